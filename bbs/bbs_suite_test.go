@@ -0,0 +1,13 @@
+package bbs_test
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"testing"
+)
+
+func TestBBS(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "BBS test suite")
+}
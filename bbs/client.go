@@ -0,0 +1,120 @@
+package bbs
+
+import (
+	"bytes"
+	"fmt"
+	"github.com/FidelityInternational/diego-capacity-monitor/mtls"
+	"github.com/gogo/protobuf/proto"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// The BBS routes that are used
+const (
+	CellsRoute                     = "/v1/cells/list.r1"
+	ActualLRPsRoute                = "/v1/actual_lrps/list"
+	DesiredLRPSchedulingInfosRoute = "/v1/desired_lrp_scheduling_infos/list"
+)
+
+// Config - how to reach the BBS, the certificates and key are either PEM or the path of a PEM file
+type Config struct {
+	URL        string
+	CACert     string
+	ClientCert string
+	ClientKey  string
+	Timeout    time.Duration
+}
+
+// Client - a client of the BBS API authenticating with mutual TLS
+type Client struct {
+	url        string
+	httpClient *http.Client
+}
+
+// NewClient - creates a BBS client from the config
+func NewClient(config Config) (*Client, error) {
+	if config.URL == "" {
+		return nil, fmt.Errorf("the BBS URL must be set")
+	}
+	tlsConfig, err := mtls.Config{CACert: config.CACert, ClientCert: config.ClientCert, ClientKey: config.ClientKey}.TLSConfig("BBS")
+	if err != nil {
+		return nil, err
+	}
+	timeout := config.Timeout
+	if timeout == 0 {
+		timeout = 10 * time.Second
+	}
+	return &Client{
+		url:        strings.TrimSuffix(config.URL, "/"),
+		httpClient: &http.Client{Timeout: timeout, Transport: &http.Transport{TLSClientConfig: tlsConfig}},
+	}, nil
+}
+
+// Cells - returns the cells registered with the BBS
+func (c *Client) Cells() ([]*CellPresence, error) {
+	var response CellsResponse
+	if err := c.do(CellsRoute, &CellsRequest{}, &response); err != nil {
+		return nil, err
+	}
+	if response.Error != nil {
+		return nil, response.Error
+	}
+	return response.Cells, nil
+}
+
+// ActualLRPs - returns the actual LRPs
+func (c *Client) ActualLRPs() ([]*ActualLRP, error) {
+	var response ActualLRPsResponse
+	if err := c.do(ActualLRPsRoute, &ActualLRPsRequest{}, &response); err != nil {
+		return nil, err
+	}
+	if response.Error != nil {
+		return nil, response.Error
+	}
+	return response.ActualLRPs, nil
+}
+
+// DesiredLRPSchedulingInfos - returns the scheduling information of the desired LRPs
+func (c *Client) DesiredLRPSchedulingInfos() ([]*DesiredLRPSchedulingInfo, error) {
+	var response DesiredLRPSchedulingInfosResponse
+	if err := c.do(DesiredLRPSchedulingInfosRoute, &DesiredLRPsRequest{}, &response); err != nil {
+		return nil, err
+	}
+	if response.Error != nil {
+		return nil, response.Error
+	}
+	return response.DesiredLRPSchedulingInfos, nil
+}
+
+// do - posts a protobuf request to a BBS route and unmarshals the protobuf response
+func (c *Client) do(route string, request proto.Message, response proto.Message) error {
+	body, err := proto.Marshal(request)
+	if err != nil {
+		return err
+	}
+	httpRequest, err := http.NewRequest("POST", c.url+route, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	httpRequest.Header.Set("Content-Type", "application/x-protobuf")
+	httpRequest.Header.Set("Accept", "application/x-protobuf")
+
+	httpResponse, err := c.httpClient.Do(httpRequest)
+	if err != nil {
+		return fmt.Errorf("could not reach the BBS: %v", err)
+	}
+	defer httpResponse.Body.Close()
+	responseBody, err := ioutil.ReadAll(httpResponse.Body)
+	if err != nil {
+		return fmt.Errorf("could not read the BBS response: %v", err)
+	}
+	if httpResponse.StatusCode != http.StatusOK {
+		return fmt.Errorf("the BBS responded to %s with %d", route, httpResponse.StatusCode)
+	}
+	if err := proto.Unmarshal(responseBody, response); err != nil {
+		return fmt.Errorf("could not unmarshal the BBS response: %v", err)
+	}
+	return nil
+}
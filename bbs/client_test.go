@@ -0,0 +1,180 @@
+package bbs_test
+
+import (
+	"github.com/FidelityInternational/diego-capacity-monitor/bbs"
+	"github.com/FidelityInternational/diego-capacity-monitor/mtls/mtlstest"
+	"github.com/gogo/protobuf/proto"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"io/ioutil"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+)
+
+var _ = Describe("Client", func() {
+	var (
+		ca         *mtlstest.Certificate
+		clientCert *mtlstest.Certificate
+		server     *httptest.Server
+		responses  map[string]proto.Message
+		config     bbs.Config
+	)
+
+	BeforeEach(func() {
+		ca = mtlstest.NewCertificate("bbs-ca", nil)
+		clientCert = mtlstest.NewCertificate("diego-capacity-monitor", ca)
+		responses = map[string]proto.Message{
+			bbs.CellsRoute: &bbs.CellsResponse{Cells: []*bbs.CellPresence{
+				{CellID: "cell-1", RepAddress: "http://10.0.0.1:1800", Zone: "z1",
+					Capacity: &bbs.CellCapacity{MemoryMB: 16384, DiskMB: 65536, Containers: 250}, PlacementTags: []string{"iso"}},
+				{CellID: "cell-2", Zone: "z2", Capacity: &bbs.CellCapacity{MemoryMB: 16384}},
+			}},
+		}
+	})
+
+	JustBeforeEach(func() {
+		server = newBBS(ca, responses)
+		config = bbs.Config{URL: server.URL, CACert: string(ca.CertPEM), ClientCert: string(clientCert.CertPEM), ClientKey: string(clientCert.KeyPEM)}
+	})
+
+	AfterEach(func() {
+		server.Close()
+	})
+
+	Describe("#Cells", func() {
+		It("returns the cells registered with the BBS", func() {
+			client, err := bbs.NewClient(config)
+			Ω(err).Should(BeNil())
+			cells, err := client.Cells()
+			Ω(err).Should(BeNil())
+			Ω(cells).Should(HaveLen(2))
+			Ω(cells[0].CellID).Should(Equal("cell-1"))
+			Ω(cells[0].RepAddress).Should(Equal("http://10.0.0.1:1800"))
+			Ω(cells[0].Zone).Should(Equal("z1"))
+			Ω(*cells[0].Capacity).Should(Equal(bbs.CellCapacity{MemoryMB: 16384, DiskMB: 65536, Containers: 250}))
+			Ω(cells[0].PlacementTags).Should(Equal([]string{"iso"}))
+			Ω(cells[1].CellID).Should(Equal("cell-2"))
+		})
+
+		Context("when the certificates are files", func() {
+			var dir string
+
+			JustBeforeEach(func() {
+				var err error
+				dir, err = ioutil.TempDir("", "bbs")
+				Ω(err).Should(BeNil())
+				for name, content := range map[string][]byte{"ca.crt": ca.CertPEM, "client.crt": clientCert.CertPEM, "client.key": clientCert.KeyPEM} {
+					Ω(ioutil.WriteFile(filepath.Join(dir, name), content, 0600)).Should(Succeed())
+				}
+				config.CACert = filepath.Join(dir, "ca.crt")
+				config.ClientCert = filepath.Join(dir, "client.crt")
+				config.ClientKey = filepath.Join(dir, "client.key")
+			})
+
+			AfterEach(func() {
+				os.RemoveAll(dir)
+			})
+
+			It("reads them", func() {
+				client, err := bbs.NewClient(config)
+				Ω(err).Should(BeNil())
+				cells, err := client.Cells()
+				Ω(err).Should(BeNil())
+				Ω(cells).Should(HaveLen(2))
+			})
+		})
+
+		Context("when the BBS returns an error", func() {
+			BeforeEach(func() {
+				responses[bbs.CellsRoute] = &bbs.CellsResponse{Error: &bbs.Error{Type: 2, Message: "deadline exceeded"}}
+			})
+
+			It("returns the error", func() {
+				client, _ := bbs.NewClient(config)
+				_, err := client.Cells()
+				Ω(err).Should(MatchError("BBS error 2: deadline exceeded"))
+			})
+		})
+
+		Context("when the client certificate is not signed by the BBS's CA", func() {
+			It("fails to connect", func() {
+				otherCert := mtlstest.NewCertificate("other", mtlstest.NewCertificate("other-ca", nil))
+				config.ClientCert, config.ClientKey = string(otherCert.CertPEM), string(otherCert.KeyPEM)
+				client, err := bbs.NewClient(config)
+				Ω(err).Should(BeNil())
+				_, err = client.Cells()
+				Ω(err).ShouldNot(BeNil())
+				Ω(err.Error()).Should(ContainSubstring("could not reach the BBS"))
+			})
+		})
+
+		Context("when the BBS's certificate is not signed by the CA", func() {
+			It("fails to connect", func() {
+				config.CACert = string(mtlstest.NewCertificate("other-ca", nil).CertPEM)
+				client, _ := bbs.NewClient(config)
+				_, err := client.Cells()
+				Ω(err).ShouldNot(BeNil())
+			})
+		})
+	})
+
+	Describe("#NewClient", func() {
+		It("requires a URL", func() {
+			_, err := bbs.NewClient(bbs.Config{})
+			Ω(err).Should(MatchError("the BBS URL must be set"))
+		})
+
+		It("returns an error for an invalid CA certificate", func() {
+			config.CACert = "-----BEGIN nonsense"
+			_, err := bbs.NewClient(config)
+			Ω(err).Should(MatchError("the BBS CA certificate does not contain a PEM certificate"))
+		})
+	})
+})
+
+var _ = Describe("Registry", func() {
+	It("keeps the cells fetched by the latest successful refresh", func() {
+		lister := &fakeLister{cells: []*bbs.CellPresence{{CellID: "cell-1"}}}
+		registry := bbs.CreateRegistry(lister)
+		_, _, ok := registry.Cells()
+		Ω(ok).Should(BeFalse())
+
+		Ω(registry.Refresh()).Should(Succeed())
+		cells, fetchedAt, ok := registry.Cells()
+		Ω(ok).Should(BeTrue())
+		Ω(cells).Should(HaveKey("cell-1"))
+		Ω(fetchedAt.IsZero()).Should(BeFalse())
+
+		lister.err = &bbs.Error{Message: "unavailable"}
+		Ω(registry.Refresh()).ShouldNot(Succeed())
+		cells, _, ok = registry.Cells()
+		Ω(ok).Should(BeTrue())
+		Ω(cells).Should(HaveKey("cell-1"))
+		Ω(registry.Err()).Should(MatchError("BBS error 0: unavailable"))
+	})
+
+	It("returns the largest memory registered by a cell", func() {
+		registry := bbs.CreateRegistry(&fakeLister{cells: []*bbs.CellPresence{
+			{CellID: "cell-1"},
+			{CellID: "cell-2", Capacity: &bbs.CellCapacity{MemoryMB: 16384}},
+			{CellID: "cell-3", Capacity: &bbs.CellCapacity{MemoryMB: 32768}},
+		}})
+		_, ok := registry.CellMemory()
+		Ω(ok).Should(BeFalse())
+
+		Ω(registry.Refresh()).Should(Succeed())
+		memory, ok := registry.CellMemory()
+		Ω(ok).Should(BeTrue())
+		Ω(memory).Should(Equal(32768.0))
+	})
+})
+
+type fakeLister struct {
+	cells []*bbs.CellPresence
+	err   error
+}
+
+func (f *fakeLister) Cells() ([]*bbs.CellPresence, error) {
+	return f.cells, f.err
+}
@@ -0,0 +1,29 @@
+package bbs_test
+
+import (
+	"github.com/FidelityInternational/diego-capacity-monitor/bbs"
+	"github.com/FidelityInternational/diego-capacity-monitor/mtls/mtlstest"
+	"github.com/gogo/protobuf/proto"
+	. "github.com/onsi/gomega"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+)
+
+// newBBS - starts a TLS stand-in for the BBS that requires client certificates signed by the CA and responds to
+// each route with the protobuf response
+func newBBS(ca *mtlstest.Certificate, responses map[string]proto.Message) *httptest.Server {
+	return mtlstest.NewServer(ca, "bbs.service.cf.internal", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		response, ok := responses[r.URL.Path]
+		if !ok || r.Method != "POST" || r.Header.Get("Content-Type") != "application/x-protobuf" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		body, _ := ioutil.ReadAll(r.Body)
+		Ω(proto.Unmarshal(body, &bbs.CellsRequest{})).Should(Succeed())
+		bytes, err := proto.Marshal(response)
+		Ω(err).Should(BeNil())
+		w.Header().Set("Content-Type", "application/x-protobuf")
+		w.Write(bytes)
+	}))
+}
@@ -0,0 +1,109 @@
+package bbs
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+// LRPLister - lists the actual LRPs and the scheduling information of the desired LRPs
+type LRPLister interface {
+	ActualLRPs() ([]*ActualLRP, error)
+	DesiredLRPSchedulingInfos() ([]*DesiredLRPSchedulingInfo, error)
+}
+
+// Instance - an LRP instance placed on a cell and the resources it reserves
+type Instance struct {
+	ProcessGUID   string   `json:"process_guid"`
+	Index         int32    `json:"index"`
+	InstanceGUID  string   `json:"instance_guid"`
+	State         string   `json:"state"`
+	MemoryMB      int32    `json:"memory_mb"`
+	DiskMB        int32    `json:"disk_mb"`
+	PlacementTags []string `json:"placement_tags,omitempty"`
+}
+
+// Instances - the latest LRP instances placed on each cell, fetched from the BBS
+type Instances struct {
+	lister    LRPLister
+	cells     map[string][]Instance
+	fetchedAt time.Time
+	err       error
+	mutex     sync.Mutex
+}
+
+// CreateInstances - creates Instances that fetches LRPs from the lister
+func CreateInstances(lister LRPLister) *Instances {
+	return &Instances{lister: lister}
+}
+
+// Refresh - fetches the actual LRPs that are claimed or running on a cell and joins them with the resources
+// their desired LRP reserves, the previously fetched instances are kept if the fetch fails
+func (i *Instances) Refresh() error {
+	actualLRPs, err := i.lister.ActualLRPs()
+	var schedulingInfos []*DesiredLRPSchedulingInfo
+	if err == nil {
+		schedulingInfos, err = i.lister.DesiredLRPSchedulingInfos()
+	}
+	i.mutex.Lock()
+	defer i.mutex.Unlock()
+	i.err = err
+	if err != nil {
+		return err
+	}
+
+	desired := make(map[string]*DesiredLRPSchedulingInfo)
+	for _, schedulingInfo := range schedulingInfos {
+		if schedulingInfo.DesiredLRPKey != nil {
+			desired[schedulingInfo.DesiredLRPKey.ProcessGUID] = schedulingInfo
+		}
+	}
+
+	cells := make(map[string][]Instance)
+	for _, actualLRP := range actualLRPs {
+		if actualLRP.ActualLRPKey == nil || actualLRP.ActualLRPInstanceKey == nil || actualLRP.ActualLRPInstanceKey.CellID == "" ||
+			(actualLRP.State != ActualLRPStateClaimed && actualLRP.State != ActualLRPStateRunning) {
+			continue
+		}
+		instance := Instance{
+			ProcessGUID:  actualLRP.ActualLRPKey.ProcessGUID,
+			Index:        actualLRP.ActualLRPKey.Index,
+			InstanceGUID: actualLRP.ActualLRPInstanceKey.InstanceGUID,
+			State:        actualLRP.State,
+		}
+		if schedulingInfo, ok := desired[instance.ProcessGUID]; ok {
+			if schedulingInfo.DesiredLRPResource != nil {
+				instance.MemoryMB = schedulingInfo.DesiredLRPResource.MemoryMB
+				instance.DiskMB = schedulingInfo.DesiredLRPResource.DiskMB
+			}
+			instance.PlacementTags = schedulingInfo.PlacementTags
+		}
+		cellID := actualLRP.ActualLRPInstanceKey.CellID
+		cells[cellID] = append(cells[cellID], instance)
+	}
+	for _, instances := range cells {
+		sort.Slice(instances, func(a, b int) bool {
+			if instances[a].ProcessGUID != instances[b].ProcessGUID {
+				return instances[a].ProcessGUID < instances[b].ProcessGUID
+			}
+			return instances[a].Index < instances[b].Index
+		})
+	}
+	i.cells = cells
+	i.fetchedAt = time.Now()
+	return nil
+}
+
+// Cells - returns the instances on each cell and when they were fetched, ok is false until they have been fetched
+func (i *Instances) Cells() (cells map[string][]Instance, fetchedAt time.Time, ok bool) {
+	i.mutex.Lock()
+	defer i.mutex.Unlock()
+	return i.cells, i.fetchedAt, i.cells != nil
+}
+
+// Err - returns the error from the latest fetch, if it failed
+func (i *Instances) Err() error {
+	i.mutex.Lock()
+	defer i.mutex.Unlock()
+	return i.err
+}
@@ -0,0 +1,91 @@
+package bbs_test
+
+import (
+	"github.com/FidelityInternational/diego-capacity-monitor/bbs"
+	"github.com/FidelityInternational/diego-capacity-monitor/mtls/mtlstest"
+	"github.com/gogo/protobuf/proto"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"net/http/httptest"
+)
+
+var _ = Describe("Instances", func() {
+	var (
+		ca        *mtlstest.Certificate
+		server    *httptest.Server
+		responses map[string]proto.Message
+		instances *bbs.Instances
+	)
+
+	actualLRP := func(processGUID string, index int32, cellID string, state string) *bbs.ActualLRP {
+		return &bbs.ActualLRP{
+			ActualLRPKey:         &bbs.ActualLRPKey{ProcessGUID: processGUID, Index: index, Domain: "cf-apps"},
+			ActualLRPInstanceKey: &bbs.ActualLRPInstanceKey{InstanceGUID: processGUID + "-instance", CellID: cellID},
+			State:                state,
+		}
+	}
+
+	BeforeEach(func() {
+		ca = mtlstest.NewCertificate("bbs-ca", nil)
+		responses = map[string]proto.Message{
+			bbs.ActualLRPsRoute: &bbs.ActualLRPsResponse{ActualLRPs: []*bbs.ActualLRP{
+				actualLRP("app-b", 0, "cell-1", bbs.ActualLRPStateRunning),
+				actualLRP("app-a", 1, "cell-1", bbs.ActualLRPStateClaimed),
+				actualLRP("app-a", 0, "cell-2", bbs.ActualLRPStateRunning),
+				actualLRP("app-a", 2, "", bbs.ActualLRPStateUnclaimed),
+				actualLRP("app-c", 0, "cell-2", bbs.ActualLRPStateCrashed),
+			}},
+			bbs.DesiredLRPSchedulingInfosRoute: &bbs.DesiredLRPSchedulingInfosResponse{DesiredLRPSchedulingInfos: []*bbs.DesiredLRPSchedulingInfo{
+				{DesiredLRPKey: &bbs.DesiredLRPKey{ProcessGUID: "app-a", Domain: "cf-apps"}, Instances: 3,
+					DesiredLRPResource: &bbs.DesiredLRPResource{MemoryMB: 1024, DiskMB: 2048}, PlacementTags: []string{"iso"}},
+				{DesiredLRPKey: &bbs.DesiredLRPKey{ProcessGUID: "app-b", Domain: "cf-apps"}, Instances: 1,
+					DesiredLRPResource: &bbs.DesiredLRPResource{MemoryMB: 256, DiskMB: 1024}},
+			}},
+		}
+	})
+
+	JustBeforeEach(func() {
+		server = newBBS(ca, responses)
+		clientCert := mtlstest.NewCertificate("diego-capacity-monitor", ca)
+		client, err := bbs.NewClient(bbs.Config{URL: server.URL, CACert: string(ca.CertPEM), ClientCert: string(clientCert.CertPEM), ClientKey: string(clientCert.KeyPEM)})
+		Ω(err).Should(BeNil())
+		instances = bbs.CreateInstances(client)
+	})
+
+	AfterEach(func() {
+		server.Close()
+	})
+
+	Describe("#Refresh", func() {
+		It("records the instances placed on each cell with the resources they reserve", func() {
+			_, _, ok := instances.Cells()
+			Ω(ok).Should(BeFalse())
+
+			Ω(instances.Refresh()).Should(Succeed())
+			cells, _, ok := instances.Cells()
+			Ω(ok).Should(BeTrue())
+			Ω(cells).Should(Equal(map[string][]bbs.Instance{
+				"cell-1": {
+					{ProcessGUID: "app-a", Index: 1, InstanceGUID: "app-a-instance", State: "CLAIMED", MemoryMB: 1024, DiskMB: 2048, PlacementTags: []string{"iso"}},
+					{ProcessGUID: "app-b", Index: 0, InstanceGUID: "app-b-instance", State: "RUNNING", MemoryMB: 256, DiskMB: 1024},
+				},
+				"cell-2": {
+					{ProcessGUID: "app-a", Index: 0, InstanceGUID: "app-a-instance", State: "RUNNING", MemoryMB: 1024, DiskMB: 2048, PlacementTags: []string{"iso"}},
+				},
+			}))
+		})
+
+		Context("when the BBS returns an error", func() {
+			BeforeEach(func() {
+				responses[bbs.DesiredLRPSchedulingInfosRoute] = &bbs.DesiredLRPSchedulingInfosResponse{Error: &bbs.Error{Type: 1, Message: "unknown"}}
+			})
+
+			It("returns the error", func() {
+				Ω(instances.Refresh()).Should(MatchError("BBS error 1: unknown"))
+				Ω(instances.Err()).Should(MatchError("BBS error 1: unknown"))
+				_, _, ok := instances.Cells()
+				Ω(ok).Should(BeFalse())
+			})
+		})
+	})
+})
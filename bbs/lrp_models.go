@@ -0,0 +1,137 @@
+package bbs
+
+import (
+	"github.com/gogo/protobuf/proto"
+)
+
+// ActualLRP states
+const (
+	ActualLRPStateUnclaimed = "UNCLAIMED"
+	ActualLRPStateClaimed   = "CLAIMED"
+	ActualLRPStateRunning   = "RUNNING"
+	ActualLRPStateCrashed   = "CRASHED"
+)
+
+// ActualLRPKey - identifies an instance of a desired LRP
+type ActualLRPKey struct {
+	ProcessGUID string `protobuf:"bytes,1,opt,name=process_guid,json=processGuid,proto3" json:"process_guid"`
+	Index       int32  `protobuf:"varint,2,opt,name=index,proto3" json:"index"`
+	Domain      string `protobuf:"bytes,3,opt,name=domain,proto3" json:"domain"`
+}
+
+func (m *ActualLRPKey) Reset()         { *m = ActualLRPKey{} }
+func (m *ActualLRPKey) String() string { return proto.CompactTextString(m) }
+
+// ProtoMessage - marks ActualLRPKey as a protobuf message
+func (*ActualLRPKey) ProtoMessage() {}
+
+// ActualLRPInstanceKey - identifies the cell an instance has been placed on
+type ActualLRPInstanceKey struct {
+	InstanceGUID string `protobuf:"bytes,1,opt,name=instance_guid,json=instanceGuid,proto3" json:"instance_guid"`
+	CellID       string `protobuf:"bytes,2,opt,name=cell_id,json=cellId,proto3" json:"cell_id"`
+}
+
+func (m *ActualLRPInstanceKey) Reset()         { *m = ActualLRPInstanceKey{} }
+func (m *ActualLRPInstanceKey) String() string { return proto.CompactTextString(m) }
+
+// ProtoMessage - marks ActualLRPInstanceKey as a protobuf message
+func (*ActualLRPInstanceKey) ProtoMessage() {}
+
+// ActualLRP - an instance of a desired LRP
+type ActualLRP struct {
+	ActualLRPKey         *ActualLRPKey         `protobuf:"bytes,1,opt,name=actual_lrp_key,json=actualLrpKey" json:"actual_lrp_key,omitempty"`
+	ActualLRPInstanceKey *ActualLRPInstanceKey `protobuf:"bytes,2,opt,name=actual_lrp_instance_key,json=actualLrpInstanceKey" json:"actual_lrp_instance_key,omitempty"`
+	State                string                `protobuf:"bytes,6,opt,name=state,proto3" json:"state"`
+	Since                int64                 `protobuf:"varint,8,opt,name=since,proto3" json:"since"`
+}
+
+func (m *ActualLRP) Reset()         { *m = ActualLRP{} }
+func (m *ActualLRP) String() string { return proto.CompactTextString(m) }
+
+// ProtoMessage - marks ActualLRP as a protobuf message
+func (*ActualLRP) ProtoMessage() {}
+
+// ActualLRPsRequest - a request for the actual LRPs, optionally of a domain or on a cell
+type ActualLRPsRequest struct {
+	Domain string `protobuf:"bytes,1,opt,name=domain,proto3" json:"domain"`
+	CellID string `protobuf:"bytes,2,opt,name=cell_id,json=cellId,proto3" json:"cell_id"`
+}
+
+func (m *ActualLRPsRequest) Reset()         { *m = ActualLRPsRequest{} }
+func (m *ActualLRPsRequest) String() string { return proto.CompactTextString(m) }
+
+// ProtoMessage - marks ActualLRPsRequest as a protobuf message
+func (*ActualLRPsRequest) ProtoMessage() {}
+
+// ActualLRPsResponse - the actual LRPs
+type ActualLRPsResponse struct {
+	Error      *Error       `protobuf:"bytes,1,opt,name=error" json:"error,omitempty"`
+	ActualLRPs []*ActualLRP `protobuf:"bytes,2,rep,name=actual_lrps,json=actualLrps" json:"actual_lrps,omitempty"`
+}
+
+func (m *ActualLRPsResponse) Reset()         { *m = ActualLRPsResponse{} }
+func (m *ActualLRPsResponse) String() string { return proto.CompactTextString(m) }
+
+// ProtoMessage - marks ActualLRPsResponse as a protobuf message
+func (*ActualLRPsResponse) ProtoMessage() {}
+
+// DesiredLRPKey - identifies a desired LRP
+type DesiredLRPKey struct {
+	ProcessGUID string `protobuf:"bytes,1,opt,name=process_guid,json=processGuid,proto3" json:"process_guid"`
+	Domain      string `protobuf:"bytes,2,opt,name=domain,proto3" json:"domain"`
+}
+
+func (m *DesiredLRPKey) Reset()         { *m = DesiredLRPKey{} }
+func (m *DesiredLRPKey) String() string { return proto.CompactTextString(m) }
+
+// ProtoMessage - marks DesiredLRPKey as a protobuf message
+func (*DesiredLRPKey) ProtoMessage() {}
+
+// DesiredLRPResource - the resources each instance of a desired LRP reserves
+type DesiredLRPResource struct {
+	MemoryMB int32 `protobuf:"varint,1,opt,name=memory_mb,json=memoryMb,proto3" json:"memory_mb"`
+	DiskMB   int32 `protobuf:"varint,2,opt,name=disk_mb,json=diskMb,proto3" json:"disk_mb"`
+}
+
+func (m *DesiredLRPResource) Reset()         { *m = DesiredLRPResource{} }
+func (m *DesiredLRPResource) String() string { return proto.CompactTextString(m) }
+
+// ProtoMessage - marks DesiredLRPResource as a protobuf message
+func (*DesiredLRPResource) ProtoMessage() {}
+
+// DesiredLRPSchedulingInfo - the scheduling information of a desired LRP
+type DesiredLRPSchedulingInfo struct {
+	DesiredLRPKey      *DesiredLRPKey      `protobuf:"bytes,1,opt,name=desired_lrp_key,json=desiredLrpKey" json:"desired_lrp_key,omitempty"`
+	Instances          int32               `protobuf:"varint,3,opt,name=instances,proto3" json:"instances"`
+	DesiredLRPResource *DesiredLRPResource `protobuf:"bytes,4,opt,name=desired_lrp_resource,json=desiredLrpResource" json:"desired_lrp_resource,omitempty"`
+	PlacementTags      []string            `protobuf:"bytes,8,rep,name=placement_tags,json=placementTags" json:"placement_tags,omitempty"`
+}
+
+func (m *DesiredLRPSchedulingInfo) Reset()         { *m = DesiredLRPSchedulingInfo{} }
+func (m *DesiredLRPSchedulingInfo) String() string { return proto.CompactTextString(m) }
+
+// ProtoMessage - marks DesiredLRPSchedulingInfo as a protobuf message
+func (*DesiredLRPSchedulingInfo) ProtoMessage() {}
+
+// DesiredLRPsRequest - a request for the desired LRPs, optionally of a domain
+type DesiredLRPsRequest struct {
+	Domain string `protobuf:"bytes,1,opt,name=domain,proto3" json:"domain"`
+}
+
+func (m *DesiredLRPsRequest) Reset()         { *m = DesiredLRPsRequest{} }
+func (m *DesiredLRPsRequest) String() string { return proto.CompactTextString(m) }
+
+// ProtoMessage - marks DesiredLRPsRequest as a protobuf message
+func (*DesiredLRPsRequest) ProtoMessage() {}
+
+// DesiredLRPSchedulingInfosResponse - the scheduling information of the desired LRPs
+type DesiredLRPSchedulingInfosResponse struct {
+	Error                     *Error                      `protobuf:"bytes,1,opt,name=error" json:"error,omitempty"`
+	DesiredLRPSchedulingInfos []*DesiredLRPSchedulingInfo `protobuf:"bytes,2,rep,name=desired_lrp_scheduling_infos,json=desiredLrpSchedulingInfos" json:"desired_lrp_scheduling_infos,omitempty"`
+}
+
+func (m *DesiredLRPSchedulingInfosResponse) Reset()         { *m = DesiredLRPSchedulingInfosResponse{} }
+func (m *DesiredLRPSchedulingInfosResponse) String() string { return proto.CompactTextString(m) }
+
+// ProtoMessage - marks DesiredLRPSchedulingInfosResponse as a protobuf message
+func (*DesiredLRPSchedulingInfosResponse) ProtoMessage() {}
@@ -0,0 +1,75 @@
+package bbs
+
+import (
+	"fmt"
+	"github.com/gogo/protobuf/proto"
+)
+
+// The BBS models below are written by hand, only the fields the monitor uses are declared and any others are
+// skipped when they are unmarshalled. Field numbers match the BBS's models.
+
+// Error - an error returned in the body of a BBS response
+type Error struct {
+	Type    int32  `protobuf:"varint,1,opt,name=type,proto3" json:"type,omitempty"`
+	Message string `protobuf:"bytes,2,opt,name=message,proto3" json:"message,omitempty"`
+}
+
+func (m *Error) Reset()         { *m = Error{} }
+func (m *Error) String() string { return proto.CompactTextString(m) }
+
+// ProtoMessage - marks Error as a protobuf message
+func (*Error) ProtoMessage() {}
+
+func (m *Error) Error() string {
+	return fmt.Sprintf("BBS error %d: %s", m.Type, m.Message)
+}
+
+// CellCapacity - the total capacity a cell offers
+type CellCapacity struct {
+	MemoryMB   int32 `protobuf:"varint,1,opt,name=memory_mb,json=memoryMb,proto3" json:"memory_mb"`
+	DiskMB     int32 `protobuf:"varint,2,opt,name=disk_mb,json=diskMb,proto3" json:"disk_mb"`
+	Containers int32 `protobuf:"varint,3,opt,name=containers,proto3" json:"containers"`
+}
+
+func (m *CellCapacity) Reset()         { *m = CellCapacity{} }
+func (m *CellCapacity) String() string { return proto.CompactTextString(m) }
+
+// ProtoMessage - marks CellCapacity as a protobuf message
+func (*CellCapacity) ProtoMessage() {}
+
+// CellPresence - a cell registered with the BBS
+type CellPresence struct {
+	CellID        string        `protobuf:"bytes,1,opt,name=cell_id,json=cellId,proto3" json:"cell_id"`
+	RepAddress    string        `protobuf:"bytes,2,opt,name=rep_address,json=repAddress,proto3" json:"rep_address"`
+	Zone          string        `protobuf:"bytes,3,opt,name=zone,proto3" json:"zone"`
+	Capacity      *CellCapacity `protobuf:"bytes,4,opt,name=capacity" json:"capacity,omitempty"`
+	PlacementTags []string      `protobuf:"bytes,6,rep,name=placement_tags,json=placementTags" json:"placement_tags,omitempty"`
+	RepURL        string        `protobuf:"bytes,8,opt,name=rep_url,json=repUrl,proto3" json:"rep_url,omitempty"`
+}
+
+func (m *CellPresence) Reset()         { *m = CellPresence{} }
+func (m *CellPresence) String() string { return proto.CompactTextString(m) }
+
+// ProtoMessage - marks CellPresence as a protobuf message
+func (*CellPresence) ProtoMessage() {}
+
+// CellsRequest - the empty request for the cells registered with the BBS
+type CellsRequest struct{}
+
+func (m *CellsRequest) Reset()         { *m = CellsRequest{} }
+func (m *CellsRequest) String() string { return proto.CompactTextString(m) }
+
+// ProtoMessage - marks CellsRequest as a protobuf message
+func (*CellsRequest) ProtoMessage() {}
+
+// CellsResponse - the cells registered with the BBS
+type CellsResponse struct {
+	Error *Error          `protobuf:"bytes,1,opt,name=error" json:"error,omitempty"`
+	Cells []*CellPresence `protobuf:"bytes,2,rep,name=cells" json:"cells,omitempty"`
+}
+
+func (m *CellsResponse) Reset()         { *m = CellsResponse{} }
+func (m *CellsResponse) String() string { return proto.CompactTextString(m) }
+
+// ProtoMessage - marks CellsResponse as a protobuf message
+func (*CellsResponse) ProtoMessage() {}
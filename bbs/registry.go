@@ -0,0 +1,69 @@
+package bbs
+
+import (
+	"sync"
+	"time"
+)
+
+// CellLister - lists the cells registered with the BBS
+type CellLister interface {
+	Cells() ([]*CellPresence, error)
+}
+
+// Registry - the latest cells fetched from the BBS
+type Registry struct {
+	lister    CellLister
+	cells     map[string]*CellPresence
+	fetchedAt time.Time
+	err       error
+	mutex     sync.Mutex
+}
+
+// CreateRegistry - creates a Registry that fetches cells from the lister
+func CreateRegistry(lister CellLister) *Registry {
+	return &Registry{lister: lister}
+}
+
+// Refresh - fetches the cells, the previously fetched cells are kept if the fetch fails
+func (r *Registry) Refresh() error {
+	cells, err := r.lister.Cells()
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	r.err = err
+	if err != nil {
+		return err
+	}
+	r.cells = make(map[string]*CellPresence)
+	for _, cell := range cells {
+		r.cells[cell.CellID] = cell
+	}
+	r.fetchedAt = time.Now()
+	return nil
+}
+
+// Cells - returns the cells by id and when they were fetched, ok is false until cells have been fetched
+func (r *Registry) Cells() (cells map[string]*CellPresence, fetchedAt time.Time, ok bool) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	return r.cells, r.fetchedAt, r.cells != nil
+}
+
+// CellMemory - returns the largest memory in MB registered by a cell, ok is false until a cell with its capacity
+// has been fetched
+func (r *Registry) CellMemory() (memory float64, ok bool) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	for _, cell := range r.cells {
+		if cell.Capacity != nil && float64(cell.Capacity.MemoryMB) > memory {
+			memory = float64(cell.Capacity.MemoryMB)
+		}
+	}
+	return memory, memory > 0
+}
+
+// Err - returns the error from the latest fetch, if it failed
+func (r *Registry) Err() error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	return r.err
+}
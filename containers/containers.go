@@ -0,0 +1,152 @@
+package containers
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+const bytesPerMB = 1024 * 1024
+
+// Instance - the latest memory use of an app instance reported by a ContainerMetric envelope
+type Instance struct {
+	AppID         string
+	InstanceIndex int32
+	// Cell - the index of the cell the instance is running on
+	Cell             string
+	MemoryBytes      uint64
+	MemoryBytesQuota uint64
+	ReceivedAt       int64
+}
+
+type instanceKey struct {
+	appID         string
+	instanceIndex int32
+}
+
+// Totals - the actual and reserved memory in MB of a set of app instances
+type Totals struct {
+	InstanceCount  int     `json:"instanceCount"`
+	ActualMemory   float64 `json:"actualMemory"`
+	ReservedMemory float64 `json:"reservedMemory"`
+	// UnusedMemory - the memory that is reserved but not used
+	UnusedMemory float64 `json:"unusedReservedMemory"`
+}
+
+// AppTotals - the actual and reserved memory of an app's instances
+type AppTotals struct {
+	AppID string `json:"appId"`
+	Totals
+}
+
+// Usage - the memory use of the app instances running on the cells
+type Usage struct {
+	// StaleDuration - how long an instance is remembered after its last ContainerMetric
+	StaleDuration time.Duration
+	// Now - returns the current time, time.Now when it is nil
+	Now       func() time.Time
+	instances map[instanceKey]Instance
+	mutex     sync.Mutex
+}
+
+// CreateUsage - creates an empty Usage
+func CreateUsage() *Usage {
+	return &Usage{StaleDuration: 5 * time.Minute, instances: make(map[instanceKey]Instance)}
+}
+
+// Record - records the latest memory use of an app instance, stamping it with the time it was received
+func (u *Usage) Record(instance Instance) {
+	if instance.ReceivedAt == 0 {
+		instance.ReceivedAt = u.now().UnixNano()
+	}
+	u.mutex.Lock()
+	defer u.mutex.Unlock()
+	u.instances[instanceKey{appID: instance.AppID, instanceIndex: instance.InstanceIndex}] = instance
+}
+
+// now - returns the current time from Now
+func (u *Usage) now() time.Time {
+	if u.Now == nil {
+		return time.Now()
+	}
+	return u.Now()
+}
+
+// current - returns the instances that are not stale, forgetting those that are
+func (u *Usage) current() []Instance {
+	u.mutex.Lock()
+	defer u.mutex.Unlock()
+	staleBefore := u.now().Add(-u.StaleDuration).UnixNano()
+	var instances []Instance
+	for key, instance := range u.instances {
+		if instance.ReceivedAt < staleBefore {
+			delete(u.instances, key)
+			continue
+		}
+		instances = append(instances, instance)
+	}
+	return instances
+}
+
+func (t *Totals) add(instance Instance) {
+	t.InstanceCount++
+	t.ActualMemory += float64(instance.MemoryBytes) / bytesPerMB
+	t.ReservedMemory += float64(instance.MemoryBytesQuota) / bytesPerMB
+	if instance.MemoryBytesQuota > instance.MemoryBytes {
+		t.UnusedMemory += float64(instance.MemoryBytesQuota-instance.MemoryBytes) / bytesPerMB
+	}
+}
+
+// Total - returns the actual and reserved memory of every instance
+func (u *Usage) Total() Totals {
+	var totals Totals
+	for _, instance := range u.current() {
+		totals.add(instance)
+	}
+	return totals
+}
+
+// Cells - returns the actual and reserved memory of the instances on each cell
+func (u *Usage) Cells() map[string]Totals {
+	cells := make(map[string]Totals)
+	for _, instance := range u.current() {
+		totals := cells[instance.Cell]
+		totals.add(instance)
+		cells[instance.Cell] = totals
+	}
+	return cells
+}
+
+// Apps - returns the actual and reserved memory of each app's instances, the apps wasting the most reserved
+// memory first
+func (u *Usage) Apps() []AppTotals {
+	apps := make(map[string]*AppTotals)
+	for _, instance := range u.current() {
+		if _, ok := apps[instance.AppID]; !ok {
+			apps[instance.AppID] = &AppTotals{AppID: instance.AppID}
+		}
+		apps[instance.AppID].add(instance)
+	}
+
+	sorted := []AppTotals{}
+	for _, app := range apps {
+		sorted = append(sorted, *app)
+	}
+	sort.Slice(sorted, func(i, j int) bool {
+		if sorted[i].UnusedMemory != sorted[j].UnusedMemory {
+			return sorted[i].UnusedMemory > sorted[j].UnusedMemory
+		}
+		return sorted[i].AppID < sorted[j].AppID
+	})
+	return sorted
+}
+
+// SafeOvercommit - returns the reserved memory in MB that could be overcommitted while leaving the given
+// percentage of headroom above the actual use
+func (t Totals) SafeOvercommit(headroomPercent float64) float64 {
+	safe := t.ReservedMemory - t.ActualMemory*(1+headroomPercent/100)
+	if safe < 0 {
+		return 0
+	}
+	return safe
+}
@@ -0,0 +1,13 @@
+package containers_test
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"testing"
+)
+
+func TestContainers(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "Containers test suite")
+}
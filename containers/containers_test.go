@@ -0,0 +1,71 @@
+package containers_test
+
+import (
+	"github.com/FidelityInternational/diego-capacity-monitor/containers"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"time"
+)
+
+const mb = 1024 * 1024
+
+var _ = Describe("Usage", func() {
+	var usage *containers.Usage
+
+	BeforeEach(func() {
+		usage = containers.CreateUsage()
+		usage.Record(containers.Instance{AppID: "app-a", InstanceIndex: 0, Cell: "cell-1", MemoryBytes: 100 * mb, MemoryBytesQuota: 1024 * mb})
+		usage.Record(containers.Instance{AppID: "app-a", InstanceIndex: 1, Cell: "cell-2", MemoryBytes: 200 * mb, MemoryBytesQuota: 1024 * mb})
+		usage.Record(containers.Instance{AppID: "app-b", InstanceIndex: 0, Cell: "cell-1", MemoryBytes: 500 * mb, MemoryBytesQuota: 512 * mb})
+	})
+
+	Describe("#Record", func() {
+		It("replaces the previous memory use of the instance", func() {
+			usage.Record(containers.Instance{AppID: "app-b", InstanceIndex: 0, Cell: "cell-1", MemoryBytes: 300 * mb, MemoryBytesQuota: 512 * mb})
+			Ω(usage.Total().InstanceCount).Should(Equal(3))
+			Ω(usage.Total().ActualMemory).Should(Equal(600.0))
+		})
+	})
+
+	Describe("#Total", func() {
+		It("returns the actual, reserved and unused memory of every instance", func() {
+			Ω(usage.Total()).Should(Equal(containers.Totals{InstanceCount: 3, ActualMemory: 800, ReservedMemory: 2560, UnusedMemory: 1760}))
+		})
+
+		It("forgets instances that have stopped reporting", func() {
+			usage.Record(containers.Instance{AppID: "app-c", Cell: "cell-3", MemoryBytes: mb, MemoryBytesQuota: mb,
+				ReceivedAt: time.Now().Add(-10 * time.Minute).UnixNano()})
+			Ω(usage.Total().InstanceCount).Should(Equal(3))
+		})
+	})
+
+	Describe("#Cells", func() {
+		It("returns the memory of the instances on each cell", func() {
+			Ω(usage.Cells()).Should(Equal(map[string]containers.Totals{
+				"cell-1": {InstanceCount: 2, ActualMemory: 600, ReservedMemory: 1536, UnusedMemory: 936},
+				"cell-2": {InstanceCount: 1, ActualMemory: 200, ReservedMemory: 1024, UnusedMemory: 824},
+			}))
+		})
+	})
+
+	Describe("#Apps", func() {
+		It("returns the memory of each app, the most unused reserved memory first", func() {
+			apps := usage.Apps()
+			Ω(apps).Should(HaveLen(2))
+			Ω(apps[0].AppID).Should(Equal("app-a"))
+			Ω(apps[0].UnusedMemory).Should(Equal(1748.0))
+			Ω(apps[1].AppID).Should(Equal("app-b"))
+			Ω(apps[1].UnusedMemory).Should(Equal(12.0))
+		})
+	})
+
+	Describe("#SafeOvercommit", func() {
+		It("returns the reserved memory beyond the actual use and headroom", func() {
+			Ω(usage.Total().SafeOvercommit(20)).Should(Equal(1600.0))
+		})
+
+		It("is never negative", func() {
+			Ω(containers.Totals{ActualMemory: 1000, ReservedMemory: 1000}.SafeOvercommit(20)).Should(BeZero())
+		})
+	})
+})
@@ -0,0 +1,151 @@
+package demand
+
+import (
+	"sync"
+	"time"
+)
+
+// The BBS and rep gauges that are tracked
+const (
+	LRPsDesired       = "LRPsDesired"
+	LRPsRunning       = "LRPsRunning"
+	CrashedActualLRPs = "CrashedActualLRPs"
+	CFAppsDomain      = "Domain.cf-apps"
+	ContainerCount    = "ContainerCount"
+)
+
+var bbsGauges = map[string]bool{
+	LRPsDesired:       true,
+	LRPsRunning:       true,
+	CrashedActualLRPs: true,
+	CFAppsDomain:      true,
+}
+
+type sample struct {
+	value      float64
+	receivedAt time.Time
+}
+
+type containerCount struct {
+	count      float64
+	receivedAt time.Time
+}
+
+// Tracker - tracks the demand for instances from the BBS gauges and the containers running on each cell
+type Tracker struct {
+	// TrendWindow - the window the trend of desired instances is worked out over
+	TrendWindow time.Duration
+	// StaleDuration - how long a cell's container count is remembered after it was last received
+	StaleDuration time.Duration
+	// Now - returns the current time, time.Now when it is nil
+	Now             func() time.Time
+	gauges          map[string]float64
+	desired         []sample
+	containerCounts map[string]containerCount
+	mutex           sync.Mutex
+}
+
+// CreateTracker - creates a Tracker with a 1 hour trend window
+func CreateTracker() *Tracker {
+	return &Tracker{
+		TrendWindow:     time.Hour,
+		StaleDuration:   15 * time.Minute,
+		gauges:          make(map[string]float64),
+		containerCounts: make(map[string]containerCount),
+	}
+}
+
+// now - returns the current time from Now
+func (t *Tracker) now() time.Time {
+	if t.Now == nil {
+		return time.Now()
+	}
+	return t.Now()
+}
+
+// RecordGauge - records the latest value of a gauge, container counts are recorded against the cell, returning false
+// if the gauge is not tracked
+func (t *Tracker) RecordGauge(cell string, name string, value float64) bool {
+	return t.RecordGaugeAt(cell, name, value, t.now())
+}
+
+// RecordGaugeAt - records the value of a gauge received at the given time
+func (t *Tracker) RecordGaugeAt(cell string, name string, value float64, receivedAt time.Time) bool {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	if name == ContainerCount {
+		t.containerCounts[cell] = containerCount{count: value, receivedAt: receivedAt}
+		return true
+	}
+	if !bbsGauges[name] {
+		return false
+	}
+	t.gauges[name] = value
+	if name == LRPsDesired {
+		// pruned as they are recorded so that the samples do not grow when the trend is not being read
+		t.pruneDesired(receivedAt)
+		t.desired = append(t.desired, sample{value: value, receivedAt: receivedAt})
+	}
+	return true
+}
+
+// pruneDesired - forgets the desired instance samples from before the trend window ending now
+func (t *Tracker) pruneDesired(now time.Time) {
+	var recent []sample
+	for _, sample := range t.desired {
+		if sample.receivedAt.After(now.Add(-t.TrendWindow)) {
+			recent = append(recent, sample)
+		}
+	}
+	t.desired = recent
+}
+
+// Gauge - returns the latest value of a BBS gauge, ok is false if it has not been received
+func (t *Tracker) Gauge(name string) (value float64, ok bool) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	value, ok = t.gauges[name]
+	return value, ok
+}
+
+// ContainerCounts - returns the number of containers on each cell that are not stale, forgetting those that are
+func (t *Tracker) ContainerCounts(now time.Time) map[string]float64 {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	counts := make(map[string]float64)
+	for cell, count := range t.containerCounts {
+		if count.receivedAt.Before(now.Add(-t.StaleDuration)) {
+			delete(t.containerCounts, cell)
+			continue
+		}
+		counts[cell] = count.count
+	}
+	return counts
+}
+
+// DesiredTrend - returns the change in desired instances per hour over the trend window, as the slope of a least
+// squares fit, ok is false until there are samples spanning some time
+func (t *Tracker) DesiredTrend(now time.Time) (perHour float64, ok bool) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	t.pruneDesired(now)
+	recent := t.desired
+	if len(recent) < 2 {
+		return 0, false
+	}
+
+	var sumX, sumY, sumXY, sumXX float64
+	for _, sample := range recent {
+		x := sample.receivedAt.Sub(recent[0].receivedAt).Hours()
+		sumX += x
+		sumY += sample.value
+		sumXY += x * sample.value
+		sumXX += x * x
+	}
+	n := float64(len(recent))
+	denominator := n*sumXX - sumX*sumX
+	if denominator == 0 {
+		return 0, false
+	}
+	return (n*sumXY - sumX*sumY) / denominator, true
+}
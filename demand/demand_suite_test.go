@@ -0,0 +1,13 @@
+package demand_test
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"testing"
+)
+
+func TestDemand(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "Demand test suite")
+}
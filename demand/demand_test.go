@@ -0,0 +1,73 @@
+package demand_test
+
+import (
+	"github.com/FidelityInternational/diego-capacity-monitor/demand"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"time"
+)
+
+var _ = Describe("Tracker", func() {
+	var (
+		tracker *demand.Tracker
+		now     = time.Now()
+	)
+
+	BeforeEach(func() {
+		tracker = demand.CreateTracker()
+	})
+
+	Describe("#RecordGauge", func() {
+		It("records the latest value of the BBS gauges", func() {
+			Ω(tracker.RecordGauge("bbs-0", demand.LRPsDesired, 100)).Should(BeTrue())
+			Ω(tracker.RecordGauge("bbs-0", demand.LRPsDesired, 110)).Should(BeTrue())
+			value, ok := tracker.Gauge(demand.LRPsDesired)
+			Ω(ok).Should(BeTrue())
+			Ω(value).Should(Equal(110.0))
+			_, ok = tracker.Gauge(demand.LRPsRunning)
+			Ω(ok).Should(BeFalse())
+		})
+
+		It("records container counts against the cell", func() {
+			Ω(tracker.RecordGauge("cell-1", demand.ContainerCount, 12)).Should(BeTrue())
+			Ω(tracker.ContainerCounts(now)).Should(Equal(map[string]float64{"cell-1": 12}))
+		})
+
+		It("ignores gauges that are not tracked", func() {
+			Ω(tracker.RecordGauge("bbs-0", "LRPsUnclaimed", 1)).Should(BeFalse())
+		})
+	})
+
+	Describe("#ContainerCounts", func() {
+		It("forgets cells whose container count is stale", func() {
+			tracker.RecordGaugeAt("cell-1", demand.ContainerCount, 12, now.Add(-20*time.Minute))
+			Ω(tracker.ContainerCounts(now)).Should(BeEmpty())
+		})
+	})
+
+	Describe("#DesiredTrend", func() {
+		It("is not known until there are two samples", func() {
+			tracker.RecordGaugeAt("bbs-0", demand.LRPsDesired, 100, now)
+			_, ok := tracker.DesiredTrend(now)
+			Ω(ok).Should(BeFalse())
+		})
+
+		It("returns the change in desired instances per hour within the window", func() {
+			tracker.RecordGaugeAt("bbs-0", demand.LRPsDesired, 500, now.Add(-2*time.Hour))
+			tracker.RecordGaugeAt("bbs-0", demand.LRPsDesired, 100, now.Add(-30*time.Minute))
+			tracker.RecordGaugeAt("bbs-0", demand.LRPsDesired, 110, now.Add(-15*time.Minute))
+			tracker.RecordGaugeAt("bbs-0", demand.LRPsDesired, 120, now)
+			trend, ok := tracker.DesiredTrend(now)
+			Ω(ok).Should(BeTrue())
+			Ω(trend).Should(BeNumerically("~", 40, 0.0001))
+		})
+
+		It("forgets the samples that are older than the window as new ones are recorded", func() {
+			tracker.RecordGaugeAt("bbs-0", demand.LRPsDesired, 500, now.Add(-2*time.Hour))
+			tracker.RecordGaugeAt("bbs-0", demand.LRPsDesired, 100, now.Add(-90*time.Minute))
+			tracker.RecordGaugeAt("bbs-0", demand.LRPsDesired, 120, now)
+			_, ok := tracker.DesiredTrend(now.Add(-90 * time.Minute))
+			Ω(ok).Should(BeFalse())
+		})
+	})
+})
@@ -0,0 +1,153 @@
+package federation
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Downstream - a monitor whose report is federated
+type Downstream struct {
+	Name string
+	URL  string
+}
+
+// Report - the parts of a downstream monitor's report that are merged into the global view
+type Report struct {
+	Healthy         bool     `json:"healthy"`
+	Status          string   `json:"status"`
+	Message         string   `json:"message"`
+	Reasons         []string `json:"reasons"`
+	CellCount       int      `json:"cellCount"`
+	CellMemory      float64  `json:"cellMemory"`
+	Watermark       int      `json:"watermark"`
+	TotalFreeMemory float64  `json:"totalFreeMemory"`
+	Warnings        []string `json:"warnings"`
+}
+
+// Result - the latest report fetched from a downstream monitor, the report is kept when a later fetch fails
+type Result struct {
+	Downstream
+	Report Report
+	// Raw - the report as the downstream monitor returned it
+	Raw        json.RawMessage
+	StatusCode int
+	// FetchedAt - when the report was last fetched, zero until it has been
+	FetchedAt time.Time
+	// Err - the error from the latest fetch, if it failed
+	Err error
+}
+
+// Federation - the latest reports of the downstream monitors
+type Federation struct {
+	downstreams []Downstream
+	httpClient  *http.Client
+	results     map[string]Result
+	mutex       sync.Mutex
+}
+
+// ParseDownstreams - parses a comma separated list of name=url pairs
+func ParseDownstreams(downstreams string) ([]Downstream, error) {
+	var parsed []Downstream
+	names := make(map[string]bool)
+	for _, pair := range strings.Split(downstreams, ",") {
+		if pair = strings.TrimSpace(pair); pair == "" {
+			continue
+		}
+		parts := strings.SplitN(pair, "=", 2)
+		if len(parts) != 2 || strings.TrimSpace(parts[0]) == "" || strings.TrimSpace(parts[1]) == "" {
+			return nil, fmt.Errorf("downstream %q must be of the form name=url", pair)
+		}
+		name := strings.TrimSpace(parts[0])
+		if names[name] {
+			return nil, fmt.Errorf("downstream %s is listed more than once", name)
+		}
+		names[name] = true
+		parsed = append(parsed, Downstream{Name: name, URL: strings.TrimSpace(parts[1])})
+	}
+	if len(parsed) == 0 {
+		return nil, fmt.Errorf("no downstreams were supplied")
+	}
+	return parsed, nil
+}
+
+// CreateFederation - creates a Federation of the downstream monitors
+func CreateFederation(downstreams []Downstream, timeout time.Duration) *Federation {
+	if timeout == 0 {
+		timeout = 10 * time.Second
+	}
+	return &Federation{
+		downstreams: downstreams,
+		httpClient:  &http.Client{Timeout: timeout},
+		results:     make(map[string]Result),
+	}
+}
+
+// Refresh - fetches the report of every downstream monitor at once
+func (f *Federation) Refresh() {
+	var wg sync.WaitGroup
+	for _, downstream := range f.downstreams {
+		wg.Add(1)
+		go func(downstream Downstream) {
+			defer wg.Done()
+			raw, statusCode, report, err := f.fetch(downstream)
+			f.mutex.Lock()
+			defer f.mutex.Unlock()
+			result := f.results[downstream.Name]
+			result.Downstream = downstream
+			result.Err = err
+			if err == nil {
+				result.Report, result.Raw, result.StatusCode, result.FetchedAt = report, raw, statusCode, time.Now()
+			}
+			f.results[downstream.Name] = result
+		}(downstream)
+	}
+	wg.Wait()
+}
+
+// fetch - fetches a downstream monitor's report, any status code is accepted as the report describes it
+func (f *Federation) fetch(downstream Downstream) (json.RawMessage, int, Report, error) {
+	var report Report
+	response, err := f.httpClient.Get(downstream.URL)
+	if err != nil {
+		return nil, 0, report, err
+	}
+	defer response.Body.Close()
+	body, err := ioutil.ReadAll(response.Body)
+	if err != nil {
+		return nil, 0, report, err
+	}
+	if err := json.Unmarshal(body, &report); err != nil || report.Status == "" {
+		return nil, 0, report, fmt.Errorf("%s responded with status %d and no report", downstream.URL, response.StatusCode)
+	}
+	return json.RawMessage(body), response.StatusCode, report, nil
+}
+
+// Results - returns the result of each downstream monitor in the order they were listed
+func (f *Federation) Results() []Result {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	var results []Result
+	for _, downstream := range f.downstreams {
+		result, ok := f.results[downstream.Name]
+		if !ok {
+			result = Result{Downstream: downstream}
+		}
+		results = append(results, result)
+	}
+	return results
+}
+
+// Result - returns the result of the named downstream monitor, ok is false when there is no such downstream
+func (f *Federation) Result(name string) (Result, bool) {
+	for _, result := range f.Results() {
+		if result.Name == name {
+			return result, true
+		}
+	}
+	return Result{}, false
+}
@@ -0,0 +1,13 @@
+package federation_test
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"testing"
+)
+
+func TestFederation(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "Federation test suite")
+}
@@ -0,0 +1,103 @@
+package federation_test
+
+import (
+	"fmt"
+	"github.com/FidelityInternational/diego-capacity-monitor/federation"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"net/http"
+	"net/http/httptest"
+)
+
+var _ = Describe("Federation", func() {
+	Describe("ParseDownstreams", func() {
+		It("parses a comma separated list of name=url pairs", func() {
+			downstreams, err := federation.ParseDownstreams(" east=https://monitor.east.cf/ , west=http://10.0.0.1:8080/foundations/west,")
+			Ω(err).Should(BeNil())
+			Ω(downstreams).Should(Equal([]federation.Downstream{
+				{Name: "east", URL: "https://monitor.east.cf/"},
+				{Name: "west", URL: "http://10.0.0.1:8080/foundations/west"},
+			}))
+		})
+
+		It("returns an error when a pair has no url", func() {
+			_, err := federation.ParseDownstreams("east")
+			Ω(err).Should(MatchError(`downstream "east" must be of the form name=url`))
+		})
+
+		It("returns an error when a name is repeated", func() {
+			_, err := federation.ParseDownstreams("east=http://a,east=http://b")
+			Ω(err).Should(MatchError("downstream east is listed more than once"))
+		})
+
+		It("returns an error when there are no downstreams", func() {
+			_, err := federation.ParseDownstreams(" , ")
+			Ω(err).Should(MatchError("no downstreams were supplied"))
+		})
+	})
+
+	Describe("#Refresh", func() {
+		var (
+			server     *httptest.Server
+			body       string
+			statusCode int
+			fed        *federation.Federation
+		)
+
+		BeforeEach(func() {
+			statusCode = http.StatusExpectationFailed
+			body = `{"healthy":false,"message":"The number of cells needs to exceed the watermark amount!","status":"critical",` +
+				`"reasons":["insufficient_cells"],"cellCount":2,"cellMemory":10000,"watermark":2,"totalFreeMemory":9000,"warnings":["a warning"]}`
+			server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(statusCode)
+				fmt.Fprint(w, body)
+			}))
+			fed = federation.CreateFederation([]federation.Downstream{{Name: "east", URL: server.URL}, {Name: "west", URL: "http://127.0.0.1:1/"}}, 0)
+		})
+
+		AfterEach(func() {
+			server.Close()
+		})
+
+		It("fetches the report of each downstream", func() {
+			fed.Refresh()
+			results := fed.Results()
+			Ω(results).Should(HaveLen(2))
+			Ω(results[0].Name).Should(Equal("east"))
+			Ω(results[0].Err).Should(BeNil())
+			Ω(results[0].StatusCode).Should(Equal(417))
+			Ω(results[0].FetchedAt).ShouldNot(BeZero())
+			Ω(string(results[0].Raw)).Should(Equal(body))
+			Ω(results[0].Report).Should(Equal(federation.Report{Status: "critical", Message: "The number of cells needs to exceed the watermark amount!",
+				Reasons: []string{"insufficient_cells"}, CellCount: 2, CellMemory: 10000, Watermark: 2, TotalFreeMemory: 9000, Warnings: []string{"a warning"}}))
+			Ω(results[1].Name).Should(Equal("west"))
+			Ω(results[1].Err).Should(HaveOccurred())
+			Ω(results[1].FetchedAt).Should(BeZero())
+		})
+
+		Context("when a later fetch fails", func() {
+			It("keeps the previous report along with the error", func() {
+				fed.Refresh()
+				body = "not json"
+				statusCode = http.StatusBadGateway
+				fed.Refresh()
+				result, ok := fed.Result("east")
+				Ω(ok).Should(BeTrue())
+				Ω(result.Err).Should(MatchError(server.URL + " responded with status 502 and no report"))
+				Ω(result.StatusCode).Should(Equal(417))
+				Ω(result.Report.Status).Should(Equal("critical"))
+			})
+		})
+
+		Context("before the reports are fetched", func() {
+			It("returns the downstreams without reports", func() {
+				result, ok := fed.Result("west")
+				Ω(ok).Should(BeTrue())
+				Ω(result.URL).Should(Equal("http://127.0.0.1:1/"))
+				Ω(result.FetchedAt).Should(BeZero())
+				_, ok = fed.Result("north")
+				Ω(ok).Should(BeFalse())
+			})
+		})
+	})
+})
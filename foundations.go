@@ -0,0 +1,80 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"regexp"
+	"strings"
+)
+
+// foundationConfig - a foundation to monitor, any environment variable can be overridden for it in Env
+type foundationConfig struct {
+	Name        string `json:"name"`
+	APIEndpoint string `json:"api_endpoint"`
+	Username    string `json:"username"`
+	Password    string `json:"password"`
+	Source      string `json:"source"`
+	// Namespace - separates the foundation's Redis keys from the others', defaults to its name
+	Namespace string            `json:"namespace"`
+	Watermark string            `json:"watermark"`
+	Env       map[string]string `json:"env"`
+}
+
+var foundationName = regexp.MustCompile(`^[A-Za-z0-9._-]+$`)
+
+// parseFoundations - parses a JSON list of foundations, or the path of a file containing one, no foundations are
+// returned when none are supplied
+func parseFoundations(foundations string) ([]foundationConfig, error) {
+	foundations = strings.TrimSpace(foundations)
+	if foundations == "" {
+		return nil, nil
+	}
+	content := []byte(foundations)
+	if !strings.HasPrefix(foundations, "[") {
+		var err error
+		content, err = ioutil.ReadFile(foundations)
+		if err != nil {
+			return nil, err
+		}
+	}
+	var configs []foundationConfig
+	if err := json.Unmarshal(content, &configs); err != nil {
+		return nil, fmt.Errorf("foundations must be a JSON list: %v", err)
+	}
+	names := make(map[string]bool)
+	for i, config := range configs {
+		if !foundationName.MatchString(config.Name) {
+			return nil, fmt.Errorf("foundation %d has name %q, it must only contain letters, digits, '.', '_' or '-'", i+1, config.Name)
+		}
+		if names[config.Name] {
+			return nil, fmt.Errorf("foundation %s is listed more than once", config.Name)
+		}
+		names[config.Name] = true
+		if configs[i].Namespace == "" {
+			configs[i].Namespace = config.Name
+		}
+	}
+	return configs, nil
+}
+
+// getenv - returns the foundation's setting for an environment variable, falling back to the environment
+func (f foundationConfig) getenv(key string) string {
+	for setting, value := range map[string]string{
+		"CF_API_ENDPOINT": f.APIEndpoint,
+		"CF_USERNAME":     f.Username,
+		"CF_PASSWORD":     f.Password,
+		"SOURCE":          f.Source,
+		"REDIS_NAMESPACE": f.Namespace,
+		"WATERMARK":       f.Watermark,
+	} {
+		if key == setting && value != "" {
+			return value
+		}
+	}
+	if value, ok := f.Env[key]; ok {
+		return value
+	}
+	return os.Getenv(key)
+}
@@ -8,13 +8,13 @@ require (
 	github.com/cloudfoundry-community/go-cfclient v0.0.0-20160713131947-c8d6c402f96d
 	github.com/cloudfoundry-community/go-cfenv v1.17.0
 	github.com/cloudfoundry/noaa v2.1.0+incompatible
-	github.com/cloudfoundry/sonde-go v0.0.0-20160919170257-a8900cb06815 // indirect
+	github.com/cloudfoundry/sonde-go v0.0.0-20160919170257-a8900cb06815
 	github.com/codegangsta/inject v0.0.0-20150114235600-33e0aa1cb7c0 // indirect
 	github.com/elazarl/goproxy v0.0.0-20201021153353-00ad82a08272 // indirect
 	github.com/elazarl/goproxy/ext v0.0.0-20201021153353-00ad82a08272 // indirect
 	github.com/garyburd/redigo v1.6.0 // indirect
 	github.com/go-martini/martini v0.0.0-20170121215854-22fa46961aab // indirect
-	github.com/gogo/protobuf v0.0.0-20161027062745-a9cd0c35b97d // indirect
+	github.com/gogo/protobuf v0.0.0-20161027062745-a9cd0c35b97d
 	github.com/golang/protobuf v1.1.0 // indirect
 	github.com/gorilla/context v0.0.0-20160422134237-a8d44e7d8e4d // indirect
 	github.com/gorilla/mux v0.0.0-20160317213430-0eeaf8392f5b
@@ -3,116 +3,715 @@ package main
 import (
 	"crypto/rand"
 	"crypto/tls"
+	"encoding/json"
 	"fmt"
 	"io"
 	"net/http"
 	"os"
 	"regexp"
+	"strconv"
+	"strings"
+	"sync"
 	"time"
 
+	"github.com/FidelityInternational/diego-capacity-monitor/bbs"
+	"github.com/FidelityInternational/diego-capacity-monitor/containers"
+	"github.com/FidelityInternational/diego-capacity-monitor/demand"
+	"github.com/FidelityInternational/diego-capacity-monitor/federation"
 	metricsLib "github.com/FidelityInternational/diego-capacity-monitor/metrics"
+	"github.com/FidelityInternational/diego-capacity-monitor/mtls"
+	"github.com/FidelityInternational/diego-capacity-monitor/placement"
+	"github.com/FidelityInternational/diego-capacity-monitor/recording"
+	"github.com/FidelityInternational/diego-capacity-monitor/replay"
+	"github.com/FidelityInternational/diego-capacity-monitor/schedule"
+	"github.com/FidelityInternational/diego-capacity-monitor/sources"
+	"github.com/FidelityInternational/diego-capacity-monitor/vitals"
+	watermarkLib "github.com/FidelityInternational/diego-capacity-monitor/watermark"
 	webs "github.com/FidelityInternational/diego-capacity-monitor/web_server"
 	"github.com/cloudfoundry-community/go-cfclient"
 	"github.com/cloudfoundry/noaa/consumer"
+	"github.com/cloudfoundry/sonde-go/events"
 )
 
 var messageMetrics map[string]metricsLib.MessageMetric
-var cellMemory float64
 var watermark int
 
 func main() {
-	c := &cfclient.Config{
-		ApiAddress:        os.Getenv("CF_API_ENDPOINT"),
-		Username:          os.Getenv("CF_USERNAME"),
-		Password:          os.Getenv("CF_PASSWORD"),
-		SkipSslValidation: true,
+	if path := os.Getenv("REPLAY_PATH"); path != "" {
+		replayRecording(path)
+		return
+	}
+
+	if downstreams := os.Getenv("FEDERATE"); downstreams != "" {
+		federate(downstreams)
+		return
 	}
 
-	client, err := cfclient.NewClient(c)
+	foundations, err := parseFoundations(os.Getenv("FOUNDATIONS"))
 	if err != nil {
+		fmt.Println("Error occurred parsing FOUNDATIONS")
 		fmt.Println(err.Error())
 		os.Exit(1)
 	}
 
-	watermark := os.Getenv("WATERMARK")
-	if watermark == "" {
-		fmt.Println("No WATERMARK environment variable supplied, so will default to 1")
-		watermark = "1"
+	if len(foundations) == 0 {
+		server, ingest := newFoundation(os.Getenv, foundationOptions{})
+		http.Handle("/", server.Start())
+		listen()
+		if err := ingest(); err != nil {
+			fmt.Println("Error occurred ingesting the source")
+			fmt.Println(err.Error())
+			os.Exit(1)
+		}
+		return
 	}
 
-	cnsmr := consumer.New(client.Endpoint.DopplerEndpoint, &tls.Config{InsecureSkipVerify: true}, nil)
-	cnsmr.SetDebugPrinter(consoleDebugPrinter{})
+	overview := &webs.Overview{}
+	ingesters := make(map[string]func() error)
+	for _, foundation := range foundations {
+		fmt.Printf("===== Configuring foundation %s\n", foundation.Name)
+		server, ingest := newFoundation(foundation.getenv, foundationOptions{})
+		overview.Foundations = append(overview.Foundations, webs.Foundation{Name: foundation.Name, Controller: server.Controller})
+		ingesters[foundation.Name] = ingest
+	}
+	http.Handle("/", overview.Start())
+	listen()
+	for name, ingest := range ingesters {
+		go superviseIngest(name, ingest)
+	}
+	select {}
+}
 
-	authToken, err := client.GetToken()
+// federate - serves a global view of the reports of the downstream monitors, fetching them every
+// FEDERATION_POLL_INTERVAL
+func federate(downstreams string) {
+	parsed, err := federation.ParseDownstreams(downstreams)
 	if err != nil {
-		fmt.Println("Error occurred grabbing oauth token")
+		fmt.Println("Error occurred parsing FEDERATE")
 		fmt.Println(err.Error())
 		os.Exit(1)
 	}
+	pollInterval := 30 * time.Second
+	if interval := os.Getenv("FEDERATION_POLL_INTERVAL"); interval != "" {
+		pollInterval, err = time.ParseDuration(interval)
+		if err != nil {
+			fmt.Println("Error occurred parsing FEDERATION_POLL_INTERVAL")
+			fmt.Println(err.Error())
+			os.Exit(1)
+		}
+	}
+	federated := &webs.Federated{Federation: federation.CreateFederation(parsed, 0), StaleDuration: 3 * pollInterval}
+	if staleDuration := os.Getenv("FEDERATION_STALE_DURATION"); staleDuration != "" {
+		federated.StaleDuration, err = time.ParseDuration(staleDuration)
+		if err != nil {
+			fmt.Println("Error occurred parsing FEDERATION_STALE_DURATION")
+			fmt.Println(err.Error())
+			os.Exit(1)
+		}
+	}
+	http.Handle("/", federated.Start())
+	listen()
+	fmt.Printf("===== Federating %d monitors every %v\n", len(parsed), pollInterval)
+	for {
+		federated.Federation.Refresh()
+		for _, result := range federated.Federation.Results() {
+			if result.Err != nil {
+				fmt.Printf("Error occurred fetching the report of %s: %v\n", result.Name, result.Err)
+			}
+		}
+		time.Sleep(pollInterval)
+	}
+}
 
-	fmt.Println("===== Streaming Firehose (will only succeed if you have admin credentials)")
-	metrics := metricsLib.CreateMetrics()
-
-	server := webs.CreateServer(metrics, &cellMemory, &watermark)
-
-	router := server.Start()
+// replayRecording - replays the envelopes recorded at the path, a recording or a directory of them, through the
+// same ingestion as the firehose and writes the timeline of the health evaluated along the way to REPLAY_TIMELINE or
+// stdout, the endpoints are served while a recording is replayed in real time
+func replayRecording(path string) {
+	speed, err := replay.ParseSpeed(os.Getenv("REPLAY_SPEED"))
+	if err != nil {
+		fmt.Println("Error occurred parsing REPLAY_SPEED")
+		fmt.Println(err.Error())
+		os.Exit(1)
+	}
+	evaluationInterval := 10 * time.Second
+	if interval := os.Getenv("REPLAY_EVALUATION_INTERVAL"); interval != "" {
+		evaluationInterval, err = time.ParseDuration(interval)
+		if err != nil {
+			fmt.Println("Error occurred parsing REPLAY_EVALUATION_INTERVAL")
+			fmt.Println(err.Error())
+			os.Exit(1)
+		}
+	}
+	output := os.Getenv("REPLAY_OUTPUT")
+	if output != "" && output != "text" && output != "json" {
+		fmt.Println("Error occurred parsing REPLAY_OUTPUT")
+		fmt.Printf("output %q must be one of text or json\n", output)
+		os.Exit(1)
+	}
+	if source := os.Getenv("SOURCE"); source != "" && source != "firehose" {
+		fmt.Println("Error occurred parsing SOURCE")
+		fmt.Println("only envelopes recorded from the firehose source can be replayed")
+		os.Exit(1)
+	}
+	files, err := replay.Files(path)
+	if err != nil {
+		fmt.Println("Error occurred opening REPLAY_PATH")
+		fmt.Println(err.Error())
+		os.Exit(1)
+	}
 
-	http.Handle("/", router)
+	clock := &replay.Clock{}
+	server, _ := newFoundation(os.Getenv, foundationOptions{now: clock.Now, replaying: true})
+	controller := server.Controller
+	handler := envelopeHandler{
+		store:            sources.Store{Metrics: &controller.Metrics, CellMemory: controller.CellMemory},
+		containerUsage:   controller.Containers,
+		placementTracker: controller.Placement,
+		demandTracker:    controller.Demand,
+		vitalsStore:      controller.Vitals,
+		quiet:            true,
+	}
+	if speed == replay.SpeedRealTime {
+		http.Handle("/", server.Start())
+		listen()
+	}
+	replayer := &replay.Replayer{
+		Clock:              clock,
+		Speed:              speed,
+		EvaluationInterval: evaluationInterval,
+		Start:              func(start time.Time) { controller.StartTime = start },
+		Ingest:             handler.handle,
+		Evaluate: func() webs.Health {
+			controller.Metrics.ClearStaleMetrics()
+			return controller.Health()
+		},
+	}
+	fmt.Printf("===== Replaying %d recordings at %s speed\n", len(files), speed)
+	timeline, err := replayer.Replay(files...)
+	if err != nil {
+		fmt.Println("Error occurred replaying the recording")
+		fmt.Println(err.Error())
+		os.Exit(1)
+	}
+	var out io.Writer = os.Stdout
+	if path := os.Getenv("REPLAY_TIMELINE"); path != "" {
+		file, err := os.Create(path)
+		if err != nil {
+			fmt.Println("Error occurred creating REPLAY_TIMELINE")
+			fmt.Println(err.Error())
+			os.Exit(1)
+		}
+		defer file.Close()
+		out = file
+	}
+	if output == "json" {
+		err = json.NewEncoder(out).Encode(timeline)
+	} else {
+		err = timeline.WriteText(out)
+	}
+	if err != nil {
+		fmt.Println("Error occurred writing the timeline")
+		fmt.Println(err.Error())
+		os.Exit(1)
+	}
+}
 
+// listen - serves the routes on PORT in the background
+func listen() {
 	go func() {
 		err := http.ListenAndServe(":"+os.Getenv("PORT"), nil)
 		if err != nil {
 			fmt.Println("ListenAndServe:", err)
 		}
 	}()
+}
 
-	firehoseSubscriptionID, err := newUUID()
-	if err != nil {
-		fmt.Println("Error occurred generating subscription ID")
+// foundationOptions - how a foundation is created, the zero value is a foundation ingesting its live source
+type foundationOptions struct {
+	// now - returns the time the foundation's metrics are timed by, the wall clock when it is nil
+	now func() time.Time
+	// replaying - the foundation is fed a recording, so its metrics are only kept in memory, a bound Redis is not
+	// used, and the BBS is not polled
+	replaying bool
+}
+
+// newFoundation - creates the server of a foundation configured by the environment variables getenv returns,
+// along with the function that ingests its cells' capacity from its source until it fails
+func newFoundation(getenv func(string) string, options foundationOptions) (*webs.Server, func() error) {
+	now := options.now
+	var err error
+	var cellMemory float64
+	watermark := getenv("WATERMARK")
+	if watermark == "" {
+		fmt.Println("No WATERMARK environment variable supplied, so will default to 1")
+		watermark = "1"
+	}
+
+	var metrics metricsLib.Metrics
+	if options.replaying {
+		metrics = metricsLib.CreateMemoryMetrics()
+	} else {
+		metrics = metricsLib.CreateMetrics()
+	}
+	metrics.Namespace = getenv("REDIS_NAMESPACE")
+	if err = metricsLib.ValidateNamespace(metrics.Namespace); err != nil {
+		fmt.Println("Error occurred parsing REDIS_NAMESPACE")
 		fmt.Println(err.Error())
 		os.Exit(1)
 	}
+	metrics.Now = now
+	store := sources.Store{Metrics: &metrics, CellMemory: &cellMemory}
 
-	msgChan, errorChan := cnsmr.FilteredFirehose(firehoseSubscriptionID, authToken, consumer.Metrics)
-	go func() {
-		for err := range errorChan {
-			fmt.Fprintf(os.Stderr, "%v\n", err.Error())
+	if cellRetention := getenv("CELL_RETENTION"); cellRetention != "" {
+		metrics.RetentionDuration, err = time.ParseDuration(cellRetention)
+		if err != nil {
+			fmt.Println("Error occurred parsing CELL_RETENTION")
+			fmt.Println(err.Error())
 			os.Exit(1)
 		}
-	}()
+	}
 
-	cellMemory = 0
+	if missedIntervals := getenv("MISSED_INTERVALS"); missedIntervals != "" {
+		metrics.MissedIntervals, err = strconv.Atoi(missedIntervals)
+		if err != nil {
+			fmt.Println("Error occurred parsing MISSED_INTERVALS")
+			fmt.Println(err.Error())
+			os.Exit(1)
+		}
+	}
 
-	go func() {
-		ticker := time.NewTicker(metrics.StaleDuration)
+	metrics.Smoothing, err = metricsLib.ParseSmoothing(getenv("MEMORY_SMOOTHING"))
+	if err != nil {
+		fmt.Println("Error occurred parsing MEMORY_SMOOTHING")
+		fmt.Println(err.Error())
+		os.Exit(1)
+	}
 
-		for range ticker.C {
-			metrics.ClearStaleMetrics()
+	if smoothingWindow := getenv("SMOOTHING_WINDOW"); smoothingWindow != "" {
+		metrics.SmoothingWindow, err = time.ParseDuration(smoothingWindow)
+		if err != nil {
+			fmt.Println("Error occurred parsing SMOOTHING_WINDOW")
+			fmt.Println(err.Error())
+			os.Exit(1)
 		}
-	}()
+	}
 
-	for msg := range msgChan {
-		if cellMemory == 0 {
-			match, _ := regexp.MatchString(".*diego[_-]cell.*CapacityTotalMemory.*", msg.String())
-			if match {
-				cellMemory = msg.ValueMetric.GetValue()
-				fmt.Printf("Setting the max memory to %v\n", cellMemory)
+	server := webs.CreateServer(metrics, &cellMemory, &watermark)
+	server.Controller.Now = now
+	server.Controller.PoolBy = getenv("POOL_BY")
+	server.Controller.PoolWatermarks, err = webs.ParsePoolWatermarks(getenv("POOL_WATERMARKS"))
+	if err != nil {
+		fmt.Println("Error occurred parsing POOL_WATERMARKS")
+		fmt.Println(err.Error())
+		os.Exit(1)
+	}
+	server.Controller.WatermarkRounding, err = watermarkLib.ParseRounding(getenv("WATERMARK_ROUNDING"))
+	if err != nil {
+		fmt.Println("Error occurred parsing WATERMARK_ROUNDING")
+		fmt.Println(err.Error())
+		os.Exit(1)
+	}
+	server.Controller.Schedule, err = schedule.Parse(getenv("PROFILES"))
+	if err != nil {
+		fmt.Println("Error occurred parsing PROFILES")
+		fmt.Println(err.Error())
+		os.Exit(1)
+	}
+	for env, threshold := range map[string]*float64{
+		"HEADROOM_WARNING_PERCENT":  &server.Controller.HeadroomWarningPercent,
+		"HEADROOM_CRITICAL_PERCENT": &server.Controller.HeadroomCriticalPercent,
+	} {
+		if value := getenv(env); value != "" {
+			*threshold, err = strconv.ParseFloat(value, 64)
+			if err != nil {
+				fmt.Printf("Error occurred parsing %s\n", env)
+				fmt.Println(err.Error())
+				os.Exit(1)
 			}
 		}
-
-		match, err := regexp.MatchString(".*diego[_-]cell.*CapacityRemainingMemory.*", msg.String())
+	}
+	instanceSizes := getenv("INSTANCE_SIZES")
+	if instanceSizes == "" {
+		instanceSizes = "256,1024,2048,4096,8192"
+	}
+	server.Controller.InstanceSizes, err = webs.ParseInstanceSizes(instanceSizes)
+	if err != nil {
+		fmt.Println("Error occurred parsing INSTANCE_SIZES")
+		fmt.Println(err.Error())
+		os.Exit(1)
+	}
+	if imbalanceLimit := getenv("IMBALANCE_LIMIT"); imbalanceLimit != "" {
+		server.Controller.ImbalanceLimit, err = strconv.ParseFloat(imbalanceLimit, 64)
+		if err != nil {
+			fmt.Println("Error occurred parsing IMBALANCE_LIMIT")
+			fmt.Println(err.Error())
+			os.Exit(1)
+		}
+	}
+	if missingCellThreshold := getenv("MISSING_CELL_THRESHOLD"); missingCellThreshold != "" {
+		server.Controller.MissingCellThreshold, err = strconv.Atoi(missingCellThreshold)
+		if err != nil {
+			fmt.Println("Error occurred parsing MISSING_CELL_THRESHOLD")
+			fmt.Println(err.Error())
+			os.Exit(1)
+		}
+	}
+	for env, setting := range map[string]*int{
+		"EXPECTED_CELL_COUNT": &server.Controller.ExpectedCellCount,
+		"QUIET_INTERVALS":     &server.Controller.QuietIntervals,
+	} {
+		if value := getenv(env); value != "" {
+			*setting, err = strconv.Atoi(value)
+			if err != nil {
+				fmt.Printf("Error occurred parsing %s\n", env)
+				fmt.Println(err.Error())
+				os.Exit(1)
+			}
+		}
+	}
+	if readinessTimeout := getenv("READINESS_TIMEOUT"); readinessTimeout != "" {
+		server.Controller.ReadinessTimeout, err = time.ParseDuration(readinessTimeout)
+		if err != nil {
+			fmt.Println("Error occurred parsing READINESS_TIMEOUT")
+			fmt.Println(err.Error())
+			os.Exit(1)
+		}
+	}
+	if hysteresisMargin := getenv("HYSTERESIS_MARGIN_PERCENT"); hysteresisMargin != "" {
+		server.Controller.HysteresisMarginPercent, err = strconv.ParseFloat(hysteresisMargin, 64)
+		if err != nil {
+			fmt.Println("Error occurred parsing HYSTERESIS_MARGIN_PERCENT")
+			fmt.Println(err.Error())
+			os.Exit(1)
+		}
+	}
+	if minimumStateDuration := getenv("MINIMUM_STATE_DURATION"); minimumStateDuration != "" {
+		server.Controller.MinimumStateDuration, err = time.ParseDuration(minimumStateDuration)
+		if err != nil {
+			fmt.Println("Error occurred parsing MINIMUM_STATE_DURATION")
+			fmt.Println(err.Error())
+			os.Exit(1)
+		}
+	}
+	containerUsage := containers.CreateUsage()
+	containerUsage.Now = now
+	server.Controller.Containers = containerUsage
+	if overcommitHeadroom := getenv("OVERCOMMIT_HEADROOM_PERCENT"); overcommitHeadroom != "" {
+		server.Controller.OvercommitHeadroomPercent, err = strconv.ParseFloat(overcommitHeadroom, 64)
+		if err != nil {
+			fmt.Println("Error occurred parsing OVERCOMMIT_HEADROOM_PERCENT")
+			fmt.Println(err.Error())
+			os.Exit(1)
+		}
+	}
+	vitalsStore := vitals.CreateStore()
+	vitalsStore.Now = now
+	server.Controller.Vitals = vitalsStore
+	server.Controller.PressureThresholds, err = vitals.ParseThresholds(getenv("PRESSURE_THRESHOLDS"))
+	if err != nil {
+		fmt.Println("Error occurred parsing PRESSURE_THRESHOLDS")
+		fmt.Println(err.Error())
+		os.Exit(1)
+	}
+	placementTracker := placement.CreateTracker()
+	placementTracker.Now = now
+	server.Controller.Placement = placementTracker
+	if placementFailureWindow := getenv("PLACEMENT_FAILURE_WINDOW"); placementFailureWindow != "" {
+		placementTracker.Window, err = time.ParseDuration(placementFailureWindow)
+		if err != nil {
+			fmt.Println("Error occurred parsing PLACEMENT_FAILURE_WINDOW")
+			fmt.Println(err.Error())
+			os.Exit(1)
+		}
+	}
+	if placementFailureThreshold := getenv("PLACEMENT_FAILURE_THRESHOLD"); placementFailureThreshold != "" {
+		server.Controller.PlacementFailureThreshold, err = strconv.Atoi(placementFailureThreshold)
 		if err != nil {
-			fmt.Println("An error occurred matching diego cells, skipping to next message")
+			fmt.Println("Error occurred parsing PLACEMENT_FAILURE_THRESHOLD")
 			fmt.Println(err.Error())
-			continue
+			os.Exit(1)
+		}
+	}
+	demandTracker := demand.CreateTracker()
+	demandTracker.Now = now
+	server.Controller.Demand = demandTracker
+	if demandTrendWindow := getenv("DEMAND_TREND_WINDOW"); demandTrendWindow != "" {
+		demandTracker.TrendWindow, err = time.ParseDuration(demandTrendWindow)
+		if err != nil {
+			fmt.Println("Error occurred parsing DEMAND_TREND_WINDOW")
+			fmt.Println(err.Error())
+			os.Exit(1)
+		}
+	}
+	var registry *bbs.Registry
+	if bbsURL := getenv("BBS_URL"); bbsURL != "" && !options.replaying {
+		bbsPollInterval := 30 * time.Second
+		if interval := getenv("BBS_POLL_INTERVAL"); interval != "" {
+			bbsPollInterval, err = time.ParseDuration(interval)
+			if err != nil {
+				fmt.Println("Error occurred parsing BBS_POLL_INTERVAL")
+				fmt.Println(err.Error())
+				os.Exit(1)
+			}
+		}
+		bbsClient, err := bbs.NewClient(bbs.Config{
+			URL:        bbsURL,
+			CACert:     getenv("BBS_CA_CERT"),
+			ClientCert: getenv("BBS_CLIENT_CERT"),
+			ClientKey:  getenv("BBS_CLIENT_KEY"),
+		})
+		if err != nil {
+			fmt.Println("Error occurred creating the BBS client")
+			fmt.Println(err.Error())
+			os.Exit(1)
+		}
+		registry = bbs.CreateRegistry(bbsClient)
+		server.Controller.Registry = registry
+		go pollRegistry(registry, bbsPollInterval)
+		if fetchInstances, _ := strconv.ParseBool(getenv("BBS_FETCH_INSTANCES")); fetchInstances {
+			instances := bbs.CreateInstances(bbsClient)
+			server.Controller.Instances = instances
+			go pollInstances(instances, bbsPollInterval)
+		}
+	}
+	var poller sources.Poller
+	pollInterval := 30 * time.Second
+	if interval := getenv("SOURCE_POLL_INTERVAL"); interval != "" {
+		pollInterval, err = time.ParseDuration(interval)
+		if err != nil {
+			fmt.Println("Error occurred parsing SOURCE_POLL_INTERVAL")
+			fmt.Println(err.Error())
+			os.Exit(1)
 		}
+	}
+	switch source := getenv("SOURCE"); source {
+	case "", "firehose":
+	case "rep":
+		if err = sources.ValidateRepPoolBy(server.Controller.PoolBy); err != nil {
+			fmt.Println("Error occurred parsing POOL_BY")
+			fmt.Println(err.Error())
+			os.Exit(1)
+		}
+		poller = newRepPoller(registry, getenv)
+	case "logcache":
+		poller = newLogCachePoller(getenv)
+	case "prometheus":
+		poller = newPrometheusPoller(getenv)
+	default:
+		fmt.Println("Error occurred parsing SOURCE")
+		fmt.Printf("source %q must be one of firehose, rep, logcache or prometheus\n", source)
+		os.Exit(1)
+	}
+	var recorder *recording.Recorder
+	var recordFilter recording.Filter
+	if !options.replaying {
+		recorder, recordFilter = newRecorder(getenv)
+	}
+	if recorder != nil && poller != nil {
+		fmt.Println("Error occurred creating the envelope recorder")
+		fmt.Println("envelopes can only be recorded from the firehose source")
+		os.Exit(1)
+	}
+	err = server.Controller.ValidateWatermarks()
+	if err != nil {
+		fmt.Println("Error occurred parsing the watermarks")
+		fmt.Println(err.Error())
+		os.Exit(1)
+	}
+
+	var clearStale sync.Once
+	ingest := func() error {
+		clearStale.Do(func() {
+			go func() {
+				ticker := time.NewTicker(metrics.StaleDuration)
+
+				for range ticker.C {
+					metrics.ClearStaleMetrics()
+				}
+			}()
+		})
+
+		if poller != nil {
+			fmt.Printf("===== Polling %s every %v\n", getenv("SOURCE"), pollInterval)
+			sources.Poll(poller, store, pollInterval)
+			return nil
+		}
+		handler := envelopeHandler{
+			store:            store,
+			containerUsage:   containerUsage,
+			placementTracker: placementTracker,
+			demandTracker:    demandTracker,
+			vitalsStore:      vitalsStore,
+		}
+		err := streamFirehose(handler, recorder, recordFilter, getenv, func() { server.Controller.SetSourceError(nil) })
+		server.Controller.SetSourceError(err)
+		return err
+	}
+	return server, ingest
+}
+
+// superviseIngest - ingests a foundation's source, logging why it stopped and ingesting it again with a growing
+// backoff so that one foundation's source failing does not stop the others
+func superviseIngest(name string, ingest func() error) {
+	backoff := time.Second
+	for {
+		started := time.Now()
+		err := ingest()
+		if time.Since(started) > maxIngestBackoff {
+			backoff = time.Second
+		}
+		fmt.Printf("Error occurred ingesting foundation %s, retrying in %v: %v\n", name, backoff, err)
+		time.Sleep(backoff)
+		if backoff *= 2; backoff > maxIngestBackoff {
+			backoff = maxIngestBackoff
+		}
+	}
+}
+
+// maxIngestBackoff - the longest wait before a foundation's source is ingested again
+const maxIngestBackoff = 5 * time.Minute
+
+// streamFirehose - records the cells' capacity and the other metrics the monitor tracks from the firehose, which
+// requires admin credentials, until the stream fails, connected is called when the first envelope is received
+func streamFirehose(handler envelopeHandler, recorder *recording.Recorder, recordFilter recording.Filter, getenv func(string) string, connected func()) error {
+	client, err := newCFClient(getenv)
+	if err != nil {
+		return fmt.Errorf("could not create the CF client: %v", err)
+	}
+
+	cnsmr := consumer.New(client.Endpoint.DopplerEndpoint, &tls.Config{InsecureSkipVerify: true}, nil)
+	cnsmr.SetDebugPrinter(consoleDebugPrinter{})
+	defer cnsmr.Close()
+
+	authToken, err := client.GetToken()
+	if err != nil {
+		return fmt.Errorf("could not grab the oauth token: %v", err)
+	}
+
+	fmt.Println("===== Streaming Firehose (will only succeed if you have admin credentials)")
+	firehoseSubscriptionID, err := newUUID()
+	if err != nil {
+		return fmt.Errorf("could not generate the subscription ID: %v", err)
+	}
+
+	msgChan, errorChan := cnsmr.FilteredFirehose(firehoseSubscriptionID, authToken, consumer.Metrics)
+	received := false
+	for {
+		select {
+		case err, ok := <-errorChan:
+			if !ok {
+				errorChan = nil
+				continue
+			}
+			return fmt.Errorf("the firehose stream failed: %v", err)
+		case msg, ok := <-msgChan:
+			if !ok {
+				return fmt.Errorf("the firehose stream was closed")
+			}
+			if !received {
+				received = true
+				connected()
+			}
+			if recorder != nil && recordFilter.Matches(msg) {
+				if err := recorder.Record(msg, time.Now()); err != nil {
+					fmt.Printf("Error occurred recording an envelope: %v\n", err)
+				}
+			}
+			handler.handle(msg)
+		}
+	}
+}
+
+// envelopeHandler - records the cells' capacity and the other metrics the monitor tracks from firehose envelopes,
+// whether they are streamed or replayed from a recording
+type envelopeHandler struct {
+	store            sources.Store
+	containerUsage   *containers.Usage
+	placementTracker *placement.Tracker
+	demandTracker    *demand.Tracker
+	vitalsStore      *vitals.Store
+	// quiet - stops the capacity of every cell being logged as it is recorded
+	quiet bool
+}
+
+var cellJob = regexp.MustCompile("diego[_-]cell")
+
+// handle - records the metric an envelope carries if it is one the monitor tracks
+func (h envelopeHandler) handle(msg *events.Envelope) {
+	if msg.GetEventType() == events.Envelope_ContainerMetric {
+		containerMetric := msg.GetContainerMetric()
+		h.containerUsage.Record(containers.Instance{
+			AppID:            containerMetric.GetApplicationId(),
+			InstanceIndex:    containerMetric.GetInstanceIndex(),
+			Cell:             msg.GetIndex(),
+			MemoryBytes:      containerMetric.GetMemoryBytes(),
+			MemoryBytesQuota: containerMetric.GetMemoryBytesQuota(),
+		})
+		return
+	}
+
+	if msg.GetEventType() == events.Envelope_CounterEvent {
+		h.placementTracker.RecordCounter(msg.GetCounterEvent().GetName(), msg.GetCounterEvent().GetDelta())
+		return
+	}
+
+	if msg.GetEventType() == events.Envelope_ValueMetric && h.placementTracker.RecordGauge(msg.GetValueMetric().GetName(), msg.GetValueMetric().GetValue()) {
+		return
+	}
+
+	if msg.GetEventType() == events.Envelope_ValueMetric && h.demandTracker.RecordGauge(msg.GetIndex(), msg.GetValueMetric().GetName(), msg.GetValueMetric().GetValue()) {
+		return
+	}
+
+	if msg.GetEventType() == events.Envelope_ValueMetric && strings.HasPrefix(msg.GetValueMetric().GetName(), "system.") {
+		if cellJob.MatchString(msg.GetJob()) {
+			h.vitalsStore.Record(msg.GetIndex(), msg.GetValueMetric().GetName(), msg.GetValueMetric().GetValue())
+		}
+		return
+	}
+
+	if *h.store.CellMemory == 0 {
+		match, _ := regexp.MatchString(".*diego[_-]cell.*CapacityTotalMemory.*", msg.String())
 		if match {
-			metrics.Set(*msg.Index, metricsLib.MessageMetric{Memory: msg.ValueMetric.GetValue(), Timestamp: *msg.Timestamp})
+			*h.store.CellMemory = msg.ValueMetric.GetValue()
+			fmt.Printf("Setting the max memory to %v\n", *h.store.CellMemory)
+		}
+	}
+
+	match, err := regexp.MatchString(".*diego[_-]cell.*CapacityRemainingMemory.*", msg.String())
+	if err != nil {
+		fmt.Println("An error occurred matching diego cells, skipping to next message")
+		fmt.Println(err.Error())
+		return
+	}
+	if match {
+		h.store.Record(sources.Sample{
+			Cell:            *msg.Index,
+			RemainingMemory: msg.ValueMetric.GetValue(),
+			Timestamp:       *msg.Timestamp,
+			Zone:            sources.ZoneFromTags(msg.GetTags()),
+			Deployment:      msg.GetDeployment(),
+			Job:             msg.GetJob(),
+			PlacementTags:   sources.PlacementTagsFromTags(msg.GetTags()),
+		})
+		if !h.quiet {
 			fmt.Printf("Index: %v, Value: %v, Timeout: %v\n", *msg.Index, msg.ValueMetric.GetValue(), *msg.Timestamp)
 		}
 	}
 }
 
+// newCFClient - creates a client of the CF API with the CF credentials
+func newCFClient(getenv func(string) string) (*cfclient.Client, error) {
+	c := &cfclient.Config{
+		ApiAddress:        getenv("CF_API_ENDPOINT"),
+		Username:          getenv("CF_USERNAME"),
+		Password:          getenv("CF_PASSWORD"),
+		SkipSslValidation: true,
+	}
+
+	return cfclient.NewClient(c)
+}
+
 type consoleDebugPrinter struct{}
 
 func (c consoleDebugPrinter) Print(title, dump string) {
@@ -132,3 +731,151 @@ func newUUID() (string, error) {
 	uuid[6] = uuid[6]&^0xf0 | 0x40
 	return fmt.Sprintf("%x-%x-%x-%x-%x", uuid[0:4], uuid[4:6], uuid[6:8], uuid[8:10], uuid[10:]), nil
 }
+
+// pollRegistry - fetches the cells registered with the BBS now and then at every interval, the controller takes
+// the cell memory from their capacity until the source reports it
+func pollRegistry(registry *bbs.Registry, interval time.Duration) {
+	for {
+		if err := registry.Refresh(); err != nil {
+			fmt.Printf("Error occurred fetching the cells from the BBS: %v\n", err)
+		}
+		time.Sleep(interval)
+	}
+}
+
+// pollInstances - fetches the LRP instances placed on the cells from the BBS now and then at every interval
+func pollInstances(instances *bbs.Instances, interval time.Duration) {
+	for {
+		if err := instances.Refresh(); err != nil {
+			fmt.Printf("Error occurred fetching the instances from the BBS: %v\n", err)
+		}
+		time.Sleep(interval)
+	}
+}
+
+// newRepPoller - creates a source that polls the /state of the reps listed in REP_ADDRESSES, or of the cells
+// registered with the BBS when none are listed
+func newRepPoller(registry *bbs.Registry, getenv func(string) string) sources.Poller {
+	var discovery sources.RepDiscovery
+	if addresses := getenv("REP_ADDRESSES"); addresses != "" {
+		discovery = sources.ParseStaticReps(addresses)
+	} else if registry != nil {
+		discovery = sources.RegistryReps{Registry: registry}
+	} else {
+		fmt.Println("Error occurred creating the rep source")
+		fmt.Println("either REP_ADDRESSES or BBS_URL must be set")
+		os.Exit(1)
+	}
+	config := sources.RepConfig{Config: mtls.Config{
+		CACert:     getenv("REP_CA_CERT"),
+		ClientCert: getenv("REP_CLIENT_CERT"),
+		ClientKey:  getenv("REP_CLIENT_KEY"),
+	}}
+	if concurrency := getenv("REP_CONCURRENCY"); concurrency != "" {
+		var err error
+		config.Concurrency, err = strconv.Atoi(concurrency)
+		if err != nil {
+			fmt.Println("Error occurred parsing REP_CONCURRENCY")
+			fmt.Println(err.Error())
+			os.Exit(1)
+		}
+	}
+	repSource, err := sources.NewRepSource(discovery, config)
+	if err != nil {
+		fmt.Println("Error occurred creating the rep source")
+		fmt.Println(err.Error())
+		os.Exit(1)
+	}
+	return repSource
+}
+
+// newLogCachePoller - creates a source that queries Log Cache for the rep gauges, authorized with the CF credentials,
+// Log Cache is found next to the CF API unless LOG_CACHE_URL is set
+func newLogCachePoller(getenv func(string) string) sources.Poller {
+	logCacheURL := getenv("LOG_CACHE_URL")
+	if logCacheURL == "" {
+		logCacheURL = strings.Replace(getenv("CF_API_ENDPOINT"), "://api.", "://log-cache.", 1)
+	}
+	client, err := newCFClient(getenv)
+	if err != nil {
+		fmt.Println("Error occurred creating the CF client")
+		fmt.Println(err.Error())
+		os.Exit(1)
+	}
+	logCacheSource, err := sources.NewLogCacheSource(sources.LogCacheConfig{
+		URL:               logCacheURL,
+		SourceID:          getenv("LOG_CACHE_SOURCE_ID"),
+		Token:             client.GetToken,
+		SkipSSLValidation: true,
+	})
+	if err != nil {
+		fmt.Println("Error occurred creating the Log Cache source")
+		fmt.Println(err.Error())
+		os.Exit(1)
+	}
+	return logCacheSource
+}
+
+// newPrometheusPoller - creates a source that scrapes the rep gauges from the Prometheus exposition at PROMETHEUS_URL
+func newPrometheusPoller(getenv func(string) string) sources.Poller {
+	skipSSLValidation, _ := strconv.ParseBool(getenv("PROMETHEUS_SKIP_SSL_VALIDATION"))
+	prometheusSource, err := sources.NewPrometheusSource(sources.PrometheusConfig{
+		URL:               getenv("PROMETHEUS_URL"),
+		Prefix:            getenv("PROMETHEUS_PREFIX"),
+		Username:          getenv("PROMETHEUS_USERNAME"),
+		Password:          getenv("PROMETHEUS_PASSWORD"),
+		SkipSSLValidation: skipSSLValidation,
+	})
+	if err != nil {
+		fmt.Println("Error occurred creating the Prometheus source")
+		fmt.Println(err.Error())
+		os.Exit(1)
+	}
+	return prometheusSource
+}
+
+// newRecorder - creates a recorder of the firehose envelopes when RECORD_DIRECTORY is set, the files are prefixed
+// with the Redis namespace when there is one so that several foundations can record to the same directory
+func newRecorder(getenv func(string) string) (*recording.Recorder, recording.Filter) {
+	directory := getenv("RECORD_DIRECTORY")
+	if directory == "" {
+		return nil, ""
+	}
+	config := recording.Config{Directory: directory, Prefix: getenv("REDIS_NAMESPACE"), MaxBytes: 100 * 1024 * 1024, MaxAge: time.Hour}
+	format, err := recording.ParseFormat(getenv("RECORD_FORMAT"))
+	if err != nil {
+		fmt.Println("Error occurred parsing RECORD_FORMAT")
+		fmt.Println(err.Error())
+		os.Exit(1)
+	}
+	config.Format = format
+	filter, err := recording.ParseFilter(getenv("RECORD_ENVELOPES"))
+	if err != nil {
+		fmt.Println("Error occurred parsing RECORD_ENVELOPES")
+		fmt.Println(err.Error())
+		os.Exit(1)
+	}
+	if maxBytes := getenv("RECORD_MAX_BYTES"); maxBytes != "" {
+		config.MaxBytes, err = strconv.ParseInt(maxBytes, 10, 64)
+		if err != nil {
+			fmt.Println("Error occurred parsing RECORD_MAX_BYTES")
+			fmt.Println(err.Error())
+			os.Exit(1)
+		}
+	}
+	if maxAge := getenv("RECORD_MAX_AGE"); maxAge != "" {
+		config.MaxAge, err = time.ParseDuration(maxAge)
+		if err != nil {
+			fmt.Println("Error occurred parsing RECORD_MAX_AGE")
+			fmt.Println(err.Error())
+			os.Exit(1)
+		}
+	}
+	recorder, err := recording.NewRecorder(config)
+	if err != nil {
+		fmt.Println("Error occurred creating the envelope recorder")
+		fmt.Println(err.Error())
+		os.Exit(1)
+	}
+	return recorder, filter
+}
@@ -0,0 +1,93 @@
+package main
+
+import (
+	"fmt"
+	"github.com/FidelityInternational/diego-capacity-monitor/recording"
+	"github.com/cloudfoundry/sonde-go/events"
+	"github.com/gogo/protobuf/proto"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"time"
+)
+
+var _ = Describe("replayRecording", func() {
+	var (
+		dir              string
+		redisListener    net.Listener
+		redisConnections int32
+		bbsServer        *httptest.Server
+		bbsRequests      int32
+	)
+
+	BeforeEach(func() {
+		var err error
+		dir, err = ioutil.TempDir("", "replay")
+		Ω(err).Should(BeNil())
+		recorder, err := recording.NewRecorder(recording.Config{Directory: filepath.Join(dir, "envelopes"), Format: recording.FormatNDJSON})
+		Ω(err).Should(BeNil())
+		start := time.Date(2026, 10, 19, 10, 0, 0, 0, time.UTC)
+		for _, index := range []string{"1", "2", "3"} {
+			Ω(recorder.Record(&events.Envelope{
+				Origin:      proto.String("rep"),
+				EventType:   events.Envelope_ValueMetric.Enum(),
+				Timestamp:   proto.Int64(start.UnixNano()),
+				Job:         proto.String("diego_cell"),
+				Index:       proto.String(index),
+				ValueMetric: &events.ValueMetric{Name: proto.String("CapacityRemainingMemory"), Value: proto.Float64(8000), Unit: proto.String("MiB")},
+			}, start)).Should(Succeed())
+		}
+		Ω(recorder.Close()).Should(Succeed())
+
+		atomic.StoreInt32(&redisConnections, 0)
+		redisListener, err = net.Listen("tcp", "127.0.0.1:0")
+		Ω(err).Should(BeNil())
+		go func() {
+			for {
+				conn, err := redisListener.Accept()
+				if err != nil {
+					return
+				}
+				atomic.AddInt32(&redisConnections, 1)
+				conn.Close()
+			}
+		}()
+		atomic.StoreInt32(&bbsRequests, 0)
+		bbsServer = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			atomic.AddInt32(&bbsRequests, 1)
+			w.WriteHeader(http.StatusInternalServerError)
+		}))
+
+		os.Setenv("VCAP_SERVICES", fmt.Sprintf(`{"p-redis": [{"credentials": {"host": "127.0.0.1", "password": "", "port": %d}, "label": "p-redis", "name": "redis", "tags": ["redis"]}]}`,
+			redisListener.Addr().(*net.TCPAddr).Port))
+		os.Setenv("VCAP_APPLICATION", "{}")
+		os.Setenv("BBS_URL", bbsServer.URL)
+		os.Setenv("BBS_FETCH_INSTANCES", "true")
+		os.Setenv("BBS_POLL_INTERVAL", "10ms")
+		os.Setenv("REPLAY_TIMELINE", filepath.Join(dir, "timeline.txt"))
+	})
+
+	AfterEach(func() {
+		for _, env := range []string{"VCAP_SERVICES", "VCAP_APPLICATION", "BBS_URL", "BBS_FETCH_INSTANCES", "BBS_POLL_INTERVAL", "REPLAY_TIMELINE"} {
+			os.Unsetenv(env)
+		}
+		redisListener.Close()
+		bbsServer.Close()
+		os.RemoveAll(dir)
+	})
+
+	It("replays the recording without touching a bound Redis or the BBS", func() {
+		replayRecording(filepath.Join(dir, "envelopes"))
+		timeline, err := ioutil.ReadFile(filepath.Join(dir, "timeline.txt"))
+		Ω(err).Should(BeNil())
+		Ω(string(timeline)).Should(HavePrefix("Replayed 3 envelopes from 2026-10-19T10:00:00Z to 2026-10-19T10:00:00Z\n"))
+		Consistently(func() int32 { return atomic.LoadInt32(&bbsRequests) }, 200*time.Millisecond).Should(BeZero())
+		Ω(atomic.LoadInt32(&redisConnections)).Should(BeZero())
+	})
+})
@@ -5,32 +5,143 @@ import (
 	"fmt"
 	"github.com/cloudfoundry-community/go-cfenv"
 	"gopkg.in/redis.v5"
+	"math"
+	"regexp"
+	"strings"
 	"time"
 )
 
+// Smoothing - how a cell's free memory is smoothed over a window before its health is evaluated
+type Smoothing string
+
+// Supported smoothing modes
+const (
+	// SmoothingNone - the latest free memory is used
+	SmoothingNone Smoothing = "none"
+	// SmoothingEWMA - an exponentially weighted moving average of the free memory with the window as its time constant
+	SmoothingEWMA Smoothing = "ewma"
+	// SmoothingMin - the lowest free memory seen within the window, a pessimistic view of the cell
+	SmoothingMin Smoothing = "min"
+)
+
+// ParseSmoothing - parses a smoothing mode, defaulting to none when none is supplied
+func ParseSmoothing(smoothing string) (Smoothing, error) {
+	switch Smoothing(strings.ToLower(strings.TrimSpace(smoothing))) {
+	case "", SmoothingNone:
+		return SmoothingNone, nil
+	case SmoothingEWMA:
+		return SmoothingEWMA, nil
+	case SmoothingMin:
+		return SmoothingMin, nil
+	}
+	return "", fmt.Errorf("smoothing %q must be one of none, ewma or min", smoothing)
+}
+
+// Sample - a free memory value and when it was received
+type Sample struct {
+	Memory     float64 `json:"memory"`
+	ReceivedAt int64   `json:"received_at"`
+}
+
+// Resources - the disk in MB and the containers a cell has left and offers
+type Resources struct {
+	RemainingDisk       float64 `json:"remaining_disk_mb"`
+	TotalDisk           float64 `json:"total_disk_mb"`
+	RemainingContainers float64 `json:"remaining_containers"`
+	TotalContainers     float64 `json:"total_containers"`
+}
+
 // MessageMetric - A struct of the firhose metrics we care about
 type MessageMetric struct {
-	Memory    float64 `json: "memory"`
-	Timestamp int64   `json: "timestamp"`
+	Memory        float64  `json:"memory"`
+	Timestamp     int64    `json:"timestamp"`
+	Zone          string   `json:"zone,omitempty"`
+	Deployment    string   `json:"deployment,omitempty"`
+	Job           string   `json:"job,omitempty"`
+	PlacementTags []string `json:"placement_tags,omitempty"`
+	// ReceivedAt - when the monitor received the metric, so that staleness is not affected by the cell's clock
+	ReceivedAt int64 `json:"received_at,omitempty"`
+	// Interval - the learned interval in nanoseconds between the metrics a cell emits
+	Interval int64 `json:"interval,omitempty"`
+	// FirstSeen - when the monitor first received a metric from the cell
+	FirstSeen int64 `json:"first_seen,omitempty"`
+	// SmoothedMemory - the free memory smoothed over the smoothing window, nil when smoothing is not used
+	SmoothedMemory *float64 `json:"smoothed_memory,omitempty"`
+	// Samples - the free memory received within the smoothing window, kept for min smoothing
+	Samples []Sample `json:"samples,omitempty"`
+	// Resources - the cell's disk and containers, when its source reports them
+	Resources *Resources `json:"resources,omitempty"`
 }
 
 // Metrics struct
 type Metrics struct {
 	MessageMetrics map[string]MessageMetric
 	StaleDuration  time.Duration
-	RedisClient    *redis.Client
+	// RetentionDuration - how long a stale metric is remembered before it is deleted, never less than StaleDuration
+	RetentionDuration time.Duration
+	// MissedIntervals - the number of emission intervals a cell can miss before its metric is stale
+	MissedIntervals int
+	// Smoothing - how each cell's free memory is smoothed, see Smoothing
+	Smoothing Smoothing
+	// SmoothingWindow - the window the free memory is smoothed over
+	SmoothingWindow time.Duration
+	// Namespace - separates the Redis keys of the foundations sharing a Redis, see ValidateNamespace
+	Namespace string
+	// Now - returns the current time, time.Now when it is nil, so that recorded metrics can be replayed
+	Now         func() time.Time
+	RedisClient *redis.Client
+}
+
+// now - returns the current time from Now
+func (m *Metrics) now() time.Time {
+	if m.Now == nil {
+		return time.Now()
+	}
+	return m.Now()
 }
 
-// CreateMetrics - creates the "Metrics" control object
+// CreateMetrics - creates the "Metrics" control object, kept in Redis when a Redis service is bound
 func CreateMetrics() Metrics {
-	staleDuration := (15 * time.Minute)
 	redisService, redisExists := redisServiceAvailable()
 	if redisExists {
-		redisClient, _ := createRedisClient(redisService)
-		return Metrics{RedisClient: redisClient, StaleDuration: staleDuration}
+		metrics := CreateMemoryMetrics()
+		metrics.MessageMetrics = nil
+		metrics.RedisClient, _ = createRedisClient(redisService)
+		return metrics
 	}
-	messageMetrics := make(map[string]MessageMetric)
-	return Metrics{MessageMetrics: messageMetrics, StaleDuration: staleDuration}
+	return CreateMemoryMetrics()
+}
+
+// CreateMemoryMetrics - creates the "Metrics" control object kept in memory, even when a Redis service is bound
+func CreateMemoryMetrics() Metrics {
+	return Metrics{
+		MessageMetrics:    make(map[string]MessageMetric),
+		StaleDuration:     15 * time.Minute,
+		RetentionDuration: 24 * time.Hour,
+		MissedIntervals:   3,
+		Smoothing:         SmoothingNone,
+		SmoothingWindow:   5 * time.Minute,
+	}
+}
+
+// keyPrefix - prefixes every Redis key the metrics are kept under, followed by the namespace, which is empty when
+// there is none, and the index
+const keyPrefix = "cell:"
+
+var namespacePattern = regexp.MustCompile(`^[A-Za-z0-9._-]*$`)
+
+// ValidateNamespace - returns an error unless the namespace only contains letters, digits, '.', '_' or '-', so that
+// it can neither run into the index nor be read as part of a Redis pattern
+func ValidateNamespace(namespace string) error {
+	if !namespacePattern.MatchString(namespace) {
+		return fmt.Errorf("namespace %q must only contain letters, digits, '.', '_' or '-'", namespace)
+	}
+	return nil
+}
+
+// key - returns the Redis key of the index within the namespace
+func (m *Metrics) key(index string) string {
+	return keyPrefix + m.Namespace + ":" + index
 }
 
 // GetAll - Gets all current metrics
@@ -40,9 +151,9 @@ func (m *Metrics) GetAll() map[string]MessageMetric {
 	}
 
 	messageMetrics := make(map[string]MessageMetric)
-	allKeys := m.RedisClient.Keys("*").Val()
+	allKeys := m.RedisClient.Keys(m.key("*")).Val()
 	for _, key := range allKeys {
-		messageMetrics[key] = m.redisGet(key)
+		messageMetrics[strings.TrimPrefix(key, m.key(""))] = m.redisGet(key)
 	}
 	return messageMetrics
 }
@@ -53,7 +164,7 @@ func (m *Metrics) Delete(index string) {
 		delete(m.MessageMetrics, index)
 		return
 	}
-	m.RedisClient.Del(index)
+	m.RedisClient.Del(m.key(index))
 }
 
 // Set - sets the message metrics for the given index
@@ -63,24 +174,137 @@ func (m *Metrics) Set(index string, value MessageMetric) {
 		return
 	}
 	byteValue, _ := json.Marshal(value)
-	m.RedisClient.Set(index, string(byteValue), 0)
+	m.RedisClient.Set(m.key(index), string(byteValue), 0)
 }
 
-// IsMetricStale - returns a bool based on the staleness of a metric
-func (m *Metrics) IsMetricStale(index string) bool {
-	var messageTimestamp int64
+// Get - gets the metric at the specified index
+func (m *Metrics) Get(index string) (MessageMetric, bool) {
 	if m.RedisNotUsed() {
-		messageTimestamp = m.MessageMetrics[index].Timestamp
-	} else {
-		messageTimestamp = m.redisGet(index).Timestamp
+		messageMetric, ok := m.MessageMetrics[index]
+		return messageMetric, ok
+	}
+	if m.RedisClient.Exists(m.key(index)).Val() {
+		return m.redisGet(m.key(index)), true
+	}
+	return MessageMetric{}, false
+}
+
+// IsMetricStale - returns a bool based on the staleness of a metric. Once a cell's emission interval has been
+// learned its metric is stale after MissedIntervals intervals without a new metric, it is always stale after
+// StaleDuration.
+func (m *Metrics) IsMetricStale(index string) bool {
+	messageMetric, _ := m.Get(index)
+	if messageMetric.Interval > 0 && m.MissedIntervals > 0 {
+		missedDuration := time.Duration(messageMetric.Interval * int64(m.MissedIntervals))
+		if missedDuration < m.StaleDuration {
+			return m.now().After(messageMetric.LastSeen().Add(missedDuration))
+		}
+	}
+	return m.IsMetricMissing(index)
+}
+
+// IsMetricMissing - returns a bool for if a metric is older than StaleDuration
+func (m *Metrics) IsMetricMissing(index string) bool {
+	return m.isOlderThan(index, m.StaleDuration)
+}
+
+// IsMetricExpired - returns a bool for if a metric is older than the retention duration
+func (m *Metrics) IsMetricExpired(index string) bool {
+	retentionDuration := m.RetentionDuration
+	if retentionDuration < m.StaleDuration {
+		retentionDuration = m.StaleDuration
+	}
+	return m.isOlderThan(index, retentionDuration)
+}
+
+func (m *Metrics) isOlderThan(index string, duration time.Duration) bool {
+	messageMetric, _ := m.Get(index)
+	return m.now().After(messageMetric.LastSeen().Add(duration))
+}
+
+// LastSeen - returns when the metric was received, or the cell's timestamp for metrics without a receipt time
+func (mm MessageMetric) LastSeen() time.Time {
+	if mm.ReceivedAt > 0 {
+		return time.Unix(0, mm.ReceivedAt)
+	}
+	return time.Unix(0, mm.Timestamp)
+}
+
+// ClockSkew - returns how far the cell's clock was behind the monitor's when the metric was received
+func (mm MessageMetric) ClockSkew() time.Duration {
+	if mm.ReceivedAt == 0 {
+		return 0
+	}
+	return time.Duration(mm.ReceivedAt - mm.Timestamp)
+}
+
+// EffectiveMemory - returns the free memory the cell's health is evaluated with, the smoothed memory when there is one
+func (mm MessageMetric) EffectiveMemory() float64 {
+	if mm.SmoothedMemory != nil {
+		return *mm.SmoothedMemory
+	}
+	return mm.Memory
+}
+
+// Record - sets the metric for the given index, stamping it with the time it was received and learning the
+// interval between the cell's metrics as a moving average of the gaps between them
+func (m *Metrics) Record(index string, value MessageMetric) {
+	if value.ReceivedAt == 0 {
+		value.ReceivedAt = m.now().UnixNano()
+	}
+	value.FirstSeen = value.ReceivedAt
+	previous, ok := m.Get(index)
+	if ok && previous.FirstSeen > 0 {
+		value.FirstSeen = previous.FirstSeen
+	}
+	if ok && previous.ReceivedAt > 0 && value.ReceivedAt > previous.ReceivedAt {
+		gap := value.ReceivedAt - previous.ReceivedAt
+		if previous.Interval == 0 {
+			value.Interval = gap
+		} else {
+			value.Interval = previous.Interval + (gap-previous.Interval)/4
+		}
+	} else if ok {
+		value.Interval = previous.Interval
+	}
+	m.smooth(&value, previous, ok)
+	m.Set(index, value)
+}
+
+// smooth - sets the smoothed memory of a newly received metric from the metric it replaces
+func (m *Metrics) smooth(value *MessageMetric, previous MessageMetric, hasPrevious bool) {
+	if m.SmoothingWindow <= 0 {
+		return
+	}
+	smoothed := value.Memory
+	switch m.Smoothing {
+	case SmoothingEWMA:
+		if hasPrevious && previous.SmoothedMemory != nil && value.ReceivedAt > previous.ReceivedAt {
+			// weight the new value by the time since the last one so that irregular intervals are smoothed evenly
+			gap := float64(value.ReceivedAt - previous.ReceivedAt)
+			alpha := 1 - math.Exp(-gap/float64(m.SmoothingWindow))
+			smoothed = *previous.SmoothedMemory + alpha*(value.Memory-*previous.SmoothedMemory)
+		}
+	case SmoothingMin:
+		windowStart := value.ReceivedAt - int64(m.SmoothingWindow)
+		for _, sample := range previous.Samples {
+			if sample.ReceivedAt > windowStart && sample.ReceivedAt <= value.ReceivedAt {
+				value.Samples = append(value.Samples, sample)
+				smoothed = math.Min(smoothed, sample.Memory)
+			}
+		}
+		value.Samples = append(value.Samples, Sample{Memory: value.Memory, ReceivedAt: value.ReceivedAt})
+	default:
+		return
 	}
-	return time.Now().After(time.Unix(0, messageTimestamp).Add(m.StaleDuration))
+	value.SmoothedMemory = &smoothed
 }
 
-// ClearStaleMetrics - Deletes any metrics that are stale
+// ClearStaleMetrics - Deletes any metrics that have expired, stale metrics are kept until then so that
+// cells which stop reporting can be reported as missing
 func (m *Metrics) ClearStaleMetrics() {
 	for index := range m.GetAll() {
-		if m.IsMetricStale(index) {
+		if m.IsMetricExpired(index) {
 			m.Delete(index)
 		}
 	}
@@ -93,7 +317,7 @@ func (m *Metrics) redisGet(key string) MessageMetric {
 	return messageMetric
 }
 
-//RedisNotUsed - returns a bool for if redis is in use or not
+// RedisNotUsed - returns a bool for if redis is in use or not
 func (m *Metrics) RedisNotUsed() bool {
 	return (m.RedisClient == nil)
 }
@@ -7,6 +7,7 @@ import (
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
 	"gopkg.in/redis.v5"
+	"math"
 	"os"
 	"os/exec"
 	"time"
@@ -180,6 +181,38 @@ var _ = Describe("#CreateMetrics", func() {
 	})
 })
 
+var _ = Describe("#ValidateNamespace", func() {
+	It("allows letters, digits, '.', '_' and '-', or no namespace", func() {
+		Ω(metricsLib.ValidateNamespace("")).Should(Succeed())
+		Ω(metricsLib.ValidateNamespace("cf-west_2.prod")).Should(Succeed())
+	})
+
+	It("rejects a namespace that could run into the index or a Redis pattern", func() {
+		Ω(metricsLib.ValidateNamespace("east:1")).Should(MatchError(`namespace "east:1" must only contain letters, digits, '.', '_' or '-'`))
+		Ω(metricsLib.ValidateNamespace("east*")).Should(HaveOccurred())
+	})
+})
+
+var _ = Describe("#CreateMemoryMetrics", func() {
+	BeforeEach(func() {
+		os.Setenv("VCAP_SERVICES", `{"p-redis": [{"credentials": {"host": "127.0.0.1", "password": "", "port": 1}, "label": "p-redis", "name": "redis", "tags": ["redis"]}]}`)
+		os.Setenv("VCAP_APPLICATION", "{}")
+	})
+
+	AfterEach(func() {
+		os.Unsetenv("VCAP_SERVICES")
+		os.Unsetenv("VCAP_APPLICATION")
+	})
+
+	It("keeps the metrics in memory even though a redis service is bound", func() {
+		metrics := metricsLib.CreateMemoryMetrics()
+		Ω(metrics.RedisClient).Should(BeNil())
+		Ω(metrics.RedisNotUsed()).Should(BeTrue())
+		Ω(metrics.MessageMetrics).ShouldNot(BeNil())
+		Ω(metrics.StaleDuration).Should(Equal(15 * time.Minute))
+	})
+})
+
 var _ = Describe("Metrics", func() {
 	var metrics metricsLib.Metrics
 
@@ -237,13 +270,13 @@ var _ = Describe("Metrics", func() {
 
 			Context("when there are metrics", func() {
 				BeforeEach(func() {
-					output, err := exec.Command("redis-cli", "-p", fmt.Sprintf("%v", redisPort), "set", "1", metric1String).Output()
+					output, err := exec.Command("redis-cli", "-p", fmt.Sprintf("%v", redisPort), "set", "cell::1", metric1String).Output()
 					Ω(err).To(BeNil())
 					Ω(string(output)).Should(Equal("OK\n"))
-					output, err = exec.Command("redis-cli", "-p", fmt.Sprintf("%v", redisPort), "set", "2", metric2String).Output()
+					output, err = exec.Command("redis-cli", "-p", fmt.Sprintf("%v", redisPort), "set", "cell::2", metric2String).Output()
 					Ω(err).To(BeNil())
 					Ω(string(output)).Should(Equal("OK\n"))
-					output, err = exec.Command("redis-cli", "-p", fmt.Sprintf("%v", redisPort), "set", "3", metric3String).Output()
+					output, err = exec.Command("redis-cli", "-p", fmt.Sprintf("%v", redisPort), "set", "cell::3", metric3String).Output()
 					Ω(err).To(BeNil())
 					Ω(string(output)).Should(Equal("OK\n"))
 				})
@@ -257,6 +290,40 @@ var _ = Describe("Metrics", func() {
 					Ω(allMetrics["3"]).Should(Equal(metricsLib.MessageMetric{Memory: 3000, Timestamp: timeNow}))
 				})
 			})
+
+			Context("when the metrics are namespaced", func() {
+				BeforeEach(func() {
+					output, err := exec.Command("redis-cli", "-p", fmt.Sprintf("%v", redisPort), "set", "cell:east:1", metric1String).Output()
+					Ω(err).To(BeNil())
+					Ω(string(output)).Should(Equal("OK\n"))
+					output, err = exec.Command("redis-cli", "-p", fmt.Sprintf("%v", redisPort), "set", "cell:west:2", metric2String).Output()
+					Ω(err).To(BeNil())
+					Ω(string(output)).Should(Equal("OK\n"))
+					output, err = exec.Command("redis-cli", "-p", fmt.Sprintf("%v", redisPort), "set", "cell:east-west:4", metric3String).Output()
+					Ω(err).To(BeNil())
+					Ω(string(output)).Should(Equal("OK\n"))
+					output, err = exec.Command("redis-cli", "-p", fmt.Sprintf("%v", redisPort), "set", "cell::5:0", metric3String).Output()
+					Ω(err).To(BeNil())
+					Ω(string(output)).Should(Equal("OK\n"))
+				})
+
+				It("returns only the metrics without a namespace when there is no namespace, keeping colons in the index", func() {
+					allMetrics := metrics.GetAll()
+					Ω(allMetrics).Should(HaveLen(1))
+					Ω(allMetrics["5:0"]).Should(Equal(metricsLib.MessageMetric{Memory: 3000, Timestamp: timeNow}))
+				})
+
+				It("returns only the metrics in the namespace, without the prefix", func() {
+					metrics.Namespace = "east"
+					allMetrics := metrics.GetAll()
+					Ω(allMetrics).Should(HaveLen(1))
+					Ω(allMetrics["1"]).Should(Equal(metricsLib.MessageMetric{Memory: 4000, Timestamp: 200}))
+					metrics.Set("3", metricsLib.MessageMetric{Memory: 3000, Timestamp: 300})
+					output, err := exec.Command("redis-cli", "-p", fmt.Sprintf("%v", redisPort), "exists", "cell:east:3").Output()
+					Ω(err).To(BeNil())
+					Ω(string(output)).Should(Equal("1\n"))
+				})
+			})
 		})
 
 		Context("when redis is not used", func() {
@@ -353,13 +420,13 @@ var _ = Describe("Metrics", func() {
 
 			Context("when there are metrics", func() {
 				BeforeEach(func() {
-					output, err := exec.Command("redis-cli", "-p", fmt.Sprintf("%v", redisPort), "set", "1", metric1String).Output()
+					output, err := exec.Command("redis-cli", "-p", fmt.Sprintf("%v", redisPort), "set", "cell::1", metric1String).Output()
 					Ω(err).To(BeNil())
 					Ω(string(output)).Should(Equal("OK\n"))
-					output, err = exec.Command("redis-cli", "-p", fmt.Sprintf("%v", redisPort), "set", "2", metric2String).Output()
+					output, err = exec.Command("redis-cli", "-p", fmt.Sprintf("%v", redisPort), "set", "cell::2", metric2String).Output()
 					Ω(err).To(BeNil())
 					Ω(string(output)).Should(Equal("OK\n"))
-					output, err = exec.Command("redis-cli", "-p", fmt.Sprintf("%v", redisPort), "set", "3", metric3String).Output()
+					output, err = exec.Command("redis-cli", "-p", fmt.Sprintf("%v", redisPort), "set", "cell::3", metric3String).Output()
 					Ω(err).To(BeNil())
 					Ω(string(output)).Should(Equal("OK\n"))
 				})
@@ -470,13 +537,13 @@ var _ = Describe("Metrics", func() {
 
 			Context("when there are metrics", func() {
 				BeforeEach(func() {
-					output, err := exec.Command("redis-cli", "-p", fmt.Sprintf("%v", redisPort), "set", "1", metric1String).Output()
+					output, err := exec.Command("redis-cli", "-p", fmt.Sprintf("%v", redisPort), "set", "cell::1", metric1String).Output()
 					Ω(err).To(BeNil())
 					Ω(string(output)).Should(Equal("OK\n"))
-					output, err = exec.Command("redis-cli", "-p", fmt.Sprintf("%v", redisPort), "set", "2", metric2String).Output()
+					output, err = exec.Command("redis-cli", "-p", fmt.Sprintf("%v", redisPort), "set", "cell::2", metric2String).Output()
 					Ω(err).To(BeNil())
 					Ω(string(output)).Should(Equal("OK\n"))
-					output, err = exec.Command("redis-cli", "-p", fmt.Sprintf("%v", redisPort), "set", "3", metric3String).Output()
+					output, err = exec.Command("redis-cli", "-p", fmt.Sprintf("%v", redisPort), "set", "cell::3", metric3String).Output()
 					Ω(err).To(BeNil())
 					Ω(string(output)).Should(Equal("OK\n"))
 				})
@@ -574,13 +641,13 @@ var _ = Describe("Metrics", func() {
 
 			Context("when there are metrics", func() {
 				BeforeEach(func() {
-					output, err := exec.Command("redis-cli", "-p", fmt.Sprintf("%v", redisPort), "set", "1", metric1String).Output()
+					output, err := exec.Command("redis-cli", "-p", fmt.Sprintf("%v", redisPort), "set", "cell::1", metric1String).Output()
 					Ω(err).To(BeNil())
 					Ω(string(output)).Should(Equal("OK\n"))
-					output, err = exec.Command("redis-cli", "-p", fmt.Sprintf("%v", redisPort), "set", "2", metric2String).Output()
+					output, err = exec.Command("redis-cli", "-p", fmt.Sprintf("%v", redisPort), "set", "cell::2", metric2String).Output()
 					Ω(err).To(BeNil())
 					Ω(string(output)).Should(Equal("OK\n"))
-					output, err = exec.Command("redis-cli", "-p", fmt.Sprintf("%v", redisPort), "set", "3", metric3String).Output()
+					output, err = exec.Command("redis-cli", "-p", fmt.Sprintf("%v", redisPort), "set", "cell::3", metric3String).Output()
 					Ω(err).To(BeNil())
 					Ω(string(output)).Should(Equal("OK\n"))
 				})
@@ -613,7 +680,170 @@ var _ = Describe("Metrics", func() {
 		})
 	})
 
+	Describe("#Record", func() {
+		var now = time.Now()
+
+		BeforeEach(func() {
+			metrics = metricsLib.CreateMetrics()
+		})
+
+		It("stamps the metric with the time it was received", func() {
+			metrics.Record("1", metricsLib.MessageMetric{Memory: 4000, Timestamp: 200})
+			recorded, ok := metrics.Get("1")
+			Ω(ok).Should(BeTrue())
+			Ω(recorded.ReceivedAt).Should(BeNumerically(">=", now.UnixNano()))
+			Ω(recorded.Interval).Should(BeZero())
+		})
+
+		It("learns the interval between the cell's metrics", func() {
+			metrics.Record("1", metricsLib.MessageMetric{Memory: 4000, ReceivedAt: now.Add(-90 * time.Second).UnixNano()})
+			metrics.Record("1", metricsLib.MessageMetric{Memory: 4000, ReceivedAt: now.Add(-60 * time.Second).UnixNano()})
+			recorded, _ := metrics.Get("1")
+			Ω(time.Duration(recorded.Interval)).Should(Equal(30 * time.Second))
+
+			metrics.Record("1", metricsLib.MessageMetric{Memory: 4000, ReceivedAt: now.Add(-10 * time.Second).UnixNano()})
+			recorded, _ = metrics.Get("1")
+			Ω(time.Duration(recorded.Interval)).Should(Equal(35 * time.Second))
+		})
+
+		It("does not smooth the memory by default", func() {
+			metrics.Record("1", metricsLib.MessageMetric{Memory: 4000})
+			recorded, _ := metrics.Get("1")
+			Ω(recorded.SmoothedMemory).Should(BeNil())
+			Ω(recorded.EffectiveMemory()).Should(Equal(4000.0))
+		})
+
+		Context("when ewma smoothing is used", func() {
+			BeforeEach(func() {
+				metrics.Smoothing = metricsLib.SmoothingEWMA
+				metrics.SmoothingWindow = time.Minute
+			})
+
+			It("moves the smoothed memory towards each new value by the time since the last one", func() {
+				metrics.Record("1", metricsLib.MessageMetric{Memory: 4000, ReceivedAt: now.Add(-time.Minute).UnixNano()})
+				recorded, _ := metrics.Get("1")
+				Ω(recorded.EffectiveMemory()).Should(Equal(4000.0))
+
+				metrics.Record("1", metricsLib.MessageMetric{Memory: 1000, ReceivedAt: now.UnixNano()})
+				recorded, _ = metrics.Get("1")
+				Ω(recorded.Memory).Should(Equal(1000.0))
+				Ω(recorded.EffectiveMemory()).Should(BeNumerically("~", 4000-3000*(1-math.Exp(-1)), 0.01))
+			})
+		})
+
+		Context("when min smoothing is used", func() {
+			BeforeEach(func() {
+				metrics.Smoothing = metricsLib.SmoothingMin
+				metrics.SmoothingWindow = time.Minute
+			})
+
+			It("uses the lowest memory received within the window", func() {
+				metrics.Record("1", metricsLib.MessageMetric{Memory: 1000, ReceivedAt: now.Add(-80 * time.Second).UnixNano()})
+				metrics.Record("1", metricsLib.MessageMetric{Memory: 2000, ReceivedAt: now.Add(-30 * time.Second).UnixNano()})
+				recorded, _ := metrics.Get("1")
+				Ω(recorded.EffectiveMemory()).Should(Equal(1000.0))
+
+				metrics.Record("1", metricsLib.MessageMetric{Memory: 5000, ReceivedAt: now.UnixNano()})
+				recorded, _ = metrics.Get("1")
+				Ω(recorded.Memory).Should(Equal(5000.0))
+				Ω(recorded.EffectiveMemory()).Should(Equal(2000.0))
+				Ω(recorded.Samples).Should(HaveLen(2))
+			})
+		})
+	})
+
+	Describe("#ParseSmoothing", func() {
+		It("defaults to none", func() {
+			smoothing, err := metricsLib.ParseSmoothing("")
+			Ω(err).Should(BeNil())
+			Ω(smoothing).Should(Equal(metricsLib.SmoothingNone))
+		})
+
+		It("parses ewma and min", func() {
+			smoothing, err := metricsLib.ParseSmoothing("EWMA")
+			Ω(err).Should(BeNil())
+			Ω(smoothing).Should(Equal(metricsLib.SmoothingEWMA))
+			smoothing, err = metricsLib.ParseSmoothing("min")
+			Ω(err).Should(BeNil())
+			Ω(smoothing).Should(Equal(metricsLib.SmoothingMin))
+		})
+
+		It("returns an error for an unknown mode", func() {
+			_, err := metricsLib.ParseSmoothing("max")
+			Ω(err).Should(MatchError(`smoothing "max" must be one of none, ewma or min`))
+		})
+	})
+
+	Describe("#IsMetricStale", func() {
+		Context("when the cell's emission interval has been learned", func() {
+			var now = time.Now()
+
+			BeforeEach(func() {
+				metrics = createPopulatedMetricsObj()
+				metrics.MissedIntervals = 3
+				metrics.Set("4", metricsLib.MessageMetric{Memory: 3000, Timestamp: now.UnixNano(),
+					ReceivedAt: now.Add(-2 * time.Minute).UnixNano(), Interval: int64(30 * time.Second)})
+				metrics.Set("5", metricsLib.MessageMetric{Memory: 3000, Timestamp: now.Add(-10 * time.Minute).UnixNano(),
+					ReceivedAt: now.Add(-30 * time.Second).UnixNano(), Interval: int64(30 * time.Second)})
+			})
+
+			It("is stale after the cell misses the configured number of intervals", func() {
+				Ω(metrics.IsMetricStale("4")).Should(BeTrue())
+				Ω(metrics.IsMetricMissing("4")).Should(BeFalse())
+			})
+
+			It("uses the time the metric was received rather than the cell's timestamp", func() {
+				Ω(metrics.IsMetricStale("5")).Should(BeFalse())
+				Ω(metrics.IsMetricMissing("5")).Should(BeFalse())
+			})
+		})
+	})
+
+	Describe("#ClockSkew", func() {
+		It("returns how far the cell's timestamp was behind the receipt time", func() {
+			Ω(metricsLib.MessageMetric{Timestamp: 1000, ReceivedAt: 1500}.ClockSkew()).Should(Equal(time.Duration(500)))
+			Ω(metricsLib.MessageMetric{Timestamp: 1000}.ClockSkew()).Should(BeZero())
+		})
+	})
+
+	Describe("#IsMetricExpired", func() {
+		BeforeEach(func() {
+			metrics = createPopulatedMetricsObj()
+			metrics.Set("4", metricsLib.MessageMetric{Memory: 3000, Timestamp: time.Now().Add(-1 * time.Hour).UnixNano()})
+			metrics.RetentionDuration = 2 * time.Hour
+		})
+
+		It("returns true only for metrics older than the retention duration", func() {
+			Ω(metrics.IsMetricStale("4")).Should(BeTrue())
+			Ω(metrics.IsMetricExpired("1")).Should(BeTrue())
+			Ω(metrics.IsMetricExpired("3")).Should(BeFalse())
+			Ω(metrics.IsMetricExpired("4")).Should(BeFalse())
+		})
+
+		It("never uses a retention duration shorter than the stale duration", func() {
+			metrics.RetentionDuration = time.Minute
+			Ω(metrics.IsMetricExpired("4")).Should(BeTrue())
+			Ω(metrics.IsMetricExpired("3")).Should(BeFalse())
+		})
+	})
+
 	Describe("#ClearStaleMetrics", func() {
+		Context("when there are metrics older than the retention duration", func() {
+			BeforeEach(func() {
+				metrics = createPopulatedMetricsObj()
+				metrics.Set("4", metricsLib.MessageMetric{Memory: 3000, Timestamp: time.Now().Add(-1 * time.Hour).UnixNano()})
+				metrics.RetentionDuration = 2 * time.Hour
+			})
+
+			It("keeps stale metrics until they expire", func() {
+				metrics.ClearStaleMetrics()
+				newMetrics := metrics.GetAll()
+				Ω(newMetrics).Should(HaveLen(2))
+				Ω(newMetrics).Should(HaveKey("3"))
+				Ω(newMetrics).Should(HaveKey("4"))
+			})
+		})
+
 		Context("when there are metrics", func() {
 			BeforeEach(func() {
 				metrics = createPopulatedMetricsObj()
@@ -0,0 +1,56 @@
+package mtls
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"io/ioutil"
+	"strings"
+)
+
+// Config - the certificates for mutual TLS, each is either PEM or the path of a PEM file
+type Config struct {
+	CACert     string
+	ClientCert string
+	ClientKey  string
+}
+
+// TLSConfig - returns a TLS config that trusts the CA certificate and presents the client certificate, name is
+// used in errors to say what the certificates are for
+func (config Config) TLSConfig(name string) (*tls.Config, error) {
+	tlsConfig := &tls.Config{MinVersion: tls.VersionTLS12}
+	if config.CACert != "" {
+		caCert, err := readPEM(config.CACert)
+		if err != nil {
+			return nil, fmt.Errorf("could not read the %s CA certificate: %v", name, err)
+		}
+		tlsConfig.RootCAs = x509.NewCertPool()
+		if !tlsConfig.RootCAs.AppendCertsFromPEM(caCert) {
+			return nil, fmt.Errorf("the %s CA certificate does not contain a PEM certificate", name)
+		}
+	}
+	if config.ClientCert != "" || config.ClientKey != "" {
+		clientCert, err := readPEM(config.ClientCert)
+		if err != nil {
+			return nil, fmt.Errorf("could not read the %s client certificate: %v", name, err)
+		}
+		clientKey, err := readPEM(config.ClientKey)
+		if err != nil {
+			return nil, fmt.Errorf("could not read the %s client key: %v", name, err)
+		}
+		certificate, err := tls.X509KeyPair(clientCert, clientKey)
+		if err != nil {
+			return nil, fmt.Errorf("could not load the %s client certificate and key: %v", name, err)
+		}
+		tlsConfig.Certificates = []tls.Certificate{certificate}
+	}
+	return tlsConfig, nil
+}
+
+// readPEM - returns PEM content as is, or reads it from a file
+func readPEM(pemOrPath string) ([]byte, error) {
+	if strings.Contains(pemOrPath, "-----BEGIN") {
+		return []byte(pemOrPath), nil
+	}
+	return ioutil.ReadFile(pemOrPath)
+}
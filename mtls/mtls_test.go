@@ -0,0 +1,49 @@
+package mtls_test
+
+import (
+	"github.com/FidelityInternational/diego-capacity-monitor/mtls"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+)
+
+var _ = Describe("Config", func() {
+	Describe("#TLSConfig", func() {
+		Context("when no certificates are set", func() {
+			It("returns a config using the system roots", func() {
+				tlsConfig, err := mtls.Config{}.TLSConfig("BBS")
+				Ω(err).Should(BeNil())
+				Ω(tlsConfig.RootCAs).Should(BeNil())
+				Ω(tlsConfig.Certificates).Should(BeEmpty())
+			})
+		})
+
+		Context("when the CA certificate is not PEM", func() {
+			It("returns an error naming what the certificate is for", func() {
+				_, err := mtls.Config{CACert: "-----BEGIN CERTIFICATE-----\nnot a certificate"}.TLSConfig("rep")
+				Ω(err).Should(MatchError("the rep CA certificate does not contain a PEM certificate"))
+			})
+		})
+
+		Context("when the CA certificate is a path that does not exist", func() {
+			It("returns an error", func() {
+				dir, err := ioutil.TempDir("", "mtls")
+				Ω(err).Should(BeNil())
+				defer os.RemoveAll(dir)
+				_, err = mtls.Config{CACert: filepath.Join(dir, "ca.crt")}.TLSConfig("BBS")
+				Ω(err).Should(HaveOccurred())
+				Ω(err.Error()).Should(HavePrefix("could not read the BBS CA certificate: "))
+			})
+		})
+
+		Context("when the client key is missing", func() {
+			It("returns an error", func() {
+				_, err := mtls.Config{ClientCert: "-----BEGIN CERTIFICATE-----"}.TLSConfig("BBS")
+				Ω(err).Should(HaveOccurred())
+				Ω(err.Error()).Should(HavePrefix("could not read the BBS client key: "))
+			})
+		})
+	})
+})
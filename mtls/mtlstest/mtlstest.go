@@ -0,0 +1,90 @@
+// Package mtlstest - certificates and TLS test servers for testing the mutual TLS clients, like httptest it panics
+// when they cannot be created
+package mtlstest
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"fmt"
+	"math/big"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"time"
+)
+
+// Certificate - a certificate and its key, both as PEM and parsed
+type Certificate struct {
+	CertPEM []byte
+	KeyPEM  []byte
+	Cert    *x509.Certificate
+	Key     *ecdsa.PrivateKey
+}
+
+// NewCertificate - creates a certificate for 127.0.0.1 signed by the parent, or a self signed CA when there is no
+// parent
+func NewCertificate(commonName string, parent *Certificate) *Certificate {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		panic(fmt.Sprintf("mtlstest: could not generate a key: %v", err))
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(time.Now().UnixNano()),
+		Subject:      pkix.Name{CommonName: commonName},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+		IPAddresses:  []net.IP{net.ParseIP("127.0.0.1")},
+	}
+	signer, signerKey := template, key
+	if parent == nil {
+		template.IsCA = true
+		template.BasicConstraintsValid = true
+	} else {
+		signer, signerKey = parent.Cert, parent.Key
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, signer, &key.PublicKey, signerKey)
+	if err != nil {
+		panic(fmt.Sprintf("mtlstest: could not create the %s certificate: %v", commonName, err))
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		panic(fmt.Sprintf("mtlstest: could not parse the %s certificate: %v", commonName, err))
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		panic(fmt.Sprintf("mtlstest: could not marshal the %s key: %v", commonName, err))
+	}
+	return &Certificate{
+		CertPEM: pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}),
+		KeyPEM:  pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}),
+		Cert:    cert,
+		Key:     key,
+	}
+}
+
+// NewServer - starts a TLS server presenting a certificate for the common name signed by the CA, which requires
+// client certificates signed by the CA
+func NewServer(ca *Certificate, commonName string, handler http.Handler) *httptest.Server {
+	server := httptest.NewUnstartedServer(handler)
+	serverCert := NewCertificate(commonName, ca)
+	serverKeyPair, err := tls.X509KeyPair(serverCert.CertPEM, serverCert.KeyPEM)
+	if err != nil {
+		panic(fmt.Sprintf("mtlstest: could not load the %s key pair: %v", commonName, err))
+	}
+	clientCAs := x509.NewCertPool()
+	clientCAs.AddCert(ca.Cert)
+	server.TLS = &tls.Config{
+		Certificates: []tls.Certificate{serverKeyPair},
+		ClientAuth:   tls.RequireAndVerifyClientCert,
+		ClientCAs:    clientCAs,
+	}
+	server.StartTLS()
+	return server
+}
@@ -0,0 +1,125 @@
+package placement
+
+import (
+	"sync"
+	"time"
+)
+
+// The auctioneer counters and BBS gauge that are tracked
+const (
+	LRPAuctionsFailed  = "AuctioneerLRPAuctionsFailed"
+	TaskAuctionsFailed = "AuctioneerTaskAuctionsFailed"
+	LRPsMissing        = "LRPsMissing"
+)
+
+type failure struct {
+	name       string
+	count      uint64
+	receivedAt time.Time
+}
+
+// Summary - the placement failures within the window and their rates
+type Summary struct {
+	Window                      time.Duration
+	LRPAuctionsFailed           uint64
+	TaskAuctionsFailed          uint64
+	LRPAuctionsFailedPerMinute  float64
+	TaskAuctionsFailedPerMinute float64
+	// LRPsMissing - the latest number of LRP instances the BBS wants running but are not, nil until it is received
+	LRPsMissing *float64
+}
+
+// Failures - returns the number of auctions that failed within the window
+func (s Summary) Failures() uint64 {
+	return s.LRPAuctionsFailed + s.TaskAuctionsFailed
+}
+
+// Tracker - tracks the auctions the auctioneer failed to place within a sliding window
+type Tracker struct {
+	Window time.Duration
+	// Now - returns the current time, time.Now when it is nil
+	Now         func() time.Time
+	failures    []failure
+	lrpsMissing *float64
+	received    bool
+	mutex       sync.Mutex
+}
+
+// CreateTracker - creates a Tracker with a 5 minute window
+func CreateTracker() *Tracker {
+	return &Tracker{Window: 5 * time.Minute}
+}
+
+// now - returns the current time from Now
+func (t *Tracker) now() time.Time {
+	if t.Now == nil {
+		return time.Now()
+	}
+	return t.Now()
+}
+
+// RecordCounter - records the increase in an auctioneer counter, returning false if the counter is not tracked
+func (t *Tracker) RecordCounter(name string, delta uint64) bool {
+	if name != LRPAuctionsFailed && name != TaskAuctionsFailed {
+		return false
+	}
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	t.received = true
+	if delta > 0 {
+		now := t.now()
+		// pruned as they are recorded so that the failures do not grow when the summary is not being read
+		t.pruneFailures(now)
+		t.failures = append(t.failures, failure{name: name, count: delta, receivedAt: now})
+	}
+	return true
+}
+
+// pruneFailures - forgets the failures from before the window ending now
+func (t *Tracker) pruneFailures(now time.Time) {
+	var recent []failure
+	for _, failure := range t.failures {
+		if failure.receivedAt.After(now.Add(-t.Window)) {
+			recent = append(recent, failure)
+		}
+	}
+	t.failures = recent
+}
+
+// RecordGauge - records the latest value of a BBS gauge, returning false if the gauge is not tracked
+func (t *Tracker) RecordGauge(name string, value float64) bool {
+	if name != LRPsMissing {
+		return false
+	}
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	t.received = true
+	t.lrpsMissing = &value
+	return true
+}
+
+// Summary - returns the failures within the window ending now, ok is false until a tracked metric is received
+func (t *Tracker) Summary(now time.Time) (summary Summary, ok bool) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	t.pruneFailures(now)
+	recent := t.failures
+
+	summary = Summary{Window: t.Window}
+	for _, failure := range recent {
+		if failure.name == LRPAuctionsFailed {
+			summary.LRPAuctionsFailed += failure.count
+		} else {
+			summary.TaskAuctionsFailed += failure.count
+		}
+	}
+	if minutes := t.Window.Minutes(); minutes > 0 {
+		summary.LRPAuctionsFailedPerMinute = float64(summary.LRPAuctionsFailed) / minutes
+		summary.TaskAuctionsFailedPerMinute = float64(summary.TaskAuctionsFailed) / minutes
+	}
+	if t.lrpsMissing != nil {
+		lrpsMissing := *t.lrpsMissing
+		summary.LRPsMissing = &lrpsMissing
+	}
+	return summary, t.received
+}
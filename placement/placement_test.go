@@ -0,0 +1,64 @@
+package placement_test
+
+import (
+	"github.com/FidelityInternational/diego-capacity-monitor/placement"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"time"
+)
+
+var _ = Describe("Tracker", func() {
+	var tracker *placement.Tracker
+
+	BeforeEach(func() {
+		tracker = placement.CreateTracker()
+	})
+
+	Describe("#Summary", func() {
+		It("is not ok until a tracked metric is received", func() {
+			_, ok := tracker.Summary(time.Now())
+			Ω(ok).Should(BeFalse())
+			Ω(tracker.RecordCounter("AuctioneerFetchStatesDuration", 1)).Should(BeFalse())
+			Ω(tracker.RecordGauge("LRPsRunning", 1)).Should(BeFalse())
+			_, ok = tracker.Summary(time.Now())
+			Ω(ok).Should(BeFalse())
+		})
+
+		It("sums the failures within the window and works out their rates", func() {
+			Ω(tracker.RecordCounter(placement.LRPAuctionsFailed, 4)).Should(BeTrue())
+			tracker.RecordCounter(placement.LRPAuctionsFailed, 6)
+			tracker.RecordCounter(placement.TaskAuctionsFailed, 1)
+			Ω(tracker.RecordGauge(placement.LRPsMissing, 3)).Should(BeTrue())
+
+			summary, ok := tracker.Summary(time.Now())
+			Ω(ok).Should(BeTrue())
+			Ω(summary.LRPAuctionsFailed).Should(Equal(uint64(10)))
+			Ω(summary.TaskAuctionsFailed).Should(Equal(uint64(1)))
+			Ω(summary.Failures()).Should(Equal(uint64(11)))
+			Ω(summary.LRPAuctionsFailedPerMinute).Should(Equal(2.0))
+			Ω(summary.TaskAuctionsFailedPerMinute).Should(Equal(0.2))
+			Ω(*summary.LRPsMissing).Should(Equal(3.0))
+		})
+
+		It("forgets failures that are older than the window", func() {
+			tracker.RecordCounter(placement.LRPAuctionsFailed, 4)
+			summary, ok := tracker.Summary(time.Now().Add(10 * time.Minute))
+			Ω(ok).Should(BeTrue())
+			Ω(summary.Failures()).Should(BeZero())
+		})
+	})
+
+	Describe("#RecordCounter", func() {
+		It("forgets failures that are older than the window as new ones are recorded", func() {
+			start := time.Now()
+			now := start
+			tracker.Now = func() time.Time { return now }
+			tracker.RecordCounter(placement.LRPAuctionsFailed, 4)
+			now = start.Add(10 * time.Minute)
+			tracker.RecordCounter(placement.LRPAuctionsFailed, 1)
+
+			summary, _ := tracker.Summary(start)
+			Ω(summary.Failures()).Should(Equal(uint64(1)))
+		})
+	})
+})
@@ -0,0 +1,47 @@
+package recording
+
+import (
+	"fmt"
+	"github.com/cloudfoundry/sonde-go/events"
+	"regexp"
+	"strings"
+)
+
+// Filter - which envelopes are recorded
+type Filter string
+
+// Supported filters
+const (
+	// FilterMatched - the capacity envelopes of the cells that the monitor evaluates health from
+	FilterMatched Filter = "matched"
+	// FilterAll - every envelope from the reps, the BBS and the auctioneer
+	FilterAll Filter = "all"
+)
+
+var cellJob = regexp.MustCompile("diego[_-]cell")
+
+// allOrigins - the origins of the envelopes recorded by FilterAll
+var allOrigins = map[string]bool{"rep": true, "bbs": true, "auctioneer": true}
+
+// ParseFilter - parses a filter, defaulting to matched when none is supplied
+func ParseFilter(filter string) (Filter, error) {
+	switch Filter(strings.ToLower(strings.TrimSpace(filter))) {
+	case "", FilterMatched:
+		return FilterMatched, nil
+	case FilterAll:
+		return FilterAll, nil
+	}
+	return "", fmt.Errorf("filter %q must be one of matched or all", filter)
+}
+
+// Matches - returns true if the envelope should be recorded
+func (f Filter) Matches(envelope *events.Envelope) bool {
+	if f == FilterAll {
+		return allOrigins[envelope.GetOrigin()]
+	}
+	if envelope.GetEventType() != events.Envelope_ValueMetric || !cellJob.MatchString(envelope.GetJob()) {
+		return false
+	}
+	name := envelope.GetValueMetric().GetName()
+	return name == "CapacityRemainingMemory" || name == "CapacityTotalMemory"
+}
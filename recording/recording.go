@@ -0,0 +1,296 @@
+package recording
+
+import (
+	"bufio"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"github.com/cloudfoundry/sonde-go/events"
+	"github.com/gogo/protobuf/proto"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Format - how envelopes are written to a recording
+type Format string
+
+// Supported formats
+const (
+	// FormatNDJSON - a JSON object per line with the receipt time and the envelope
+	FormatNDJSON Format = "ndjson"
+	// FormatProtobuf - per envelope, the receipt time in nanoseconds as 8 big-endian bytes, the length of the
+	// envelope as 4 big-endian bytes and the envelope as protobuf
+	FormatProtobuf Format = "protobuf"
+)
+
+// extensions - the file extension of each format
+var extensions = map[Format]string{
+	FormatNDJSON:   ".ndjson",
+	FormatProtobuf: ".pb",
+}
+
+// ParseFormat - parses a format, defaulting to ndjson when none is supplied
+func ParseFormat(format string) (Format, error) {
+	switch Format(strings.ToLower(strings.TrimSpace(format))) {
+	case "", FormatNDJSON:
+		return FormatNDJSON, nil
+	case FormatProtobuf:
+		return FormatProtobuf, nil
+	}
+	return "", fmt.Errorf("format %q must be one of ndjson or protobuf", format)
+}
+
+// FormatOf - returns the format of a recording from its file extension
+func FormatOf(path string) (Format, error) {
+	for format, extension := range extensions {
+		if strings.HasSuffix(path, extension) {
+			return format, nil
+		}
+	}
+	return "", fmt.Errorf("%s is not a recording, its extension must be .ndjson or .pb", path)
+}
+
+// Record - an envelope and when the monitor received it
+type Record struct {
+	ReceivedAt time.Time
+	Envelope   *events.Envelope
+}
+
+type ndjsonRecord struct {
+	ReceivedAt int64            `json:"received_at"`
+	Envelope   *events.Envelope `json:"envelope"`
+}
+
+// Config - where and how envelopes are recorded, a file is rotated once it reaches MaxBytes or is MaxAge old,
+// whichever comes first, neither applies when it is zero
+type Config struct {
+	Directory string
+	// Prefix - the start of the name of each file, which is followed by when it was opened
+	Prefix   string
+	Format   Format
+	MaxBytes int64
+	MaxAge   time.Duration
+	// FlushInterval - how often the buffered records are written to the file, one second when it is zero, they are
+	// also written when the file is rotated or closed
+	FlushInterval time.Duration
+}
+
+// Recorder - writes envelopes to rotating files
+type Recorder struct {
+	config   Config
+	file     *os.File
+	writer   *bufio.Writer
+	size     int64
+	openedAt time.Time
+	flushErr error
+	done     chan struct{}
+	mutex    sync.Mutex
+}
+
+// NewRecorder - creates a Recorder, creating its directory if needed, the first file is opened with the first record
+func NewRecorder(config Config) (*Recorder, error) {
+	if config.Directory == "" {
+		return nil, fmt.Errorf("the recording directory must be set")
+	}
+	if _, ok := extensions[config.Format]; !ok {
+		return nil, fmt.Errorf("format %q must be one of ndjson or protobuf", config.Format)
+	}
+	if config.Prefix == "" {
+		config.Prefix = "envelopes"
+	}
+	if config.FlushInterval <= 0 {
+		config.FlushInterval = time.Second
+	}
+	if err := os.MkdirAll(config.Directory, 0755); err != nil {
+		return nil, err
+	}
+	recorder := &Recorder{config: config, done: make(chan struct{})}
+	go recorder.flushEvery(config.FlushInterval)
+	return recorder, nil
+}
+
+// flushEvery - writes the buffered records to the file at every interval until the recorder is closed, an error is
+// kept to be returned by the next Record or Close
+func (r *Recorder) flushEvery(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-r.done:
+			return
+		case <-ticker.C:
+			r.mutex.Lock()
+			if r.writer != nil {
+				if err := r.writer.Flush(); err != nil && r.flushErr == nil {
+					r.flushErr = err
+				}
+			}
+			r.mutex.Unlock()
+		}
+	}
+}
+
+// takeFlushErr - returns and forgets the error from the last failed flush
+func (r *Recorder) takeFlushErr() error {
+	err := r.flushErr
+	r.flushErr = nil
+	if err != nil {
+		return fmt.Errorf("could not flush the recording: %v", err)
+	}
+	return nil
+}
+
+// Record - buffers the envelope with when it was received, rotating the file first if it is due, the buffer is
+// written to the file every FlushInterval rather than for each envelope
+func (r *Recorder) Record(envelope *events.Envelope, receivedAt time.Time) error {
+	bytes, err := r.encode(envelope, receivedAt)
+	if err != nil {
+		return err
+	}
+
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	if r.file != nil && r.dueForRotation(int64(len(bytes)), receivedAt) {
+		if err := r.close(); err != nil {
+			return err
+		}
+	}
+	if r.file == nil {
+		if err := r.open(receivedAt); err != nil {
+			return err
+		}
+	}
+	if _, err := r.writer.Write(bytes); err != nil {
+		return err
+	}
+	r.size += int64(len(bytes))
+	return r.takeFlushErr()
+}
+
+// encode - encodes a record in the recorder's format
+func (r *Recorder) encode(envelope *events.Envelope, receivedAt time.Time) ([]byte, error) {
+	if r.config.Format == FormatNDJSON {
+		bytes, err := json.Marshal(ndjsonRecord{ReceivedAt: receivedAt.UnixNano(), Envelope: envelope})
+		if err != nil {
+			return nil, err
+		}
+		return append(bytes, '\n'), nil
+	}
+	envelopeBytes, err := proto.Marshal(envelope)
+	if err != nil {
+		return nil, err
+	}
+	bytes := make([]byte, 12, 12+len(envelopeBytes))
+	binary.BigEndian.PutUint64(bytes[0:8], uint64(receivedAt.UnixNano()))
+	binary.BigEndian.PutUint32(bytes[8:12], uint32(len(envelopeBytes)))
+	return append(bytes, envelopeBytes...), nil
+}
+
+// dueForRotation - returns true if writing the next record would take the file over its maximum size, or the
+// file has reached its maximum age
+func (r *Recorder) dueForRotation(nextSize int64, now time.Time) bool {
+	if r.config.MaxBytes > 0 && r.size > 0 && r.size+nextSize > r.config.MaxBytes {
+		return true
+	}
+	return r.config.MaxAge > 0 && now.Sub(r.openedAt) >= r.config.MaxAge
+}
+
+// open - opens a new file named after when it was opened
+func (r *Recorder) open(now time.Time) error {
+	name := fmt.Sprintf("%s-%s%s", r.config.Prefix, now.UTC().Format("20060102T150405.000000000Z"), extensions[r.config.Format])
+	file, err := os.OpenFile(filepath.Join(r.config.Directory, name), os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return err
+	}
+	r.file, r.writer, r.size, r.openedAt = file, bufio.NewWriter(file), 0, now
+	return nil
+}
+
+// close - flushes and closes the current file
+func (r *Recorder) close() error {
+	if r.file == nil {
+		return nil
+	}
+	err := r.writer.Flush()
+	if closeErr := r.file.Close(); err == nil {
+		err = closeErr
+	}
+	r.file, r.writer = nil, nil
+	return err
+}
+
+// Close - flushes and closes the current file and stops the periodic flush, the recorder must not be used after
+func (r *Recorder) Close() error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	select {
+	case <-r.done:
+	default:
+		close(r.done)
+	}
+	if err := r.takeFlushErr(); err != nil {
+		r.close()
+		return err
+	}
+	return r.close()
+}
+
+// Reader - reads the records of a recording in order
+type Reader struct {
+	format  Format
+	reader  *bufio.Reader
+	scanner *bufio.Scanner
+}
+
+// NewReader - creates a Reader of a recording in the format
+func NewReader(reader io.Reader, format Format) *Reader {
+	r := &Reader{format: format}
+	if format == FormatNDJSON {
+		r.scanner = bufio.NewScanner(reader)
+		r.scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	} else {
+		r.reader = bufio.NewReader(reader)
+	}
+	return r
+}
+
+// Next - returns the next record, io.EOF once there are no more
+func (r *Reader) Next() (Record, error) {
+	if r.format == FormatNDJSON {
+		for r.scanner.Scan() {
+			if len(strings.TrimSpace(r.scanner.Text())) == 0 {
+				continue
+			}
+			var record ndjsonRecord
+			if err := json.Unmarshal(r.scanner.Bytes(), &record); err != nil {
+				return Record{}, fmt.Errorf("could not decode a record: %v", err)
+			}
+			return Record{ReceivedAt: time.Unix(0, record.ReceivedAt), Envelope: record.Envelope}, nil
+		}
+		if err := r.scanner.Err(); err != nil {
+			return Record{}, err
+		}
+		return Record{}, io.EOF
+	}
+
+	header := make([]byte, 12)
+	if _, err := io.ReadFull(r.reader, header); err != nil {
+		if err == io.ErrUnexpectedEOF {
+			return Record{}, fmt.Errorf("the recording ends part way through a record")
+		}
+		return Record{}, err
+	}
+	envelopeBytes := make([]byte, binary.BigEndian.Uint32(header[8:12]))
+	if _, err := io.ReadFull(r.reader, envelopeBytes); err != nil {
+		return Record{}, fmt.Errorf("the recording ends part way through a record")
+	}
+	envelope := &events.Envelope{}
+	if err := proto.Unmarshal(envelopeBytes, envelope); err != nil {
+		return Record{}, fmt.Errorf("could not decode a record: %v", err)
+	}
+	return Record{ReceivedAt: time.Unix(0, int64(binary.BigEndian.Uint64(header[0:8]))), Envelope: envelope}, nil
+}
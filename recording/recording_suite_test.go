@@ -0,0 +1,13 @@
+package recording_test
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"testing"
+)
+
+func TestRecording(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "Recording test suite")
+}
@@ -0,0 +1,199 @@
+package recording_test
+
+import (
+	"github.com/FidelityInternational/diego-capacity-monitor/recording"
+	"github.com/cloudfoundry/sonde-go/events"
+	"github.com/gogo/protobuf/proto"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+)
+
+// capacityEnvelope - returns a rep's CapacityRemainingMemory envelope
+func capacityEnvelope(index string, memory float64) *events.Envelope {
+	return &events.Envelope{
+		Origin:      proto.String("rep"),
+		EventType:   events.Envelope_ValueMetric.Enum(),
+		Timestamp:   proto.Int64(1600000000000000000),
+		Job:         proto.String("diego_cell"),
+		Index:       proto.String(index),
+		Tags:        map[string]string{"az": "z1"},
+		ValueMetric: &events.ValueMetric{Name: proto.String("CapacityRemainingMemory"), Value: proto.Float64(memory), Unit: proto.String("MiB")},
+	}
+}
+
+// readAll - returns every record in the recording files in the directory in order
+func readAll(dir string) ([]string, []recording.Record) {
+	files, err := filepath.Glob(filepath.Join(dir, "*"))
+	Ω(err).Should(BeNil())
+	sort.Strings(files)
+	var records []recording.Record
+	for _, path := range files {
+		format, err := recording.FormatOf(path)
+		Ω(err).Should(BeNil())
+		file, err := os.Open(path)
+		Ω(err).Should(BeNil())
+		reader := recording.NewReader(file, format)
+		for {
+			record, err := reader.Next()
+			if err == io.EOF {
+				break
+			}
+			Ω(err).Should(BeNil())
+			records = append(records, record)
+		}
+		file.Close()
+	}
+	return files, records
+}
+
+var _ = Describe("Recording", func() {
+	var (
+		dir    string
+		config recording.Config
+		start  time.Time
+	)
+
+	BeforeEach(func() {
+		var err error
+		dir, err = ioutil.TempDir("", "recording")
+		Ω(err).Should(BeNil())
+		config = recording.Config{Directory: filepath.Join(dir, "envelopes"), Format: recording.FormatNDJSON}
+		start = time.Date(2026, 10, 19, 12, 0, 0, 0, time.UTC)
+	})
+
+	AfterEach(func() {
+		os.RemoveAll(dir)
+	})
+
+	Describe("ParseFormat", func() {
+		It("defaults to ndjson", func() {
+			format, err := recording.ParseFormat("")
+			Ω(err).Should(BeNil())
+			Ω(format).Should(Equal(recording.FormatNDJSON))
+			format, err = recording.ParseFormat("Protobuf")
+			Ω(err).Should(BeNil())
+			Ω(format).Should(Equal(recording.FormatProtobuf))
+			_, err = recording.ParseFormat("csv")
+			Ω(err).Should(MatchError(`format "csv" must be one of ndjson or protobuf`))
+		})
+	})
+
+	Describe("Filter", func() {
+		It("matches the cells' capacity envelopes", func() {
+			filter, err := recording.ParseFilter("")
+			Ω(err).Should(BeNil())
+			Ω(filter.Matches(capacityEnvelope("cell-1", 4096))).Should(BeTrue())
+			envelope := capacityEnvelope("cell-1", 4096)
+			envelope.ValueMetric.Name = proto.String("ContainerCount")
+			Ω(filter.Matches(envelope)).Should(BeFalse())
+		})
+
+		It("matches every rep, BBS and auctioneer envelope when it is all", func() {
+			filter, err := recording.ParseFilter("all")
+			Ω(err).Should(BeNil())
+			envelope := capacityEnvelope("cell-1", 4096)
+			envelope.ValueMetric.Name = proto.String("ContainerCount")
+			Ω(filter.Matches(envelope)).Should(BeTrue())
+			envelope.Origin = proto.String("gorouter")
+			Ω(filter.Matches(envelope)).Should(BeFalse())
+		})
+	})
+
+	for _, format := range []recording.Format{recording.FormatNDJSON, recording.FormatProtobuf} {
+		format := format
+		Context("when the format is "+string(format), func() {
+			BeforeEach(func() {
+				config.Format = format
+			})
+
+			It("records the envelopes with when they were received", func() {
+				recorder, err := recording.NewRecorder(config)
+				Ω(err).Should(BeNil())
+				Ω(recorder.Record(capacityEnvelope("cell-1", 4096), start)).Should(Succeed())
+				Ω(recorder.Record(capacityEnvelope("cell-2", 2048), start.Add(time.Second))).Should(Succeed())
+				Ω(recorder.Close()).Should(Succeed())
+
+				files, records := readAll(config.Directory)
+				Ω(files).Should(HaveLen(1))
+				Ω(filepath.Base(files[0])).Should(HavePrefix("envelopes-20261019T120000.000000000Z."))
+				Ω(records).Should(HaveLen(2))
+				Ω(records[0].ReceivedAt.Equal(start)).Should(BeTrue())
+				Ω(records[0].Envelope).Should(Equal(capacityEnvelope("cell-1", 4096)))
+				Ω(records[1].ReceivedAt.Equal(start.Add(time.Second))).Should(BeTrue())
+				Ω(records[1].Envelope.GetIndex()).Should(Equal("cell-2"))
+			})
+
+			It("rotates the file once it would exceed the maximum size", func() {
+				config.MaxBytes = 1
+				recorder, err := recording.NewRecorder(config)
+				Ω(err).Should(BeNil())
+				for i := 0; i < 3; i++ {
+					Ω(recorder.Record(capacityEnvelope("cell-1", 4096), start.Add(time.Duration(i)*time.Millisecond))).Should(Succeed())
+				}
+				Ω(recorder.Close()).Should(Succeed())
+
+				files, records := readAll(config.Directory)
+				Ω(files).Should(HaveLen(3))
+				Ω(records).Should(HaveLen(3))
+			})
+		})
+	}
+
+	It("rotates the file once it reaches the maximum age", func() {
+		config.MaxAge = time.Minute
+		recorder, err := recording.NewRecorder(config)
+		Ω(err).Should(BeNil())
+		Ω(recorder.Record(capacityEnvelope("cell-1", 4096), start)).Should(Succeed())
+		Ω(recorder.Record(capacityEnvelope("cell-1", 4096), start.Add(59*time.Second))).Should(Succeed())
+		Ω(recorder.Record(capacityEnvelope("cell-1", 4096), start.Add(time.Minute))).Should(Succeed())
+		Ω(recorder.Close()).Should(Succeed())
+
+		files, records := readAll(config.Directory)
+		Ω(files).Should(HaveLen(2))
+		Ω(filepath.Base(files[1])).Should(Equal("envelopes-20261019T120100.000000000Z.ndjson"))
+		Ω(records).Should(HaveLen(3))
+	})
+
+	It("buffers the records and writes them to the file every flush interval", func() {
+		config.FlushInterval = 50 * time.Millisecond
+		recorder, err := recording.NewRecorder(config)
+		Ω(err).Should(BeNil())
+		defer recorder.Close()
+		Ω(recorder.Record(capacityEnvelope("cell-1", 4096), start)).Should(Succeed())
+		_, records := readAll(config.Directory)
+		Ω(records).Should(BeEmpty())
+		Eventually(func() []recording.Record {
+			_, records := readAll(config.Directory)
+			return records
+		}).Should(HaveLen(1))
+	})
+
+	It("requires a directory", func() {
+		_, err := recording.NewRecorder(recording.Config{Format: recording.FormatNDJSON})
+		Ω(err).Should(MatchError("the recording directory must be set"))
+	})
+
+	It("returns an error when a protobuf recording ends part way through a record", func() {
+		config.Format = recording.FormatProtobuf
+		recorder, err := recording.NewRecorder(config)
+		Ω(err).Should(BeNil())
+		Ω(recorder.Record(capacityEnvelope("cell-1", 4096), start)).Should(Succeed())
+		Ω(recorder.Close()).Should(Succeed())
+		files, _ := filepath.Glob(filepath.Join(config.Directory, "*"))
+		info, err := os.Stat(files[0])
+		Ω(err).Should(BeNil())
+		Ω(os.Truncate(files[0], info.Size()-1)).Should(Succeed())
+
+		file, err := os.Open(files[0])
+		Ω(err).Should(BeNil())
+		defer file.Close()
+		_, err = recording.NewReader(file, recording.FormatProtobuf).Next()
+		Ω(err).Should(MatchError("the recording ends part way through a record"))
+	})
+})
@@ -0,0 +1,244 @@
+package replay
+
+import (
+	"fmt"
+	"github.com/FidelityInternational/diego-capacity-monitor/recording"
+	webServer "github.com/FidelityInternational/diego-capacity-monitor/web_server"
+	"github.com/cloudfoundry/sonde-go/events"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Speed - how fast a recording is replayed
+type Speed string
+
+// Supported speeds
+const (
+	// SpeedFast - the envelopes are replayed as fast as they can be read
+	SpeedFast Speed = "fast"
+	// SpeedRealTime - the gaps between the envelopes are waited out so that they are replayed at the speed they were
+	// received
+	SpeedRealTime Speed = "realtime"
+)
+
+// ParseSpeed - parses a speed, defaulting to fast when none is supplied
+func ParseSpeed(speed string) (Speed, error) {
+	switch Speed(strings.ToLower(strings.TrimSpace(speed))) {
+	case "", SpeedFast:
+		return SpeedFast, nil
+	case SpeedRealTime:
+		return SpeedRealTime, nil
+	}
+	return "", fmt.Errorf("speed %q must be one of fast or realtime", speed)
+}
+
+// Clock - the time in the recording being replayed, which the monitor reads instead of the wall clock
+type Clock struct {
+	now   time.Time
+	mutex sync.Mutex
+}
+
+// Now - returns the time in the recording
+func (c *Clock) Now() time.Time {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return c.now
+}
+
+// Set - moves the clock to the given time
+func (c *Clock) Set(now time.Time) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.now = now
+}
+
+// Transition - a change of the health evaluated during the replay
+type Transition struct {
+	Time       time.Time `json:"time"`
+	Healthy    bool      `json:"healthy"`
+	Status     string    `json:"status"`
+	Reasons    []string  `json:"reasons"`
+	Message    string    `json:"message"`
+	StatusCode int       `json:"status_code"`
+}
+
+// Timeline - the changes of health over a replayed recording
+type Timeline struct {
+	Start       time.Time    `json:"start"`
+	End         time.Time    `json:"end"`
+	Envelopes   int          `json:"envelopes"`
+	Transitions []Transition `json:"transitions"`
+}
+
+// WriteText - writes the timeline with a line for each transition, giving how long each state lasted
+func (t Timeline) WriteText(w io.Writer) error {
+	if _, err := fmt.Fprintf(w, "Replayed %d envelopes from %s to %s\n", t.Envelopes,
+		t.Start.UTC().Format(time.RFC3339), t.End.UTC().Format(time.RFC3339)); err != nil {
+		return err
+	}
+	for i, transition := range t.Transitions {
+		until := t.End
+		if i+1 < len(t.Transitions) {
+			until = t.Transitions[i+1].Time
+		}
+		if _, err := fmt.Fprintf(w, "%s  %-10v  %-8s  %s  %s\n", transition.Time.UTC().Format(time.RFC3339),
+			until.Sub(transition.Time), transition.Status, strings.Join(transition.Reasons, ","), transition.Message); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Replayer - feeds recorded envelopes to the monitor, timing it by when they were received, and records the health
+// it evaluates
+type Replayer struct {
+	// Clock - set to when each envelope was received before it is ingested
+	Clock *Clock
+	Speed Speed
+	// EvaluationInterval - how often in the recording's time the health is evaluated, after every envelope when it
+	// is zero
+	EvaluationInterval time.Duration
+	// Start - called with when the first envelope was received before it is ingested, nil when it is not needed
+	Start func(start time.Time)
+	// Ingest - ingests an envelope the same way as when it is streamed from the firehose
+	Ingest func(envelope *events.Envelope)
+	// Evaluate - evaluates the health the same way as the Index endpoint
+	Evaluate func() webServer.Health
+	timeline Timeline
+	started  bool
+	next     time.Time
+}
+
+// Files - returns the recording at the path, or the recordings in the directory at the path in the order they
+// were recorded
+func Files(path string) ([]string, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+	if !info.IsDir() {
+		return []string{path}, nil
+	}
+	entries, err := ioutil.ReadDir(path)
+	if err != nil {
+		return nil, err
+	}
+	var files []string
+	for _, entry := range entries {
+		if _, err := recording.FormatOf(entry.Name()); err == nil && !entry.IsDir() {
+			files = append(files, filepath.Join(path, entry.Name()))
+		}
+	}
+	// the files are named after when they were opened so they sort in the order they were recorded
+	sort.Strings(files)
+	if len(files) == 0 {
+		return nil, fmt.Errorf("there are no recordings in %s", path)
+	}
+	return files, nil
+}
+
+// Replay - replays the recording files in order, returning the timeline of the health evaluated while they were
+// replayed
+func (r *Replayer) Replay(files ...string) (Timeline, error) {
+	for _, file := range files {
+		if err := r.replayFile(file); err != nil {
+			return r.timeline, err
+		}
+	}
+	if !r.started {
+		return r.timeline, fmt.Errorf("the recording does not contain any envelopes")
+	}
+	if r.EvaluationInterval > 0 {
+		r.evaluate(r.timeline.End)
+	}
+	return r.timeline, nil
+}
+
+// replayFile - replays the records of a recording file
+func (r *Replayer) replayFile(file string) error {
+	format, err := recording.FormatOf(file)
+	if err != nil {
+		return err
+	}
+	f, err := os.Open(file)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	reader := recording.NewReader(f, format)
+	for {
+		record, err := reader.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("could not read %s: %v", file, err)
+		}
+		r.replay(record)
+	}
+}
+
+// replay - ingests a record at the time it was received, first evaluating the health at every interval the
+// recording has moved past since the previous record
+func (r *Replayer) replay(record recording.Record) {
+	if !r.started {
+		r.started = true
+		r.timeline.Start, r.timeline.End = record.ReceivedAt, record.ReceivedAt
+		r.next = record.ReceivedAt.Add(r.EvaluationInterval)
+		r.Clock.Set(record.ReceivedAt)
+		if r.Start != nil {
+			r.Start(record.ReceivedAt)
+		}
+	}
+	for r.EvaluationInterval > 0 && !r.next.After(record.ReceivedAt) {
+		r.evaluate(r.next)
+		r.next = r.next.Add(r.EvaluationInterval)
+	}
+	r.advance(record.ReceivedAt)
+	r.Ingest(record.Envelope)
+	r.timeline.Envelopes++
+	if r.EvaluationInterval == 0 {
+		r.evaluate(record.ReceivedAt)
+	}
+}
+
+// evaluate - evaluates the health at the given time, adding a transition when it differs from the last one
+func (r *Replayer) evaluate(now time.Time) {
+	r.advance(now)
+	health := r.Evaluate()
+	transitions := r.timeline.Transitions
+	if len(transitions) > 0 {
+		last := transitions[len(transitions)-1]
+		if last.Status == health.Status && last.Healthy == health.Healthy && strings.Join(last.Reasons, ",") == strings.Join(health.Reasons, ",") {
+			return
+		}
+	}
+	r.timeline.Transitions = append(transitions, Transition{
+		Time:       r.Clock.Now(),
+		Healthy:    health.Healthy,
+		Status:     health.Status,
+		Reasons:    health.Reasons,
+		Message:    health.Message,
+		StatusCode: health.StatusCode,
+	})
+}
+
+// advance - moves the clock forward to the given time, waiting for the gap when replaying in real time, the clock
+// is never moved back
+func (r *Replayer) advance(now time.Time) {
+	gap := now.Sub(r.Clock.Now())
+	if gap <= 0 {
+		return
+	}
+	if r.Speed == SpeedRealTime {
+		time.Sleep(gap)
+	}
+	r.Clock.Set(now)
+	r.timeline.End = now
+}
@@ -0,0 +1,270 @@
+package replay_test
+
+import (
+	"bytes"
+	metricsLib "github.com/FidelityInternational/diego-capacity-monitor/metrics"
+	"github.com/FidelityInternational/diego-capacity-monitor/recording"
+	"github.com/FidelityInternational/diego-capacity-monitor/replay"
+	webs "github.com/FidelityInternational/diego-capacity-monitor/web_server"
+	"github.com/cloudfoundry/sonde-go/events"
+	"github.com/gogo/protobuf/proto"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// capacityEnvelope - returns a rep's CapacityRemainingMemory envelope
+func capacityEnvelope(index string, memory float64, timestamp time.Time) *events.Envelope {
+	return &events.Envelope{
+		Origin:      proto.String("rep"),
+		EventType:   events.Envelope_ValueMetric.Enum(),
+		Timestamp:   proto.Int64(timestamp.UnixNano()),
+		Job:         proto.String("diego_cell"),
+		Index:       proto.String(index),
+		ValueMetric: &events.ValueMetric{Name: proto.String("CapacityRemainingMemory"), Value: proto.Float64(memory), Unit: proto.String("MiB")},
+	}
+}
+
+// record - records the free memory of each cell as received at the time
+func record(recorder *recording.Recorder, receivedAt time.Time, memory ...float64) {
+	for i, cellMemory := range memory {
+		index := string(rune('1' + i))
+		Ω(recorder.Record(capacityEnvelope(index, cellMemory, receivedAt), receivedAt)).Should(Succeed())
+	}
+}
+
+var _ = Describe("Replay", func() {
+	var (
+		dir   string
+		start time.Time
+	)
+
+	BeforeEach(func() {
+		var err error
+		dir, err = ioutil.TempDir("", "replay")
+		Ω(err).Should(BeNil())
+		start = time.Date(2026, 10, 19, 10, 0, 0, 0, time.UTC)
+	})
+
+	AfterEach(func() {
+		os.RemoveAll(dir)
+	})
+
+	Describe("ParseSpeed", func() {
+		It("defaults to fast", func() {
+			speed, err := replay.ParseSpeed("")
+			Ω(err).Should(BeNil())
+			Ω(speed).Should(Equal(replay.SpeedFast))
+			speed, err = replay.ParseSpeed("RealTime")
+			Ω(err).Should(BeNil())
+			Ω(speed).Should(Equal(replay.SpeedRealTime))
+		})
+
+		It("rejects an unknown speed", func() {
+			_, err := replay.ParseSpeed("slow")
+			Ω(err).Should(MatchError(`speed "slow" must be one of fast or realtime`))
+		})
+	})
+
+	Describe("Files", func() {
+		It("returns a recording file as it is", func() {
+			path := filepath.Join(dir, "incident.pb")
+			Ω(ioutil.WriteFile(path, nil, 0644)).Should(Succeed())
+			files, err := replay.Files(path)
+			Ω(err).Should(BeNil())
+			Ω(files).Should(Equal([]string{path}))
+		})
+
+		It("returns the recordings in a directory in the order they were recorded", func() {
+			for _, name := range []string{"envelopes-20261019T110000.000000000Z.ndjson", "notes.txt", "envelopes-20261019T100000.000000000Z.ndjson"} {
+				Ω(ioutil.WriteFile(filepath.Join(dir, name), nil, 0644)).Should(Succeed())
+			}
+			files, err := replay.Files(dir)
+			Ω(err).Should(BeNil())
+			Ω(files).Should(Equal([]string{
+				filepath.Join(dir, "envelopes-20261019T100000.000000000Z.ndjson"),
+				filepath.Join(dir, "envelopes-20261019T110000.000000000Z.ndjson"),
+			}))
+		})
+
+		It("returns an error when a directory has no recordings", func() {
+			_, err := replay.Files(dir)
+			Ω(err).Should(MatchError("there are no recordings in " + dir))
+		})
+	})
+
+	Describe("Replayer", func() {
+		var (
+			clock      *replay.Clock
+			controller *webs.Controller
+			replayer   *replay.Replayer
+			files      []string
+			format     recording.Format
+			recordFunc func(recorder *recording.Recorder)
+		)
+
+		BeforeEach(func() {
+			format = recording.FormatNDJSON
+			recordFunc = func(recorder *recording.Recorder) {
+				record(recorder, start, 8000, 8000, 8000)
+				record(recorder, start.Add(time.Minute), 1000, 1000, 1000)
+				record(recorder, start.Add(2*time.Minute), 1000, 1000, 1000)
+			}
+
+			clock = &replay.Clock{}
+			cellMemory := float64(10000)
+			watermark := "1"
+			metrics := metricsLib.CreateMetrics()
+			metrics.Now = clock.Now
+			controller = webs.CreateController(metrics, &cellMemory, &watermark, time.Now())
+			controller.Now = clock.Now
+			controller.ExpectedCellCount = 3
+			replayer = &replay.Replayer{
+				Clock:              clock,
+				Speed:              replay.SpeedFast,
+				EvaluationInterval: 30 * time.Second,
+				Start:              func(start time.Time) { controller.StartTime = start },
+				Ingest: func(envelope *events.Envelope) {
+					metrics.Record(envelope.GetIndex(), metricsLib.MessageMetric{Memory: envelope.GetValueMetric().GetValue(), Timestamp: envelope.GetTimestamp()})
+				},
+				Evaluate: controller.Health,
+			}
+		})
+
+		JustBeforeEach(func() {
+			recorder, err := recording.NewRecorder(recording.Config{Directory: dir, Format: format})
+			Ω(err).Should(BeNil())
+			recordFunc(recorder)
+			Ω(recorder.Close()).Should(Succeed())
+			files, err = replay.Files(dir)
+			Ω(err).Should(BeNil())
+		})
+
+		It("evaluates the health every interval of the recording's time and returns its transitions", func() {
+			timeline, err := replayer.Replay(files...)
+			Ω(err).Should(BeNil())
+			Ω(timeline.Start).Should(BeTemporally("==", start))
+			Ω(timeline.End).Should(BeTemporally("==", start.Add(2*time.Minute)))
+			Ω(timeline.Envelopes).Should(Equal(9))
+			Ω(timeline.Transitions).Should(HaveLen(2))
+			Ω(timeline.Transitions[0].Time).Should(BeTemporally("==", start.Add(30*time.Second)))
+			Ω(timeline.Transitions[0].Healthy).Should(BeTrue())
+			Ω(timeline.Transitions[0].Status).Should(Equal("ok"))
+			Ω(timeline.Transitions[1].Time).Should(BeTemporally("==", start.Add(90*time.Second)))
+			Ω(timeline.Transitions[1].Healthy).Should(BeFalse())
+			Ω(timeline.Transitions[1].Status).Should(Equal("critical"))
+			Ω(timeline.Transitions[1].Reasons).Should(Equal([]string{"no_upgrade_headroom"}))
+			Ω(timeline.Transitions[1].StatusCode).Should(Equal(417))
+		})
+
+		It("sets the clock to when the envelopes were received", func() {
+			_, err := replayer.Replay(files...)
+			Ω(err).Should(BeNil())
+			Ω(clock.Now()).Should(BeTemporally("==", start.Add(2*time.Minute)))
+			Ω(controller.StartTime).Should(BeTemporally("==", start))
+		})
+
+		Context("when the cells stop reporting", func() {
+			BeforeEach(func() {
+				recordFunc = func(recorder *recording.Recorder) {
+					record(recorder, start, 8000, 8000, 8000)
+					record(recorder, start.Add(time.Hour), 8000)
+				}
+			})
+
+			It("reports them as missing in the gap once they are stale", func() {
+				timeline, err := replayer.Replay(files...)
+				Ω(err).Should(BeNil())
+				Ω(timeline.Transitions).Should(HaveLen(3))
+				Ω(timeline.Transitions[0].Status).Should(Equal("ok"))
+				Ω(timeline.Transitions[1].Time).Should(BeTemporally("==", start.Add(15*time.Minute+30*time.Second)))
+				Ω(timeline.Transitions[1].Reasons).Should(Equal([]string{"no_data"}))
+				Ω(timeline.Transitions[2].Time).Should(BeTemporally("==", start.Add(time.Hour)))
+				Ω(timeline.Transitions[2].Reasons).Should(Equal([]string{"insufficient_cells"}))
+			})
+		})
+
+		Context("when the evaluation interval is zero", func() {
+			BeforeEach(func() {
+				replayer.EvaluationInterval = 0
+			})
+
+			It("evaluates the health after every envelope", func() {
+				timeline, err := replayer.Replay(files...)
+				Ω(err).Should(BeNil())
+				Ω(timeline.Transitions).Should(HaveLen(3))
+				Ω(timeline.Transitions[0].Time).Should(BeTemporally("==", start))
+				Ω(timeline.Transitions[0].Reasons).Should(Equal([]string{"initialising"}))
+				Ω(timeline.Transitions[1].Time).Should(BeTemporally("==", start))
+				Ω(timeline.Transitions[1].Status).Should(Equal("ok"))
+				Ω(timeline.Transitions[2].Time).Should(BeTemporally("==", start.Add(time.Minute)))
+				Ω(timeline.Transitions[2].Status).Should(Equal("critical"))
+			})
+		})
+
+		Context("when the recording is replayed in real time", func() {
+			BeforeEach(func() {
+				replayer.Speed = replay.SpeedRealTime
+				recordFunc = func(recorder *recording.Recorder) {
+					record(recorder, start, 8000, 8000, 8000)
+					record(recorder, start.Add(200*time.Millisecond), 1000, 1000, 1000)
+				}
+			})
+
+			It("waits out the gaps between the envelopes", func() {
+				began := time.Now()
+				timeline, err := replayer.Replay(files...)
+				Ω(err).Should(BeNil())
+				Ω(time.Since(began)).Should(BeNumerically(">=", 200*time.Millisecond))
+				Ω(timeline.Envelopes).Should(Equal(6))
+			})
+		})
+
+		Context("when the recording is protobuf", func() {
+			BeforeEach(func() {
+				format = recording.FormatProtobuf
+			})
+
+			It("replays it the same way", func() {
+				timeline, err := replayer.Replay(files...)
+				Ω(err).Should(BeNil())
+				Ω(timeline.Envelopes).Should(Equal(9))
+				Ω(timeline.Transitions).Should(HaveLen(2))
+			})
+		})
+
+		Context("when the recording has no envelopes", func() {
+			BeforeEach(func() {
+				recordFunc = func(recorder *recording.Recorder) {}
+				Ω(ioutil.WriteFile(filepath.Join(dir, "empty.ndjson"), nil, 0644)).Should(Succeed())
+			})
+
+			It("returns an error", func() {
+				_, err := replayer.Replay(files...)
+				Ω(err).Should(MatchError("the recording does not contain any envelopes"))
+			})
+		})
+	})
+
+	Describe("Timeline", func() {
+		It("writes a line for each transition with how long it lasted", func() {
+			timeline := replay.Timeline{
+				Start:     start,
+				End:       start.Add(2 * time.Minute),
+				Envelopes: 9,
+				Transitions: []replay.Transition{
+					{Time: start.Add(30 * time.Second), Healthy: true, Status: "ok", Reasons: []string{"ok"}, Message: "Everything is awesome!"},
+					{Time: start.Add(90 * time.Second), Status: "critical", Reasons: []string{"no_upgrade_headroom"}, Message: "FATAL"},
+				},
+			}
+			var buffer bytes.Buffer
+			Ω(timeline.WriteText(&buffer)).Should(Succeed())
+			Ω(buffer.String()).Should(Equal("Replayed 9 envelopes from 2026-10-19T10:00:00Z to 2026-10-19T10:02:00Z\n" +
+				"2026-10-19T10:00:30Z  1m0s        ok        ok  Everything is awesome!\n" +
+				"2026-10-19T10:01:30Z  30s         critical  no_upgrade_headroom  FATAL\n"))
+		})
+	})
+})
@@ -0,0 +1,153 @@
+package schedule
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+// DefaultProfileName - the name of the profile that is active outside of every scheduled window
+const DefaultProfileName = "default"
+
+var weekdays = map[string]time.Weekday{
+	"sun": time.Sunday, "sunday": time.Sunday,
+	"mon": time.Monday, "monday": time.Monday,
+	"tue": time.Tuesday, "tuesday": time.Tuesday,
+	"wed": time.Wednesday, "wednesday": time.Wednesday,
+	"thu": time.Thursday, "thursday": time.Thursday,
+	"fri": time.Friday, "friday": time.Friday,
+	"sat": time.Saturday, "saturday": time.Saturday,
+}
+
+// Profile - a watermark and headroom thresholds that apply during a weekly window
+type Profile struct {
+	Name      string   `json:"name"`
+	Days      []string `json:"days,omitempty"`
+	Start     string   `json:"start,omitempty"`
+	End       string   `json:"end,omitempty"`
+	Watermark string   `json:"watermark,omitempty"`
+	// PoolWatermarks - pattern=watermark pairs in the form of POOL_WATERMARKS, matched before the pool watermarks
+	// that are not scheduled
+	PoolWatermarks          string   `json:"pool_watermarks,omitempty"`
+	HeadroomWarningPercent  *float64 `json:"headroom_warning_percent,omitempty"`
+	HeadroomCriticalPercent *float64 `json:"headroom_critical_percent,omitempty"`
+	days                    map[time.Weekday]bool
+	start                   int
+	end                     int
+}
+
+// Schedule - an ordered list of profiles, the first profile whose window contains a time is active at that time
+type Schedule struct {
+	Profiles []Profile
+}
+
+// Parse - parses a JSON list of profiles, e.g.
+// [{"name":"weekend","days":["sat","sun"],"start":"00:00","end":"24:00","watermark":"20%","headroom_warning_percent":10}]
+func Parse(profiles string) (*Schedule, error) {
+	schedule := &Schedule{}
+	if strings.TrimSpace(profiles) == "" {
+		return schedule, nil
+	}
+	if err := json.Unmarshal([]byte(profiles), &schedule.Profiles); err != nil {
+		return nil, fmt.Errorf("profiles must be a JSON list: %v", err)
+	}
+	for i := range schedule.Profiles {
+		if err := schedule.Profiles[i].parse(); err != nil {
+			return nil, err
+		}
+	}
+	return schedule, nil
+}
+
+func (p *Profile) parse() error {
+	if p.Name == "" || p.Name == DefaultProfileName {
+		return fmt.Errorf("profile name %q must be set and must not be %q", p.Name, DefaultProfileName)
+	}
+	p.days = make(map[time.Weekday]bool)
+	for _, day := range p.Days {
+		weekday, ok := weekdays[strings.ToLower(day)]
+		if !ok {
+			return fmt.Errorf("profile %s has an invalid day %q", p.Name, day)
+		}
+		p.days[weekday] = true
+	}
+	if len(p.Days) == 0 {
+		for _, weekday := range weekdays {
+			p.days[weekday] = true
+		}
+	}
+
+	var err error
+	if p.start, err = parseMinutes(p.Start, 0); err != nil {
+		return fmt.Errorf("profile %s has an invalid start: %v", p.Name, err)
+	}
+	if p.end, err = parseMinutes(p.End, 24*60); err != nil {
+		return fmt.Errorf("profile %s has an invalid end: %v", p.Name, err)
+	}
+	if p.start == p.end {
+		return fmt.Errorf("profile %s starts and ends at the same time", p.Name)
+	}
+	return nil
+}
+
+// parseMinutes - parses a HH:MM time of day into minutes past midnight
+func parseMinutes(timeOfDay string, defaultMinutes int) (int, error) {
+	if timeOfDay == "" {
+		return defaultMinutes, nil
+	}
+	var hours, minutes int
+	if _, err := fmt.Sscanf(timeOfDay, "%d:%d", &hours, &minutes); err != nil || hours < 0 || minutes < 0 || minutes > 59 ||
+		hours*60+minutes > 24*60 {
+		return 0, fmt.Errorf("%q must be a time of day in the form HH:MM", timeOfDay)
+	}
+	return hours*60 + minutes, nil
+}
+
+// contains - returns true if the time falls within the profile's window, windows may span midnight
+func (p *Profile) contains(t time.Time) bool {
+	minutes := t.Hour()*60 + t.Minute()
+	if p.start < p.end {
+		return p.days[t.Weekday()] && minutes >= p.start && minutes < p.end
+	}
+	yesterday := t.AddDate(0, 0, -1).Weekday()
+	return (p.days[t.Weekday()] && minutes >= p.start) || (p.days[yesterday] && minutes < p.end)
+}
+
+// Active - returns the profile active at the given time, or a default profile when no window contains it
+func (s *Schedule) Active(t time.Time) Profile {
+	for _, profile := range s.Profiles {
+		if profile.contains(t) {
+			return profile
+		}
+	}
+	return Profile{Name: DefaultProfileName}
+}
+
+// NextSwitch - returns when the active profile will next change and the profile that will then be active,
+// ok is false if the active profile never changes
+func (s *Schedule) NextSwitch(t time.Time) (switchTime time.Time, next Profile, ok bool) {
+	current := s.Active(t).Name
+	midnight := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, t.Location())
+
+	var candidates []time.Time
+	for day := 0; day <= 8; day++ {
+		for _, profile := range s.Profiles {
+			for _, minutes := range []int{profile.start, profile.end} {
+				candidate := midnight.AddDate(0, 0, day).Add(time.Duration(minutes) * time.Minute)
+				if candidate.After(t) {
+					candidates = append(candidates, candidate)
+				}
+			}
+		}
+	}
+	sort.Slice(candidates, func(i, j int) bool { return candidates[i].Before(candidates[j]) })
+
+	for _, candidate := range candidates {
+		if profile := s.Active(candidate); profile.Name != current {
+			return candidate, profile, true
+		}
+	}
+	return time.Time{}, Profile{}, false
+}
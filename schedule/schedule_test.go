@@ -0,0 +1,106 @@
+package schedule_test
+
+import (
+	"github.com/FidelityInternational/diego-capacity-monitor/schedule"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"time"
+)
+
+var _ = Describe("Schedule", func() {
+	var (
+		profiles    string
+		s           *schedule.Schedule
+		err         error
+		at          = func(day int, hour int, minute int) time.Time { return time.Date(2016, 11, day, hour, minute, 0, 0, time.UTC) }
+		wednesday10 = at(2, 10, 0)
+		saturday10  = at(5, 10, 0)
+	)
+
+	JustBeforeEach(func() {
+		s, err = schedule.Parse(profiles)
+	})
+
+	Context("when no profiles are supplied", func() {
+		BeforeEach(func() {
+			profiles = ""
+		})
+
+		It("always returns the default profile", func() {
+			Ω(err).Should(BeNil())
+			Ω(s.Active(saturday10).Name).Should(Equal("default"))
+			_, _, ok := s.NextSwitch(saturday10)
+			Ω(ok).Should(BeFalse())
+		})
+	})
+
+	Context("when profiles are supplied", func() {
+		BeforeEach(func() {
+			profiles = `[
+  {"name": "weekend", "days": ["sat", "Sunday"], "watermark": "20%", "headroom_warning_percent": 10},
+  {"name": "hotfix", "days": ["mon", "tue", "wed", "thu", "fri"], "start": "22:00", "end": "02:00", "watermark": "1"}
+]`
+		})
+
+		It("parses the profiles", func() {
+			Ω(err).Should(BeNil())
+			Ω(s.Profiles).Should(HaveLen(2))
+			Ω(*s.Profiles[0].HeadroomWarningPercent).Should(Equal(float64(10)))
+			Ω(s.Profiles[0].HeadroomCriticalPercent).Should(BeNil())
+		})
+
+		It("returns the profile whose window contains the time", func() {
+			Ω(s.Active(saturday10).Name).Should(Equal("weekend"))
+			Ω(s.Active(at(6, 23, 59)).Name).Should(Equal("weekend"))
+			Ω(s.Active(wednesday10).Name).Should(Equal("default"))
+			Ω(s.Active(at(2, 22, 0)).Name).Should(Equal("hotfix"))
+			Ω(s.Active(at(3, 1, 59)).Name).Should(Equal("hotfix"))
+			Ω(s.Active(at(3, 2, 0)).Name).Should(Equal("default"))
+		})
+
+		It("returns a window spanning midnight on the day after the last day", func() {
+			Ω(s.Active(at(5, 1, 0)).Name).Should(Equal("weekend"))
+			Ω(s.Active(at(7, 1, 0)).Name).Should(Equal("default"))
+			Ω(s.Active(at(8, 1, 0)).Name).Should(Equal("hotfix"))
+		})
+
+		It("returns the next time the active profile changes", func() {
+			switchTime, next, ok := s.NextSwitch(wednesday10)
+			Ω(ok).Should(BeTrue())
+			Ω(switchTime).Should(Equal(at(2, 22, 0)))
+			Ω(next.Name).Should(Equal("hotfix"))
+
+			switchTime, next, ok = s.NextSwitch(saturday10)
+			Ω(ok).Should(BeTrue())
+			Ω(switchTime).Should(Equal(at(7, 0, 0)))
+			Ω(next.Name).Should(Equal("default"))
+		})
+	})
+
+	Context("when the profiles are invalid", func() {
+		It("returns an error for invalid JSON", func() {
+			_, err := schedule.Parse(`{"name": "weekend"}`)
+			Ω(err).Should(MatchError(ContainSubstring("profiles must be a JSON list")))
+		})
+
+		It("returns an error for a missing name", func() {
+			_, err := schedule.Parse(`[{"days": ["sat"]}]`)
+			Ω(err).Should(MatchError(`profile name "" must be set and must not be "default"`))
+		})
+
+		It("returns an error for an invalid day", func() {
+			_, err := schedule.Parse(`[{"name": "weekend", "days": ["caturday"]}]`)
+			Ω(err).Should(MatchError(`profile weekend has an invalid day "caturday"`))
+		})
+
+		It("returns an error for an invalid time", func() {
+			_, err := schedule.Parse(`[{"name": "weekend", "start": "25:00"}]`)
+			Ω(err).Should(MatchError(`profile weekend has an invalid start: "25:00" must be a time of day in the form HH:MM`))
+		})
+
+		It("returns an error for an empty window", func() {
+			_, err := schedule.Parse(`[{"name": "weekend", "start": "10:00", "end": "10:00"}]`)
+			Ω(err).Should(MatchError(`profile weekend starts and ends at the same time`))
+		})
+	})
+})
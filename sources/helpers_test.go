@@ -0,0 +1,22 @@
+package sources_test
+
+import (
+	"encoding/json"
+	"github.com/FidelityInternational/diego-capacity-monitor/mtls/mtlstest"
+	"github.com/FidelityInternational/diego-capacity-monitor/sources"
+	"net/http"
+	"net/http/httptest"
+)
+
+// newRep - starts a TLS stand-in for a rep that requires client certificates signed by the CA and responds to
+// /state with the state
+func newRep(ca *mtlstest.Certificate, state sources.RepState) *httptest.Server {
+	return mtlstest.NewServer(ca, "cell.service.cf.internal", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != sources.RepStateRoute || r.Method != "GET" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(state)
+	}))
+}
@@ -0,0 +1,201 @@
+package sources
+
+import (
+	"crypto/tls"
+	"encoding/json"
+	"fmt"
+	metricsLib "github.com/FidelityInternational/diego-capacity-monitor/metrics"
+	"math"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// LogCacheQueryRoute - the Log Cache route that evaluates PromQL instant queries
+const LogCacheQueryRoute = "/api/v1/query"
+
+// The rep gauges that are queried from Log Cache
+const (
+	CapacityRemainingMemory     = "CapacityRemainingMemory"
+	CapacityTotalMemory         = "CapacityTotalMemory"
+	CapacityRemainingDisk       = "CapacityRemainingDisk"
+	CapacityTotalDisk           = "CapacityTotalDisk"
+	CapacityRemainingContainers = "CapacityRemainingContainers"
+	CapacityTotalContainers     = "CapacityTotalContainers"
+)
+
+// LogCacheConfig - how to reach Log Cache, Token returns the Authorization header for each poll
+type LogCacheConfig struct {
+	URL string
+	// SourceID - the source id the rep's gauges are stored under, defaults to rep
+	SourceID          string
+	Token             func() (string, error)
+	SkipSSLValidation bool
+	Timeout           time.Duration
+}
+
+// LogCacheSource - queries Log Cache for the rep gauges of each cell
+type LogCacheSource struct {
+	url        string
+	sourceID   string
+	token      func() (string, error)
+	httpClient *http.Client
+}
+
+type promQLSample struct {
+	Metric map[string]string `json:"metric"`
+	Value  []interface{}     `json:"value"`
+}
+
+type promQLResponse struct {
+	Status string `json:"status"`
+	Error  string `json:"error"`
+	Data   struct {
+		ResultType string         `json:"resultType"`
+		Result     []promQLSample `json:"result"`
+	} `json:"data"`
+}
+
+// NewLogCacheSource - creates a LogCacheSource from the config
+func NewLogCacheSource(config LogCacheConfig) (*LogCacheSource, error) {
+	if config.URL == "" {
+		return nil, fmt.Errorf("the Log Cache URL must be set")
+	}
+	sourceID := config.SourceID
+	if sourceID == "" {
+		sourceID = "rep"
+	}
+	timeout := config.Timeout
+	if timeout == 0 {
+		timeout = 10 * time.Second
+	}
+	return &LogCacheSource{
+		url:      strings.TrimSuffix(config.URL, "/"),
+		sourceID: sourceID,
+		token:    config.Token,
+		httpClient: &http.Client{Timeout: timeout, Transport: &http.Transport{
+			TLSClientConfig: &tls.Config{InsecureSkipVerify: config.SkipSSLValidation},
+		}},
+	}, nil
+}
+
+// Poll - queries each gauge and returns a sample for every cell that reports its remaining memory
+func (s *LogCacheSource) Poll() ([]Sample, error) {
+	token := ""
+	if s.token != nil {
+		var err error
+		token, err = s.token()
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	samples := make(map[string]*Sample)
+	for _, name := range []string{CapacityRemainingMemory, CapacityTotalMemory, CapacityRemainingDisk, CapacityTotalDisk,
+		CapacityRemainingContainers, CapacityTotalContainers} {
+		results, err := s.query(fmt.Sprintf("%s{source_id=%q}", name, s.sourceID), token)
+		if err != nil {
+			return nil, fmt.Errorf("could not query %s from Log Cache: %v", name, err)
+		}
+		for _, result := range results {
+			cell := result.Metric["index"]
+			timestamp, value, err := result.point()
+			if cell == "" || err != nil {
+				continue
+			}
+			sample, ok := samples[cell]
+			if !ok {
+				sample = &Sample{Cell: cell}
+				samples[cell] = sample
+			}
+			sample.set(name, value, timestamp, result.Metric)
+		}
+	}
+
+	var polled []Sample
+	for _, sample := range samples {
+		if sample.Timestamp == 0 {
+			// A cell is only reported once its remaining memory is known
+			continue
+		}
+		polled = append(polled, *sample)
+	}
+	sort.Slice(polled, func(i, j int) bool { return polled[i].Cell < polled[j].Cell })
+	return polled, nil
+}
+
+// query - evaluates a PromQL instant query, returning its vector
+func (s *LogCacheSource) query(query string, token string) ([]promQLSample, error) {
+	request, err := http.NewRequest("GET", s.url+LogCacheQueryRoute+"?query="+url.QueryEscape(query), nil)
+	if err != nil {
+		return nil, err
+	}
+	if token != "" {
+		request.Header.Set("Authorization", token)
+	}
+	response, err := s.httpClient.Do(request)
+	if err != nil {
+		return nil, err
+	}
+	defer response.Body.Close()
+	var result promQLResponse
+	if err := json.NewDecoder(response.Body).Decode(&result); err != nil {
+		return nil, fmt.Errorf("Log Cache responded with status %d: %v", response.StatusCode, err)
+	}
+	if response.StatusCode != http.StatusOK || result.Status != "success" {
+		return nil, fmt.Errorf("Log Cache responded with status %d: %s", response.StatusCode, result.Error)
+	}
+	if result.Data.ResultType != "vector" {
+		return nil, fmt.Errorf("Log Cache returned a %s rather than a vector", result.Data.ResultType)
+	}
+	return result.Data.Result, nil
+}
+
+// point - returns the timestamp in nanoseconds and the value of an instant vector sample
+func (p promQLSample) point() (int64, float64, error) {
+	if len(p.Value) != 2 {
+		return 0, 0, fmt.Errorf("the sample does not have a timestamp and a value")
+	}
+	seconds, ok := p.Value[0].(float64)
+	if !ok {
+		return 0, 0, fmt.Errorf("the sample's timestamp is not a number")
+	}
+	text, ok := p.Value[1].(string)
+	if !ok {
+		return 0, 0, fmt.Errorf("the sample's value is not a string")
+	}
+	value, err := strconv.ParseFloat(text, 64)
+	if err != nil {
+		return 0, 0, err
+	}
+	return int64(math.Round(seconds * float64(time.Second))), value, nil
+}
+
+// set - sets the value of the named rep gauge, the cell's labels and timestamp are taken from its remaining memory
+func (sample *Sample) set(name string, value float64, timestamp int64, labels map[string]string) {
+	if name != CapacityRemainingMemory && name != CapacityTotalMemory && sample.Resources == nil {
+		sample.Resources = &metricsLib.Resources{}
+	}
+	switch name {
+	case CapacityRemainingMemory:
+		sample.RemainingMemory = value
+		sample.Timestamp = timestamp
+		sample.Zone = ZoneFromTags(labels)
+		sample.Deployment = labels["deployment"]
+		sample.Job = labels["job"]
+		sample.PlacementTags = PlacementTagsFromTags(labels)
+	case CapacityTotalMemory:
+		sample.TotalMemory = value
+	case CapacityRemainingDisk:
+		sample.Resources.RemainingDisk = value
+	case CapacityTotalDisk:
+		sample.Resources.TotalDisk = value
+	case CapacityRemainingContainers:
+		sample.Resources.RemainingContainers = value
+	case CapacityTotalContainers:
+		sample.Resources.TotalContainers = value
+	}
+}
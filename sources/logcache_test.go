@@ -0,0 +1,147 @@
+package sources_test
+
+import (
+	"fmt"
+	metricsLib "github.com/FidelityInternational/diego-capacity-monitor/metrics"
+	"github.com/FidelityInternational/diego-capacity-monitor/sources"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+)
+
+// newLogCache - starts a fake Log Cache that answers each query with the vector of the queried metric
+func newLogCache(vectors map[string]string, authorizations *[]string) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		*authorizations = append(*authorizations, r.Header.Get("Authorization"))
+		if r.URL.Path != sources.LogCacheQueryRoute {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		query := r.URL.Query().Get("query")
+		name := strings.SplitN(query, "{", 2)[0]
+		if !strings.HasSuffix(query, `{source_id="rep"}`) {
+			w.WriteHeader(http.StatusBadRequest)
+			fmt.Fprint(w, `{"status":"error","errorType":"bad_data","error":"unexpected query"}`)
+			return
+		}
+		fmt.Fprintf(w, `{"status":"success","data":{"resultType":"vector","result":[%s]}}`, vectors[name])
+	}))
+}
+
+var _ = Describe("LogCache", func() {
+	var (
+		server         *httptest.Server
+		vectors        map[string]string
+		authorizations []string
+		config         sources.LogCacheConfig
+	)
+
+	BeforeEach(func() {
+		authorizations = nil
+		vectors = map[string]string{
+			sources.CapacityRemainingMemory: `{"metric":{"index":"cell-1","deployment":"cf","job":"diego_cell","az":"z1","placement_tags":"iso"},"value":[1600000000.5,"4096"]},
+				{"metric":{"index":"cell-2","deployment":"cf","job":"diego_cell","az":"z2"},"value":[1600000001,"8192"]}`,
+			sources.CapacityTotalMemory:         `{"metric":{"index":"cell-1"},"value":[1600000000,"16384"]},{"metric":{"index":"cell-2"},"value":[1600000000,"16384"]}`,
+			sources.CapacityRemainingDisk:       `{"metric":{"index":"cell-1"},"value":[1600000000,"10240"]}`,
+			sources.CapacityTotalDisk:           `{"metric":{"index":"cell-1"},"value":[1600000000,"65536"]}`,
+			sources.CapacityRemainingContainers: `{"metric":{"index":"cell-1"},"value":[1600000000,"200"]}`,
+			sources.CapacityTotalContainers:     `{"metric":{"index":"cell-1"},"value":[1600000000,"250"]},{"metric":{"index":"cell-3"},"value":[1600000000,"250"]}`,
+		}
+	})
+
+	JustBeforeEach(func() {
+		server = newLogCache(vectors, &authorizations)
+		config = sources.LogCacheConfig{URL: server.URL, Token: func() (string, error) { return "bearer token", nil }}
+	})
+
+	AfterEach(func() {
+		server.Close()
+	})
+
+	Describe("NewLogCacheSource", func() {
+		It("requires the URL", func() {
+			_, err := sources.NewLogCacheSource(sources.LogCacheConfig{})
+			Ω(err).Should(MatchError("the Log Cache URL must be set"))
+		})
+	})
+
+	Describe("#Poll", func() {
+		It("returns a sample for each cell reporting its remaining memory", func() {
+			source, err := sources.NewLogCacheSource(config)
+			Ω(err).Should(BeNil())
+			samples, err := source.Poll()
+			Ω(err).Should(BeNil())
+			Ω(samples).Should(Equal([]sources.Sample{
+				{Cell: "cell-1", RemainingMemory: 4096, TotalMemory: 16384, Zone: "z1", Deployment: "cf", Job: "diego_cell",
+					PlacementTags: []string{"iso"}, Timestamp: 1600000000500000000,
+					Resources: &metricsLib.Resources{RemainingDisk: 10240, TotalDisk: 65536, RemainingContainers: 200, TotalContainers: 250}},
+				{Cell: "cell-2", RemainingMemory: 8192, TotalMemory: 16384, Zone: "z2", Deployment: "cf", Job: "diego_cell",
+					Timestamp: 1600000001000000000},
+			}))
+		})
+
+		It("authorizes each query with the token", func() {
+			source, err := sources.NewLogCacheSource(config)
+			Ω(err).Should(BeNil())
+			_, err = source.Poll()
+			Ω(err).Should(BeNil())
+			Ω(authorizations).Should(HaveLen(6))
+			for _, authorization := range authorizations {
+				Ω(authorization).Should(Equal("bearer token"))
+			}
+		})
+
+		Context("when the source id is not the rep's", func() {
+			It("returns the error from Log Cache", func() {
+				config.SourceID = "other"
+				source, err := sources.NewLogCacheSource(config)
+				Ω(err).Should(BeNil())
+				_, err = source.Poll()
+				Ω(err).Should(MatchError("could not query CapacityRemainingMemory from Log Cache: Log Cache responded with status 400: unexpected query"))
+			})
+		})
+
+		Context("when Log Cache responds with a body that is not JSON", func() {
+			BeforeEach(func() {
+				vectors[sources.CapacityRemainingMemory] = `not JSON`
+			})
+
+			It("returns the decode error along with the status", func() {
+				source, err := sources.NewLogCacheSource(config)
+				Ω(err).Should(BeNil())
+				_, err = source.Poll()
+				Ω(err).Should(MatchError("could not query CapacityRemainingMemory from Log Cache: Log Cache responded with status 200: " +
+					"invalid character 'o' in literal null (expecting 'u')"))
+			})
+		})
+
+		Context("when the token cannot be fetched", func() {
+			It("returns the error", func() {
+				config.Token = func() (string, error) { return "", fmt.Errorf("no token") }
+				source, err := sources.NewLogCacheSource(config)
+				Ω(err).Should(BeNil())
+				_, err = source.Poll()
+				Ω(err).Should(MatchError("no token"))
+				Ω(authorizations).Should(BeEmpty())
+			})
+		})
+
+		Context("when a value is not a number", func() {
+			BeforeEach(func() {
+				vectors[sources.CapacityRemainingMemory] = `{"metric":{"index":"cell-1"},"value":[1600000000,"NaN?"]},
+					{"metric":{"index":"cell-2"},"value":[1600000000,"8192"]}`
+			})
+
+			It("skips the cell", func() {
+				source, err := sources.NewLogCacheSource(config)
+				Ω(err).Should(BeNil())
+				samples, err := source.Poll()
+				Ω(err).Should(BeNil())
+				Ω(samples).Should(HaveLen(1))
+				Ω(samples[0].Cell).Should(Equal("cell-2"))
+			})
+		})
+	})
+})
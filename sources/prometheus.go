@@ -0,0 +1,246 @@
+package sources
+
+import (
+	"bufio"
+	"crypto/tls"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// DefaultPrometheusPrefix - the prefix the firehose-exporter gives the rep's value metrics
+const DefaultPrometheusPrefix = "firehose_value_metric_rep_"
+
+// prometheusGauges - the rep gauges by their name in the exposition, without the prefix
+var prometheusGauges = map[string]string{
+	"capacity_remaining_memory":     CapacityRemainingMemory,
+	"capacity_total_memory":         CapacityTotalMemory,
+	"capacity_remaining_disk":       CapacityRemainingDisk,
+	"capacity_total_disk":           CapacityTotalDisk,
+	"capacity_remaining_containers": CapacityRemainingContainers,
+	"capacity_total_containers":     CapacityTotalContainers,
+}
+
+// prometheusLabels - the labels each field of a sample is read from, the first that is present is used
+var prometheusLabels = map[string][]string{
+	"index":      {"index", "bosh_job_id"},
+	"ip":         {"ip", "bosh_job_ip"},
+	"deployment": {"deployment", "bosh_deployment"},
+	"job":        {"job", "bosh_job_name"},
+	"az":         {"az", "zone", "bosh_job_az"},
+}
+
+// PrometheusConfig - how to scrape a Prometheus exposition of the rep gauges, such as the firehose-exporter's
+type PrometheusConfig struct {
+	URL string
+	// Prefix - the prefix of the rep gauges' names, defaults to DefaultPrometheusPrefix
+	Prefix            string
+	Username          string
+	Password          string
+	SkipSSLValidation bool
+	Timeout           time.Duration
+}
+
+// PrometheusSource - scrapes the rep gauges of each cell from a Prometheus exposition
+type PrometheusSource struct {
+	config     PrometheusConfig
+	httpClient *http.Client
+}
+
+type prometheusSample struct {
+	name      string
+	labels    map[string]string
+	value     float64
+	timestamp int64
+}
+
+// NewPrometheusSource - creates a PrometheusSource from the config
+func NewPrometheusSource(config PrometheusConfig) (*PrometheusSource, error) {
+	if config.URL == "" {
+		return nil, fmt.Errorf("the Prometheus URL must be set")
+	}
+	if config.Prefix == "" {
+		config.Prefix = DefaultPrometheusPrefix
+	}
+	timeout := config.Timeout
+	if timeout == 0 {
+		timeout = 10 * time.Second
+	}
+	return &PrometheusSource{
+		config: config,
+		httpClient: &http.Client{Timeout: timeout, Transport: &http.Transport{
+			TLSClientConfig: &tls.Config{InsecureSkipVerify: config.SkipSSLValidation},
+		}},
+	}, nil
+}
+
+// Poll - scrapes the exposition and returns a sample for every cell that reports its remaining memory
+func (s *PrometheusSource) Poll() ([]Sample, error) {
+	request, err := http.NewRequest("GET", s.config.URL, nil)
+	if err != nil {
+		return nil, err
+	}
+	if s.config.Username != "" {
+		request.SetBasicAuth(s.config.Username, s.config.Password)
+	}
+	request.Header.Set("Accept", "text/plain")
+	response, err := s.httpClient.Do(request)
+	if err != nil {
+		return nil, err
+	}
+	defer response.Body.Close()
+	if response.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("%s responded with status %d", s.config.URL, response.StatusCode)
+	}
+	scraped, err := parsePrometheusText(response.Body)
+	if err != nil {
+		return nil, fmt.Errorf("could not parse the metrics from %s: %v", s.config.URL, err)
+	}
+
+	now := time.Now().UnixNano()
+	samples := make(map[string]*Sample)
+	for _, scrapedSample := range scraped {
+		name, ok := prometheusGauges[strings.TrimPrefix(scrapedSample.name, s.config.Prefix)]
+		if !ok || !strings.HasPrefix(scrapedSample.name, s.config.Prefix) {
+			continue
+		}
+		labels := make(map[string]string)
+		for field, names := range prometheusLabels {
+			for _, label := range names {
+				if value, ok := scrapedSample.labels[label]; ok {
+					labels[field] = value
+					break
+				}
+			}
+		}
+		labels["placement_tags"] = scrapedSample.labels["placement_tags"]
+		// A cell is identified by its index, or its IP when the exporter does not label the index
+		cell := labels["index"]
+		if cell == "" {
+			cell = labels["ip"]
+		}
+		if cell == "" {
+			continue
+		}
+		sample, ok := samples[cell]
+		if !ok {
+			sample = &Sample{Cell: cell}
+			samples[cell] = sample
+		}
+		timestamp := scrapedSample.timestamp
+		if timestamp == 0 {
+			timestamp = now
+		}
+		sample.set(name, scrapedSample.value, timestamp, labels)
+	}
+
+	var polled []Sample
+	for _, sample := range samples {
+		if sample.Timestamp == 0 {
+			continue
+		}
+		polled = append(polled, *sample)
+	}
+	sort.Slice(polled, func(i, j int) bool { return polled[i].Cell < polled[j].Cell })
+	return polled, nil
+}
+
+// parsePrometheusText - parses the samples of a Prometheus text exposition, comments and type hints are skipped
+// and timestamps are converted from milliseconds to nanoseconds
+func parsePrometheusText(reader io.Reader) ([]prometheusSample, error) {
+	var samples []prometheusSample
+	scanner := bufio.NewScanner(reader)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for lineNumber := 1; scanner.Scan(); lineNumber++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		sample, err := parsePrometheusLine(line)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %v", lineNumber, err)
+		}
+		samples = append(samples, sample)
+	}
+	return samples, scanner.Err()
+}
+
+// parsePrometheusLine - parses a single sample of the form name{label="value",...} value [timestamp]
+func parsePrometheusLine(line string) (prometheusSample, error) {
+	sample := prometheusSample{labels: make(map[string]string)}
+	end := strings.IndexAny(line, "{ \t")
+	if end <= 0 {
+		return sample, fmt.Errorf("%q is not a sample", line)
+	}
+	sample.name, line = line[:end], line[end:]
+	if strings.HasPrefix(line, "{") {
+		var err error
+		line, err = parsePrometheusLabels(line[1:], sample.labels)
+		if err != nil {
+			return sample, err
+		}
+	}
+	fields := strings.Fields(line)
+	if len(fields) < 1 || len(fields) > 2 {
+		return sample, fmt.Errorf("%s does not have a value and an optional timestamp", sample.name)
+	}
+	var err error
+	sample.value, err = strconv.ParseFloat(fields[0], 64)
+	if err != nil {
+		return sample, fmt.Errorf("the value of %s is not a number: %v", sample.name, err)
+	}
+	if len(fields) == 2 {
+		milliseconds, err := strconv.ParseInt(fields[1], 10, 64)
+		if err != nil {
+			return sample, fmt.Errorf("the timestamp of %s is not a number: %v", sample.name, err)
+		}
+		sample.timestamp = milliseconds * int64(time.Millisecond)
+	}
+	return sample, nil
+}
+
+// parsePrometheusLabels - parses the labels up to and including the closing brace, returning the rest of the line
+func parsePrometheusLabels(line string, labels map[string]string) (string, error) {
+	for {
+		line = strings.TrimLeft(line, " \t,")
+		if strings.HasPrefix(line, "}") {
+			return line[1:], nil
+		}
+		equals := strings.Index(line, "=")
+		if equals <= 0 || len(line) < equals+2 || line[equals+1] != '"' {
+			return "", fmt.Errorf("the labels are malformed")
+		}
+		name := strings.TrimSpace(line[:equals])
+		line = line[equals+2:]
+		var value strings.Builder
+		closed := false
+		for i := 0; i < len(line); i++ {
+			switch {
+			case line[i] == '\\' && i+1 < len(line):
+				i++
+				switch line[i] {
+				case 'n':
+					value.WriteByte('\n')
+				default:
+					value.WriteByte(line[i])
+				}
+			case line[i] == '"':
+				closed = true
+				line = line[i+1:]
+			default:
+				value.WriteByte(line[i])
+			}
+			if closed {
+				break
+			}
+		}
+		if !closed {
+			return "", fmt.Errorf("the value of label %s is not closed", name)
+		}
+		labels[name] = value.String()
+	}
+}
@@ -0,0 +1,133 @@
+package sources_test
+
+import (
+	metricsLib "github.com/FidelityInternational/diego-capacity-monitor/metrics"
+	"github.com/FidelityInternational/diego-capacity-monitor/sources"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"net/http"
+	"net/http/httptest"
+)
+
+var _ = Describe("Prometheus", func() {
+	var (
+		server     *httptest.Server
+		exposition string
+		status     int
+		config     sources.PrometheusConfig
+	)
+
+	BeforeEach(func() {
+		status = http.StatusOK
+		exposition = `# HELP firehose_value_metric_rep_capacity_remaining_memory Cloud Foundry Firehose 'CapacityRemainingMemory' value metric from 'rep'.
+# TYPE firehose_value_metric_rep_capacity_remaining_memory gauge
+firehose_value_metric_rep_capacity_remaining_memory{bosh_deployment="cf",bosh_job_id="cell-1",bosh_job_ip="10.0.16.5",bosh_job_name="diego_cell",az="z1",placement_tags="iso,\"quoted\""} 4096 1600000000500
+firehose_value_metric_rep_capacity_remaining_memory{deployment="cf",job="diego_cell",index="cell-2",ip="10.0.16.6",az="z2"} 8192
+firehose_value_metric_rep_capacity_remaining_memory{ip="10.0.16.7"} 1024
+firehose_value_metric_rep_capacity_total_memory{bosh_job_id="cell-1"} 16384
+firehose_value_metric_rep_capacity_remaining_disk{bosh_job_id="cell-1"} 10240
+firehose_value_metric_rep_capacity_total_disk{bosh_job_id="cell-1"} 65536
+firehose_value_metric_rep_capacity_remaining_containers{bosh_job_id="cell-1"} 200
+firehose_value_metric_rep_capacity_total_containers{bosh_job_id="cell-1"} 250
+firehose_value_metric_rep_capacity_total_containers{bosh_job_id="cell-4"} 250
+firehose_value_metric_auctioneer_auctioneer_lrp_auctions_failed{bosh_job_id="auctioneer-1"} 2
+go_goroutines 12
+`
+	})
+
+	JustBeforeEach(func() {
+		server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if username, password, ok := r.BasicAuth(); config.Username != "" && (!ok || username != "admin" || password != "secret") {
+				w.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+			w.WriteHeader(status)
+			w.Write([]byte(exposition))
+		}))
+		config.URL = server.URL + "/metrics"
+	})
+
+	AfterEach(func() {
+		server.Close()
+		config = sources.PrometheusConfig{}
+	})
+
+	Describe("NewPrometheusSource", func() {
+		It("requires the URL", func() {
+			_, err := sources.NewPrometheusSource(sources.PrometheusConfig{})
+			Ω(err).Should(MatchError("the Prometheus URL must be set"))
+		})
+	})
+
+	Describe("#Poll", func() {
+		It("returns a sample for each cell reporting its remaining memory", func() {
+			source, err := sources.NewPrometheusSource(config)
+			Ω(err).Should(BeNil())
+			samples, err := source.Poll()
+			Ω(err).Should(BeNil())
+			Ω(samples).Should(HaveLen(3))
+			Ω(samples[0]).Should(Equal(sources.Sample{Cell: "10.0.16.7", RemainingMemory: 1024, Timestamp: samples[0].Timestamp}))
+			Ω(samples[0].Timestamp).ShouldNot(BeZero())
+			Ω(samples[1]).Should(Equal(sources.Sample{Cell: "cell-1", RemainingMemory: 4096, TotalMemory: 16384, Zone: "z1",
+				Deployment: "cf", Job: "diego_cell", PlacementTags: []string{"iso", `"quoted"`}, Timestamp: 1600000000500000000,
+				Resources: &metricsLib.Resources{RemainingDisk: 10240, TotalDisk: 65536, RemainingContainers: 200, TotalContainers: 250}}))
+			Ω(samples[2].Cell).Should(Equal("cell-2"))
+			Ω(samples[2].Deployment).Should(Equal("cf"))
+			Ω(samples[2].Job).Should(Equal("diego_cell"))
+			Ω(samples[2].Zone).Should(Equal("z2"))
+		})
+
+		Context("when the exporter uses another prefix", func() {
+			BeforeEach(func() {
+				exposition = `rep_capacity_remaining_memory{index="cell-1"} 4096` + "\n"
+			})
+
+			It("matches the gauges with the prefix", func() {
+				config.Prefix = "rep_"
+				source, err := sources.NewPrometheusSource(config)
+				Ω(err).Should(BeNil())
+				samples, err := source.Poll()
+				Ω(err).Should(BeNil())
+				Ω(samples).Should(HaveLen(1))
+				Ω(samples[0].RemainingMemory).Should(Equal(4096.0))
+			})
+		})
+
+		Context("when the endpoint requires basic auth", func() {
+			It("authenticates with the username and password", func() {
+				config.Username, config.Password = "admin", "secret"
+				source, err := sources.NewPrometheusSource(config)
+				Ω(err).Should(BeNil())
+				samples, err := source.Poll()
+				Ω(err).Should(BeNil())
+				Ω(samples).Should(HaveLen(3))
+			})
+		})
+
+		Context("when the endpoint responds with an error", func() {
+			BeforeEach(func() {
+				status = http.StatusServiceUnavailable
+			})
+
+			It("returns an error", func() {
+				source, err := sources.NewPrometheusSource(config)
+				Ω(err).Should(BeNil())
+				_, err = source.Poll()
+				Ω(err).Should(MatchError(config.URL + " responded with status 503"))
+			})
+		})
+
+		Context("when the exposition is malformed", func() {
+			BeforeEach(func() {
+				exposition = "# TYPE a gauge\n" + `firehose_value_metric_rep_capacity_remaining_memory{index="cell-1} 4096` + "\n"
+			})
+
+			It("returns an error naming the line", func() {
+				source, err := sources.NewPrometheusSource(config)
+				Ω(err).Should(BeNil())
+				_, err = source.Poll()
+				Ω(err).Should(MatchError("could not parse the metrics from " + config.URL + ": line 2: the value of label index is not closed"))
+			})
+		})
+	})
+})
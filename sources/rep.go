@@ -0,0 +1,220 @@
+package sources
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/FidelityInternational/diego-capacity-monitor/bbs"
+	metricsLib "github.com/FidelityInternational/diego-capacity-monitor/metrics"
+	"github.com/FidelityInternational/diego-capacity-monitor/mtls"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// RepStateRoute - the rep's route that reports its resources
+const RepStateRoute = "/state"
+
+// RepResources - an amount of each resource a rep offers
+type RepResources struct {
+	MemoryMB   int32
+	DiskMB     int32
+	Containers int
+}
+
+// RepState - the parts of the rep's /state response the monitor uses
+type RepState struct {
+	CellID             string
+	Zone               string
+	AvailableResources RepResources
+	TotalResources     RepResources
+	PlacementTags      []string
+	Evacuating         bool
+}
+
+// ValidateRepPoolBy - returns an error when cells cannot be grouped into pools by the attribute with the rep source,
+// as a rep's state does not say which deployment or job its cell belongs to
+func ValidateRepPoolBy(poolBy string) error {
+	switch poolBy {
+	case "deployment", "job":
+		return fmt.Errorf("cells polled from their reps cannot be pooled by %s, only by placement_tags", poolBy)
+	}
+	return nil
+}
+
+// RepTarget - a rep to poll, Cell is empty when the cell's id should be taken from its state
+type RepTarget struct {
+	Cell string
+	URL  string
+}
+
+// RepDiscovery - finds the reps to poll
+type RepDiscovery interface {
+	Reps() ([]RepTarget, error)
+}
+
+// StaticReps - a fixed list of rep URLs, https is assumed when a URL has no scheme
+type StaticReps []string
+
+// ParseStaticReps - parses a comma separated list of rep URLs
+func ParseStaticReps(addresses string) StaticReps {
+	var reps StaticReps
+	for _, address := range strings.Split(addresses, ",") {
+		if address = strings.TrimSpace(address); address != "" {
+			reps = append(reps, address)
+		}
+	}
+	return reps
+}
+
+// Reps - returns the reps in the list
+func (s StaticReps) Reps() ([]RepTarget, error) {
+	var targets []RepTarget
+	for _, address := range s {
+		if !strings.Contains(address, "://") {
+			address = "https://" + address
+		}
+		targets = append(targets, RepTarget{URL: address})
+	}
+	return targets, nil
+}
+
+// RegistryReps - the reps of the cells registered with the BBS, preferring their secure URL
+type RegistryReps struct {
+	Registry *bbs.Registry
+}
+
+// Reps - returns the reps of the registered cells
+func (r RegistryReps) Reps() ([]RepTarget, error) {
+	cells, _, ok := r.Registry.Cells()
+	if !ok {
+		if err := r.Registry.Err(); err != nil {
+			return nil, fmt.Errorf("the cells could not be fetched from the BBS: %v", err)
+		}
+		return nil, fmt.Errorf("the cells have not been fetched from the BBS yet")
+	}
+	var targets []RepTarget
+	for id, cell := range cells {
+		url := cell.RepURL
+		if url == "" {
+			url = cell.RepAddress
+		}
+		if url != "" {
+			targets = append(targets, RepTarget{Cell: id, URL: url})
+		}
+	}
+	sort.Slice(targets, func(i, j int) bool { return targets[i].Cell < targets[j].Cell })
+	return targets, nil
+}
+
+// RepConfig - how to reach the reps, the certificates and key are either PEM or the path of a PEM file
+type RepConfig struct {
+	mtls.Config
+	Timeout time.Duration
+	// Concurrency - the most reps that are polled at once
+	Concurrency int
+}
+
+// RepSource - polls the /state of each rep for its cell's capacity
+type RepSource struct {
+	discovery   RepDiscovery
+	httpClient  *http.Client
+	concurrency int
+}
+
+// NewRepSource - creates a RepSource that polls the reps found by the discovery
+func NewRepSource(discovery RepDiscovery, config RepConfig) (*RepSource, error) {
+	tlsConfig, err := config.TLSConfig("rep")
+	if err != nil {
+		return nil, err
+	}
+	timeout := config.Timeout
+	if timeout == 0 {
+		timeout = 10 * time.Second
+	}
+	concurrency := config.Concurrency
+	if concurrency <= 0 {
+		concurrency = 10
+	}
+	return &RepSource{
+		discovery:   discovery,
+		httpClient:  &http.Client{Timeout: timeout, Transport: &http.Transport{TLSClientConfig: tlsConfig}},
+		concurrency: concurrency,
+	}, nil
+}
+
+// Poll - polls every rep, at most the concurrency at once, and returns a sample for each that responded along with
+// an error describing those that did not
+func (s *RepSource) Poll() ([]Sample, error) {
+	targets, err := s.discovery.Reps()
+	if err != nil {
+		return nil, err
+	}
+
+	samples := make([]*Sample, len(targets))
+	errs := make([]error, len(targets))
+	limit := make(chan struct{}, s.concurrency)
+	var wg sync.WaitGroup
+	for i, target := range targets {
+		wg.Add(1)
+		limit <- struct{}{}
+		go func(i int, target RepTarget) {
+			defer wg.Done()
+			defer func() { <-limit }()
+			samples[i], errs[i] = s.poll(target)
+		}(i, target)
+	}
+	wg.Wait()
+
+	var polled []Sample
+	var failures []string
+	for i := range targets {
+		if errs[i] != nil {
+			failures = append(failures, errs[i].Error())
+			continue
+		}
+		polled = append(polled, *samples[i])
+	}
+	if len(failures) > 0 {
+		return polled, fmt.Errorf("%d of %d reps could not be polled: %s", len(failures), len(targets), strings.Join(failures, "; "))
+	}
+	return polled, nil
+}
+
+// poll - fetches the state of a single rep
+func (s *RepSource) poll(target RepTarget) (*Sample, error) {
+	response, err := s.httpClient.Get(strings.TrimSuffix(target.URL, "/") + RepStateRoute)
+	if err != nil {
+		return nil, err
+	}
+	defer response.Body.Close()
+	if response.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("%s responded with status %d", target.URL, response.StatusCode)
+	}
+	var state RepState
+	if err := json.NewDecoder(response.Body).Decode(&state); err != nil {
+		return nil, fmt.Errorf("could not decode the state of %s: %v", target.URL, err)
+	}
+	cell := target.Cell
+	if cell == "" {
+		cell = state.CellID
+	}
+	if cell == "" {
+		return nil, fmt.Errorf("%s did not report its cell id", target.URL)
+	}
+	return &Sample{
+		Cell:            cell,
+		RemainingMemory: float64(state.AvailableResources.MemoryMB),
+		TotalMemory:     float64(state.TotalResources.MemoryMB),
+		Zone:            state.Zone,
+		PlacementTags:   state.PlacementTags,
+		Resources: &metricsLib.Resources{
+			RemainingDisk:       float64(state.AvailableResources.DiskMB),
+			TotalDisk:           float64(state.TotalResources.DiskMB),
+			RemainingContainers: float64(state.AvailableResources.Containers),
+			TotalContainers:     float64(state.TotalResources.Containers),
+		},
+		Timestamp: time.Now().UnixNano(),
+	}, nil
+}
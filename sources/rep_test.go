@@ -0,0 +1,170 @@
+package sources_test
+
+import (
+	"github.com/FidelityInternational/diego-capacity-monitor/bbs"
+	metricsLib "github.com/FidelityInternational/diego-capacity-monitor/metrics"
+	"github.com/FidelityInternational/diego-capacity-monitor/mtls"
+	"github.com/FidelityInternational/diego-capacity-monitor/mtls/mtlstest"
+	"github.com/FidelityInternational/diego-capacity-monitor/sources"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"net/http/httptest"
+	"strings"
+)
+
+type fakeCellLister struct {
+	cells []*bbs.CellPresence
+}
+
+func (f fakeCellLister) Cells() ([]*bbs.CellPresence, error) {
+	return f.cells, nil
+}
+
+var _ = Describe("Rep", func() {
+	var (
+		ca         *mtlstest.Certificate
+		clientCert *mtlstest.Certificate
+		reps       []*httptest.Server
+		config     sources.RepConfig
+	)
+
+	BeforeEach(func() {
+		ca = mtlstest.NewCertificate("rep-ca", nil)
+		clientCert = mtlstest.NewCertificate("diego-capacity-monitor", ca)
+		reps = []*httptest.Server{
+			newRep(ca, sources.RepState{CellID: "cell-1", Zone: "z1", PlacementTags: []string{"iso"},
+				AvailableResources: sources.RepResources{MemoryMB: 4096, DiskMB: 10240, Containers: 200},
+				TotalResources:     sources.RepResources{MemoryMB: 16384, DiskMB: 65536, Containers: 250}}),
+			newRep(ca, sources.RepState{CellID: "cell-2", Zone: "z2",
+				AvailableResources: sources.RepResources{MemoryMB: 8192},
+				TotalResources:     sources.RepResources{MemoryMB: 16384}}),
+		}
+		config = sources.RepConfig{
+			Config: mtls.Config{CACert: string(ca.CertPEM), ClientCert: string(clientCert.CertPEM), ClientKey: string(clientCert.KeyPEM)},
+		}
+	})
+
+	AfterEach(func() {
+		for _, rep := range reps {
+			rep.Close()
+		}
+	})
+
+	Describe("ParseStaticReps", func() {
+		It("parses a comma separated list, assuming https", func() {
+			targets, err := sources.ParseStaticReps(" https://10.0.0.1:1801, 10.0.0.2:1801 ,").Reps()
+			Ω(err).Should(BeNil())
+			Ω(targets).Should(Equal([]sources.RepTarget{{URL: "https://10.0.0.1:1801"}, {URL: "https://10.0.0.2:1801"}}))
+		})
+	})
+
+	Describe("ValidateRepPoolBy", func() {
+		It("allows the cells to be pooled by their placement tags or not at all", func() {
+			Ω(sources.ValidateRepPoolBy("")).Should(Succeed())
+			Ω(sources.ValidateRepPoolBy("placement_tags")).Should(Succeed())
+		})
+
+		It("rejects pooling by deployment or job, which the reps do not report", func() {
+			Ω(sources.ValidateRepPoolBy("deployment")).Should(MatchError("cells polled from their reps cannot be pooled by deployment, only by placement_tags"))
+			Ω(sources.ValidateRepPoolBy("job")).Should(MatchError("cells polled from their reps cannot be pooled by job, only by placement_tags"))
+		})
+	})
+
+	Describe("RegistryReps", func() {
+		It("returns the rep of each registered cell, preferring its secure URL", func() {
+			registry := bbs.CreateRegistry(fakeCellLister{cells: []*bbs.CellPresence{
+				{CellID: "cell-2", RepAddress: "http://10.0.0.2:1800"},
+				{CellID: "cell-1", RepAddress: "http://10.0.0.1:1800", RepURL: "https://cell-1.cell.service.cf.internal:1801"},
+			}})
+			Ω(registry.Refresh()).Should(Succeed())
+			targets, err := sources.RegistryReps{Registry: registry}.Reps()
+			Ω(err).Should(BeNil())
+			Ω(targets).Should(Equal([]sources.RepTarget{
+				{Cell: "cell-1", URL: "https://cell-1.cell.service.cf.internal:1801"},
+				{Cell: "cell-2", URL: "http://10.0.0.2:1800"},
+			}))
+		})
+
+		It("returns an error until the cells have been fetched", func() {
+			_, err := sources.RegistryReps{Registry: bbs.CreateRegistry(fakeCellLister{})}.Reps()
+			Ω(err).Should(MatchError("the cells have not been fetched from the BBS yet"))
+		})
+	})
+
+	Describe("#Poll", func() {
+		It("returns a sample for each rep", func() {
+			source, err := sources.NewRepSource(sources.StaticReps{reps[0].URL, reps[1].URL}, config)
+			Ω(err).Should(BeNil())
+			samples, err := source.Poll()
+			Ω(err).Should(BeNil())
+			Ω(samples).Should(HaveLen(2))
+			Ω(samples[0].Cell).Should(Equal("cell-1"))
+			Ω(samples[0].RemainingMemory).Should(Equal(4096.0))
+			Ω(samples[0].TotalMemory).Should(Equal(16384.0))
+			Ω(samples[0].Zone).Should(Equal("z1"))
+			Ω(samples[0].PlacementTags).Should(Equal([]string{"iso"}))
+			Ω(*samples[0].Resources).Should(Equal(metricsLib.Resources{RemainingDisk: 10240, TotalDisk: 65536, RemainingContainers: 200, TotalContainers: 250}))
+			Ω(samples[0].Timestamp).ShouldNot(BeZero())
+			Ω(samples[1].Cell).Should(Equal("cell-2"))
+			Ω(samples[1].RemainingMemory).Should(Equal(8192.0))
+		})
+
+		It("uses the cell id of the target over the rep's", func() {
+			source, err := sources.NewRepSource(fakeDiscovery{{Cell: "registered-cell", URL: reps[0].URL}}, config)
+			Ω(err).Should(BeNil())
+			samples, err := source.Poll()
+			Ω(err).Should(BeNil())
+			Ω(samples[0].Cell).Should(Equal("registered-cell"))
+		})
+
+		It("polls with a concurrency of one", func() {
+			config.Concurrency = 1
+			source, err := sources.NewRepSource(sources.StaticReps{reps[0].URL, reps[1].URL}, config)
+			Ω(err).Should(BeNil())
+			samples, err := source.Poll()
+			Ω(err).Should(BeNil())
+			Ω(samples).Should(HaveLen(2))
+		})
+
+		Context("when a rep cannot be polled", func() {
+			It("returns the samples of the other reps and an error", func() {
+				reps[1].Close()
+				source, err := sources.NewRepSource(sources.StaticReps{reps[0].URL, reps[1].URL}, config)
+				Ω(err).Should(BeNil())
+				samples, err := source.Poll()
+				Ω(err).Should(HaveOccurred())
+				Ω(err.Error()).Should(HavePrefix("1 of 2 reps could not be polled: "))
+				Ω(samples).Should(HaveLen(1))
+				Ω(samples[0].Cell).Should(Equal("cell-1"))
+			})
+		})
+
+		Context("when the client certificate is not trusted by the rep", func() {
+			It("returns an error", func() {
+				untrusted := mtlstest.NewCertificate("diego-capacity-monitor", mtlstest.NewCertificate("other-ca", nil))
+				config.ClientCert, config.ClientKey = string(untrusted.CertPEM), string(untrusted.KeyPEM)
+				source, err := sources.NewRepSource(sources.StaticReps{reps[0].URL}, config)
+				Ω(err).Should(BeNil())
+				samples, err := source.Poll()
+				Ω(err).Should(HaveOccurred())
+				Ω(samples).Should(BeEmpty())
+			})
+		})
+
+		Context("when the rep responds with an error", func() {
+			It("returns an error", func() {
+				source, err := sources.NewRepSource(sources.StaticReps{strings.TrimSuffix(reps[0].URL, "/") + "/missing"}, config)
+				Ω(err).Should(BeNil())
+				_, err = source.Poll()
+				Ω(err).Should(HaveOccurred())
+				Ω(err.Error()).Should(ContainSubstring("responded with status 404"))
+			})
+		})
+	})
+})
+
+type fakeDiscovery []sources.RepTarget
+
+func (f fakeDiscovery) Reps() ([]sources.RepTarget, error) {
+	return f, nil
+}
@@ -0,0 +1,90 @@
+package sources
+
+import (
+	"fmt"
+	metricsLib "github.com/FidelityInternational/diego-capacity-monitor/metrics"
+	"strings"
+	"time"
+)
+
+// Sample - the capacity of a cell, the same whichever source it was collected from
+type Sample struct {
+	// Cell - the cell's id, the index of its firehose envelopes
+	Cell string
+	// RemainingMemory - the memory in MB the cell has left to allocate
+	RemainingMemory float64
+	// TotalMemory - the memory in MB the cell offers, 0 when the source does not report it
+	TotalMemory   float64
+	Zone          string
+	Deployment    string
+	Job           string
+	PlacementTags []string
+	// Resources - the cell's disk and containers, nil when the source does not report them
+	Resources *metricsLib.Resources
+	// Timestamp - when the sample was taken in nanoseconds, according to the source
+	Timestamp int64
+}
+
+// Store - records samples in the metrics, setting the cell memory from the first sample that reports it
+type Store struct {
+	Metrics    *metricsLib.Metrics
+	CellMemory *float64
+}
+
+// Record - records the sample's remaining memory against its cell
+func (s Store) Record(sample Sample) {
+	if *s.CellMemory == 0 && sample.TotalMemory > 0 {
+		*s.CellMemory = sample.TotalMemory
+		fmt.Printf("Setting the max memory to %v\n", *s.CellMemory)
+	}
+	s.Metrics.Record(sample.Cell, metricsLib.MessageMetric{
+		Memory:        sample.RemainingMemory,
+		Timestamp:     sample.Timestamp,
+		Zone:          sample.Zone,
+		Deployment:    sample.Deployment,
+		Job:           sample.Job,
+		PlacementTags: sample.PlacementTags,
+		Resources:     sample.Resources,
+	})
+}
+
+// Poller - a source that is polled for the samples of every cell
+type Poller interface {
+	Poll() ([]Sample, error)
+}
+
+// Poll - polls the source now and then at every interval, recording whatever samples it returns even when some
+// cells could not be polled
+func Poll(poller Poller, store Store, interval time.Duration) {
+	for {
+		samples, err := poller.Poll()
+		if err != nil {
+			fmt.Printf("Error occurred polling cell capacity: %v\n", err)
+		}
+		for _, sample := range samples {
+			store.Record(sample)
+		}
+		time.Sleep(interval)
+	}
+}
+
+// ZoneFromTags - returns the availability zone a cell has tagged its envelopes with, if any
+func ZoneFromTags(tags map[string]string) string {
+	for _, key := range []string{"az", "zone", "availability_zone"} {
+		if zone, ok := tags[key]; ok {
+			return zone
+		}
+	}
+	return ""
+}
+
+// PlacementTagsFromTags - returns the comma separated placement tags a cell has tagged its envelopes with, if any
+func PlacementTagsFromTags(tags map[string]string) []string {
+	var placementTags []string
+	for _, tag := range strings.Split(tags["placement_tags"], ",") {
+		if tag = strings.TrimSpace(tag); tag != "" {
+			placementTags = append(placementTags, tag)
+		}
+	}
+	return placementTags
+}
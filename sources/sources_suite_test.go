@@ -0,0 +1,13 @@
+package sources_test
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"testing"
+)
+
+func TestSources(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "Sources test suite")
+}
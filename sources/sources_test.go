@@ -0,0 +1,44 @@
+package sources_test
+
+import (
+	metricsLib "github.com/FidelityInternational/diego-capacity-monitor/metrics"
+	"github.com/FidelityInternational/diego-capacity-monitor/sources"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Store", func() {
+	var (
+		metrics    metricsLib.Metrics
+		cellMemory float64
+		store      sources.Store
+	)
+
+	BeforeEach(func() {
+		metrics = metricsLib.Metrics{MessageMetrics: make(map[string]metricsLib.MessageMetric), Smoothing: metricsLib.SmoothingNone}
+		cellMemory = 0
+		store = sources.Store{Metrics: &metrics, CellMemory: &cellMemory}
+	})
+
+	Describe("#Record", func() {
+		It("records the remaining memory against the cell", func() {
+			store.Record(sources.Sample{Cell: "cell-1", RemainingMemory: 4096, Zone: "z1", PlacementTags: []string{"iso"}, Timestamp: 123})
+			metric, ok := metrics.Get("cell-1")
+			Ω(ok).Should(BeTrue())
+			Ω(metric.Memory).Should(Equal(4096.0))
+			Ω(metric.Zone).Should(Equal("z1"))
+			Ω(metric.PlacementTags).Should(Equal([]string{"iso"}))
+			Ω(metric.Timestamp).Should(Equal(int64(123)))
+			Ω(metric.ReceivedAt).ShouldNot(BeZero())
+		})
+
+		It("sets the cell memory from the first sample that reports it", func() {
+			store.Record(sources.Sample{Cell: "cell-1", RemainingMemory: 4096})
+			Ω(cellMemory).Should(BeZero())
+			store.Record(sources.Sample{Cell: "cell-1", RemainingMemory: 4096, TotalMemory: 16384})
+			Ω(cellMemory).Should(Equal(16384.0))
+			store.Record(sources.Sample{Cell: "cell-2", RemainingMemory: 4096, TotalMemory: 32768})
+			Ω(cellMemory).Should(Equal(16384.0))
+		})
+	})
+})
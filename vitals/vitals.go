@@ -0,0 +1,159 @@
+package vitals
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// The BOSH system metrics that are tracked for each cell
+const (
+	MemoryPercent        = "system.mem.percent"
+	MemoryKB             = "system.mem.kb"
+	SwapPercent          = "system.swap.percent"
+	CPUUser              = "system.cpu.user"
+	EphemeralDiskPercent = "system.disk.ephemeral.percent"
+)
+
+var tracked = map[string]bool{
+	MemoryPercent:        true,
+	MemoryKB:             true,
+	SwapPercent:          true,
+	CPUUser:              true,
+	EphemeralDiskPercent: true,
+}
+
+// pressureMetrics - the names pressure thresholds are configured with and the metrics they apply to
+var pressureMetrics = map[string]string{
+	"memory":         MemoryPercent,
+	"swap":           SwapPercent,
+	"cpu":            CPUUser,
+	"ephemeral_disk": EphemeralDiskPercent,
+}
+
+// Vitals - the latest BOSH system metrics of a cell's VM
+type Vitals struct {
+	Metrics    map[string]float64
+	ReceivedAt int64
+}
+
+// Value - returns the latest value of a system metric, ok is false if it has not been received
+func (v Vitals) Value(name string) (value float64, ok bool) {
+	value, ok = v.Metrics[name]
+	return value, ok
+}
+
+// PhysicalMemory - returns the VM's physical memory in MB, worked out from the memory used and its percentage
+func (v Vitals) PhysicalMemory() (float64, bool) {
+	usedKB, okKB := v.Value(MemoryKB)
+	percent, okPercent := v.Value(MemoryPercent)
+	if !okKB || !okPercent || percent <= 0 {
+		return 0, false
+	}
+	return usedKB / 1024 / (percent / 100), true
+}
+
+// Pressure - returns the names of the thresholds the VM is at or above, sorted by name
+func (v Vitals) Pressure(thresholds Thresholds) []string {
+	var pressure []string
+	for name, threshold := range thresholds {
+		if value, ok := v.Value(pressureMetrics[name]); ok && value >= threshold {
+			pressure = append(pressure, name)
+		}
+	}
+	sort.Strings(pressure)
+	return pressure
+}
+
+// Thresholds - the percentages at or above which a VM is under pressure, by memory, swap, cpu and ephemeral_disk
+type Thresholds map[string]float64
+
+// DefaultThresholds - returns the thresholds used unless they are overridden
+func DefaultThresholds() Thresholds {
+	return Thresholds{"memory": 90, "swap": 10, "cpu": 90, "ephemeral_disk": 90}
+}
+
+// ParseThresholds - parses a comma separated list of name=percent pairs over the default thresholds, e.g. "memory=85,swap=5"
+func ParseThresholds(thresholds string) (Thresholds, error) {
+	parsed := DefaultThresholds()
+	for _, pair := range strings.Split(thresholds, ",") {
+		if strings.TrimSpace(pair) == "" {
+			continue
+		}
+		parts := strings.SplitN(pair, "=", 2)
+		name := strings.TrimSpace(parts[0])
+		if _, ok := pressureMetrics[name]; !ok || len(parts) != 2 {
+			return nil, fmt.Errorf("pressure threshold %q must be in the form name=percent, where name is one of memory, swap, cpu or ephemeral_disk", pair)
+		}
+		percent, err := strconv.ParseFloat(strings.TrimSpace(parts[1]), 64)
+		if err != nil {
+			return nil, fmt.Errorf("pressure threshold %q must be in the form name=percent: %v", pair, err)
+		}
+		parsed[name] = percent
+	}
+	return parsed, nil
+}
+
+// Store - the latest BOSH system metrics of each cell
+type Store struct {
+	// StaleDuration - how long a cell's system metrics are remembered after they were last received
+	StaleDuration time.Duration
+	// Now - returns the current time, time.Now when it is nil
+	Now   func() time.Time
+	cells map[string]Vitals
+	mutex sync.Mutex
+}
+
+// CreateStore - creates an empty Store
+func CreateStore() *Store {
+	return &Store{StaleDuration: 15 * time.Minute, cells: make(map[string]Vitals)}
+}
+
+// Record - records the latest value of a system metric for a cell, returning false if the metric is not tracked
+func (s *Store) Record(cell string, name string, value float64) bool {
+	if !tracked[name] {
+		return false
+	}
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	vitals, ok := s.cells[cell]
+	if !ok {
+		vitals = Vitals{Metrics: make(map[string]float64)}
+	}
+	vitals.Metrics[name] = value
+	vitals.ReceivedAt = s.now().UnixNano()
+	s.cells[cell] = vitals
+	return true
+}
+
+// now - returns the current time from Now
+func (s *Store) now() time.Time {
+	if s.Now == nil {
+		return time.Now()
+	}
+	return s.Now()
+}
+
+// All - returns the system metrics of each cell that are not stale, forgetting those that are
+func (s *Store) All() map[string]Vitals {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	staleBefore := s.now().Add(-s.StaleDuration).UnixNano()
+	cells := make(map[string]Vitals)
+	for cell, vitals := range s.cells {
+		if vitals.ReceivedAt < staleBefore {
+			delete(s.cells, cell)
+			continue
+		}
+		// copy the metrics so that they are not changed by later records
+		copied := Vitals{Metrics: make(map[string]float64), ReceivedAt: vitals.ReceivedAt}
+		for name, value := range vitals.Metrics {
+			copied.Metrics[name] = value
+		}
+		cells[cell] = copied
+	}
+	return cells
+}
@@ -0,0 +1,96 @@
+package vitals_test
+
+import (
+	"github.com/FidelityInternational/diego-capacity-monitor/vitals"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"time"
+)
+
+var _ = Describe("Store", func() {
+	var store *vitals.Store
+
+	BeforeEach(func() {
+		store = vitals.CreateStore()
+	})
+
+	Describe("#Record", func() {
+		It("records the tracked system metrics of each cell", func() {
+			Ω(store.Record("1", vitals.MemoryPercent, 50)).Should(BeTrue())
+			Ω(store.Record("1", vitals.SwapPercent, 2)).Should(BeTrue())
+			cell := store.All()["1"]
+			Ω(cell.Metrics).Should(Equal(map[string]float64{vitals.MemoryPercent: 50, vitals.SwapPercent: 2}))
+		})
+
+		It("ignores metrics that are not tracked", func() {
+			Ω(store.Record("1", "system.load.1m", 3)).Should(BeFalse())
+			Ω(store.All()).Should(BeEmpty())
+		})
+	})
+
+	Describe("#All", func() {
+		It("forgets cells whose metrics are stale", func() {
+			store.StaleDuration = -time.Second
+			store.Record("1", vitals.MemoryPercent, 50)
+			Ω(store.All()).Should(BeEmpty())
+		})
+	})
+})
+
+var _ = Describe("Vitals", func() {
+	var cell vitals.Vitals
+
+	BeforeEach(func() {
+		cell = vitals.Vitals{Metrics: map[string]float64{
+			vitals.MemoryPercent: 95,
+			vitals.MemoryKB:      95 * 1024 * 1024 / 100 * 16,
+			vitals.SwapPercent:   5,
+			vitals.CPUUser:       40,
+		}}
+	})
+
+	Describe("#PhysicalMemory", func() {
+		It("works out the physical memory in MB from the memory used", func() {
+			physicalMemory, ok := cell.PhysicalMemory()
+			Ω(ok).Should(BeTrue())
+			Ω(physicalMemory).Should(BeNumerically("~", 16384, 0.01))
+		})
+
+		It("is not known without the memory percentage", func() {
+			delete(cell.Metrics, vitals.MemoryPercent)
+			_, ok := cell.PhysicalMemory()
+			Ω(ok).Should(BeFalse())
+		})
+	})
+
+	Describe("#Pressure", func() {
+		It("returns the thresholds the VM is at or above", func() {
+			Ω(cell.Pressure(vitals.DefaultThresholds())).Should(Equal([]string{"memory"}))
+			Ω(cell.Pressure(vitals.Thresholds{"swap": 5, "cpu": 50})).Should(Equal([]string{"swap"}))
+		})
+	})
+})
+
+var _ = Describe("#ParseThresholds", func() {
+	It("overrides the default thresholds", func() {
+		thresholds, err := vitals.ParseThresholds("memory=85, swap=5")
+		Ω(err).Should(BeNil())
+		Ω(thresholds).Should(Equal(vitals.Thresholds{"memory": 85, "swap": 5, "cpu": 90, "ephemeral_disk": 90}))
+	})
+
+	It("returns the defaults when none are supplied", func() {
+		thresholds, err := vitals.ParseThresholds("")
+		Ω(err).Should(BeNil())
+		Ω(thresholds).Should(Equal(vitals.DefaultThresholds()))
+	})
+
+	It("returns an error for an unknown threshold", func() {
+		_, err := vitals.ParseThresholds("load=3")
+		Ω(err).Should(MatchError(`pressure threshold "load=3" must be in the form name=percent, where name is one of memory, swap, cpu or ephemeral_disk`))
+	})
+
+	It("returns an error for an invalid percentage", func() {
+		_, err := vitals.ParseThresholds("memory=high")
+		Ω(err).ShouldNot(BeNil())
+	})
+})
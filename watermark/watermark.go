@@ -0,0 +1,232 @@
+package watermark
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// ErrCellMemoryUnknown - returned when an amount of memory is converted to cells before the cell memory is known
+var ErrCellMemoryUnknown = errors.New("the cell memory is not known yet")
+
+// Rounding - how a fractional number of cells is turned into a whole number of cells
+type Rounding string
+
+// Supported rounding modes
+const (
+	Ceil  Rounding = "ceil"
+	Floor Rounding = "floor"
+	Round Rounding = "round"
+)
+
+var memoryUnits = map[string]float64{
+	"MB": 1,
+	"GB": 1024,
+	"TB": 1024 * 1024,
+}
+
+// ParseRounding - parses a rounding mode, defaulting to ceil when none is supplied
+func ParseRounding(rounding string) (Rounding, error) {
+	switch Rounding(strings.ToLower(strings.TrimSpace(rounding))) {
+	case "", Ceil:
+		return Ceil, nil
+	case Floor:
+		return Floor, nil
+	case Round:
+		return Round, nil
+	}
+	return "", fmt.Errorf("rounding %q must be one of ceil, floor or round", rounding)
+}
+
+func (r Rounding) apply(value float64) int {
+	switch r {
+	case Floor:
+		return int(math.Floor(value))
+	case Round:
+		return int(math.Floor(value + 0.5))
+	}
+	return int(math.Ceil(value))
+}
+
+// Watermark - a parsed watermark expression
+type Watermark struct {
+	Expression string
+	Rounding   Rounding
+	root       node
+}
+
+// Parse - parses a watermark expression, which is one of
+//   - a number of cells, e.g. "2"
+//   - a percentage of the cells, e.g. "10%"
+//   - an amount of memory, converted to cells using the cell memory, e.g. "64GB"
+//   - the max or min of other expressions, e.g. "max(2, 10%)"
+func Parse(expression string, rounding Rounding) (Watermark, error) {
+	p := &parser{input: expression}
+	root, err := p.parseExpression()
+	if err == nil {
+		p.skipSpaces()
+		if p.pos < len(p.input) {
+			err = fmt.Errorf("unexpected %q", p.input[p.pos:])
+		}
+	}
+	if err != nil {
+		return Watermark{}, fmt.Errorf("invalid watermark %q: %v", expression, err)
+	}
+	return Watermark{Expression: expression, Rounding: rounding, root: root}, nil
+}
+
+// CellCount - returns the number of cells the watermark represents out of cellCount cells of cellMemory MB each
+func (w Watermark) CellCount(cellCount int, cellMemory float64) (int, error) {
+	if w.root == nil {
+		return 0, fmt.Errorf("watermark %q has not been parsed", w.Expression)
+	}
+	return w.root.cells(cellCount, cellMemory, w.Rounding)
+}
+
+type node interface {
+	cells(cellCount int, cellMemory float64, rounding Rounding) (int, error)
+}
+
+type cellsNode int
+
+func (n cellsNode) cells(int, float64, Rounding) (int, error) {
+	return int(n), nil
+}
+
+type percentNode float64
+
+func (n percentNode) cells(cellCount int, _ float64, rounding Rounding) (int, error) {
+	return rounding.apply(float64(cellCount) * float64(n) / 100), nil
+}
+
+type memoryNode float64
+
+func (n memoryNode) cells(_ int, cellMemory float64, rounding Rounding) (int, error) {
+	if cellMemory <= 0 {
+		return 0, ErrCellMemoryUnknown
+	}
+	return rounding.apply(float64(n) / cellMemory), nil
+}
+
+type functionNode struct {
+	name      string
+	arguments []node
+}
+
+func (n functionNode) cells(cellCount int, cellMemory float64, rounding Rounding) (int, error) {
+	var result int
+	for i, argument := range n.arguments {
+		value, err := argument.cells(cellCount, cellMemory, rounding)
+		if err != nil {
+			return 0, err
+		}
+		if i == 0 || (n.name == "max" && value > result) || (n.name == "min" && value < result) {
+			result = value
+		}
+	}
+	return result, nil
+}
+
+type parser struct {
+	input string
+	pos   int
+}
+
+func (p *parser) skipSpaces() {
+	for p.pos < len(p.input) && p.input[p.pos] == ' ' {
+		p.pos++
+	}
+}
+
+func (p *parser) parseExpression() (node, error) {
+	p.skipSpaces()
+	start := p.pos
+	for p.pos < len(p.input) && unicode.IsLetter(rune(p.input[p.pos])) {
+		p.pos++
+	}
+	name := strings.ToLower(p.input[start:p.pos])
+	p.skipSpaces()
+	if p.pos < len(p.input) && p.input[p.pos] == '(' {
+		return p.parseFunction(name)
+	}
+	p.pos = start
+	return p.parseTerm()
+}
+
+func (p *parser) parseFunction(name string) (node, error) {
+	if name != "max" && name != "min" {
+		return nil, fmt.Errorf("unknown function %q, expected max or min", name)
+	}
+	p.pos++
+	function := functionNode{name: name}
+	for {
+		argument, err := p.parseExpression()
+		if err != nil {
+			return nil, err
+		}
+		function.arguments = append(function.arguments, argument)
+		p.skipSpaces()
+		if p.pos >= len(p.input) {
+			return nil, fmt.Errorf("missing ) after %s arguments", name)
+		}
+		if p.input[p.pos] == ')' {
+			p.pos++
+			break
+		}
+		if p.input[p.pos] != ',' {
+			return nil, fmt.Errorf("unexpected %q in %s arguments", p.input[p.pos:], name)
+		}
+		p.pos++
+	}
+	if len(function.arguments) < 2 {
+		return nil, fmt.Errorf("%s needs at least 2 arguments", name)
+	}
+	return function, nil
+}
+
+func (p *parser) parseTerm() (node, error) {
+	start := p.pos
+	for p.pos < len(p.input) && !strings.ContainsRune(",)", rune(p.input[p.pos])) {
+		p.pos++
+	}
+	term := strings.TrimSpace(p.input[start:p.pos])
+	if term == "" {
+		return nil, fmt.Errorf("missing value")
+	}
+
+	if strings.HasSuffix(term, "%") {
+		percent, err := parseNumber(strings.TrimSuffix(term, "%"))
+		if err != nil {
+			return nil, err
+		}
+		return percentNode(percent), nil
+	}
+
+	upperTerm := strings.ToUpper(term)
+	for unit, multiplier := range memoryUnits {
+		if strings.HasSuffix(upperTerm, unit) {
+			memory, err := parseNumber(strings.TrimSpace(term[:len(term)-len(unit)]))
+			if err != nil {
+				return nil, err
+			}
+			return memoryNode(memory * multiplier), nil
+		}
+	}
+
+	cells, err := strconv.Atoi(term)
+	if err != nil || cells < 0 {
+		return nil, fmt.Errorf("%q is not a number of cells, a percentage or an amount of memory", term)
+	}
+	return cellsNode(cells), nil
+}
+
+func parseNumber(number string) (float64, error) {
+	value, err := strconv.ParseFloat(strings.TrimSpace(number), 64)
+	if err != nil || value < 0 || math.IsInf(value, 0) || math.IsNaN(value) {
+		return 0, fmt.Errorf("%q is not a positive number", number)
+	}
+	return value, nil
+}
@@ -0,0 +1,155 @@
+package watermark_test
+
+import (
+	watermarkLib "github.com/FidelityInternational/diego-capacity-monitor/watermark"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("#ParseRounding", func() {
+	It("defaults to ceil", func() {
+		rounding, err := watermarkLib.ParseRounding("")
+		Ω(err).Should(BeNil())
+		Ω(rounding).Should(Equal(watermarkLib.Ceil))
+	})
+
+	It("parses the rounding modes", func() {
+		rounding, err := watermarkLib.ParseRounding("Floor")
+		Ω(err).Should(BeNil())
+		Ω(rounding).Should(Equal(watermarkLib.Floor))
+		rounding, err = watermarkLib.ParseRounding("round")
+		Ω(err).Should(BeNil())
+		Ω(rounding).Should(Equal(watermarkLib.Round))
+	})
+
+	It("returns an error for an unknown rounding mode", func() {
+		_, err := watermarkLib.ParseRounding("truncate")
+		Ω(err).Should(MatchError(`rounding "truncate" must be one of ceil, floor or round`))
+	})
+})
+
+var _ = Describe("Watermark", func() {
+	var (
+		expression string
+		rounding   watermarkLib.Rounding
+		watermark  watermarkLib.Watermark
+		parseErr   error
+	)
+
+	BeforeEach(func() {
+		rounding = watermarkLib.Ceil
+	})
+
+	JustBeforeEach(func() {
+		watermark, parseErr = watermarkLib.Parse(expression, rounding)
+	})
+
+	cellCount := func(cells int, cellMemory float64) int {
+		count, err := watermark.CellCount(cells, cellMemory)
+		Ω(err).Should(BeNil())
+		return count
+	}
+
+	Context("when the watermark is a number of cells", func() {
+		BeforeEach(func() {
+			expression = " 3 "
+		})
+
+		It("returns the number of cells", func() {
+			Ω(parseErr).Should(BeNil())
+			Ω(cellCount(50, 10000)).Should(Equal(3))
+		})
+	})
+
+	Context("when the watermark is a percentage", func() {
+		BeforeEach(func() {
+			expression = "10%"
+		})
+
+		It("rounds up by default", func() {
+			Ω(parseErr).Should(BeNil())
+			Ω(cellCount(50, 10000)).Should(Equal(5))
+			Ω(cellCount(51, 10000)).Should(Equal(6))
+			Ω(cellCount(4, 10000)).Should(Equal(1))
+		})
+
+		Context("and the rounding is floor", func() {
+			BeforeEach(func() {
+				rounding = watermarkLib.Floor
+			})
+
+			It("rounds down", func() {
+				Ω(cellCount(59, 10000)).Should(Equal(5))
+				Ω(cellCount(4, 10000)).Should(Equal(0))
+			})
+		})
+
+		Context("and the rounding is round", func() {
+			BeforeEach(func() {
+				rounding = watermarkLib.Round
+			})
+
+			It("rounds to the nearest cell", func() {
+				Ω(cellCount(54, 10000)).Should(Equal(5))
+				Ω(cellCount(55, 10000)).Should(Equal(6))
+			})
+		})
+	})
+
+	Context("when the watermark is an amount of memory", func() {
+		BeforeEach(func() {
+			expression = "64GB"
+		})
+
+		It("returns the number of cells holding that memory", func() {
+			Ω(parseErr).Should(BeNil())
+			Ω(cellCount(50, 16384)).Should(Equal(4))
+			Ω(cellCount(50, 10000)).Should(Equal(7))
+		})
+
+		It("returns an error when the cell memory is not known", func() {
+			_, err := watermark.CellCount(50, 0)
+			Ω(err).Should(MatchError("the cell memory is not known yet"))
+		})
+	})
+
+	Context("when the watermark is a compound expression", func() {
+		BeforeEach(func() {
+			expression = "max(2, min(10%, 8192mb))"
+		})
+
+		It("evaluates the functions", func() {
+			Ω(parseErr).Should(BeNil())
+			Ω(cellCount(50, 4096)).Should(Equal(2))
+			Ω(cellCount(10, 4096)).Should(Equal(2))
+			Ω(cellCount(50, 1024)).Should(Equal(5))
+		})
+	})
+
+	Context("when the watermark is invalid", func() {
+		It("returns an error for an unknown function", func() {
+			_, err := watermarkLib.Parse("avg(1, 2)", rounding)
+			Ω(err).Should(MatchError(`invalid watermark "avg(1, 2)": unknown function "avg", expected max or min`))
+		})
+
+		It("returns an error for an unclosed function", func() {
+			_, err := watermarkLib.Parse("max(1, 2", rounding)
+			Ω(err).Should(MatchError(`invalid watermark "max(1, 2": missing ) after max arguments`))
+		})
+
+		It("returns an error for trailing input", func() {
+			_, err := watermarkLib.Parse("max(1, 2))", rounding)
+			Ω(err).Should(MatchError(`invalid watermark "max(1, 2))": unexpected ")"`))
+		})
+
+		It("returns an error for a negative value", func() {
+			_, err := watermarkLib.Parse("-1", rounding)
+			Ω(err).Should(MatchError(`invalid watermark "-1": "-1" is not a number of cells, a percentage or an amount of memory`))
+		})
+
+		It("returns an error for an empty watermark", func() {
+			_, err := watermarkLib.Parse("", rounding)
+			Ω(err).Should(MatchError(`invalid watermark "": missing value`))
+		})
+	})
+})
@@ -0,0 +1,71 @@
+package webServer
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/FidelityInternational/diego-capacity-monitor/containers"
+	"net/http"
+)
+
+type containerReport struct {
+	containers.Totals
+	// SafeOvercommitMemory - the reserved memory that could be overcommitted while keeping the headroom above the actual use
+	SafeOvercommitMemory      float64 `json:"safeOvercommitMemory"`
+	OvercommitHeadroomPercent float64 `json:"overcommitHeadroomPercent"`
+}
+
+// truncateTotals - truncates the memory of the totals to 2 decimal places
+func truncateTotals(totals containers.Totals) containers.Totals {
+	totals.ActualMemory = truncate2dp(totals.ActualMemory)
+	totals.ReservedMemory = truncate2dp(totals.ReservedMemory)
+	totals.UnusedMemory = truncate2dp(totals.UnusedMemory)
+	return totals
+}
+
+// containerReport - reports the actual and reserved memory of the app instances, nil when they are not tracked
+// or no ContainerMetric envelopes have been received
+func (c *Controller) containerReport() *containerReport {
+	if c.Containers == nil {
+		return nil
+	}
+	total := c.Containers.Total()
+	if total.InstanceCount == 0 {
+		return nil
+	}
+	return &containerReport{
+		Totals:                    truncateTotals(total),
+		SafeOvercommitMemory:      truncate2dp(total.SafeOvercommit(c.OvercommitHeadroomPercent)),
+		OvercommitHeadroomPercent: c.OvercommitHeadroomPercent,
+	}
+}
+
+// containerUsage - returns the actual and reserved memory of the instances on each cell, nil when they are not tracked
+func (c *Controller) containerUsage() map[string]containers.Totals {
+	if c.Containers == nil {
+		return nil
+	}
+	return c.Containers.Cells()
+}
+
+// setContainerUsage - sets the actual and reserved memory of the instances on the cell
+func (cell *cellReport) setContainerUsage(totals containers.Totals) {
+	actualMemory, reservedMemory := truncate2dp(totals.ActualMemory), truncate2dp(totals.ReservedMemory)
+	cell.ActualMemory = &actualMemory
+	cell.ReservedMemory = &reservedMemory
+}
+
+// Apps - returns a json list of the actual and reserved memory of each app, the apps wasting the most reserved
+// memory first
+func (c *Controller) Apps(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	apps := []containers.AppTotals{}
+	if c.Containers != nil {
+		apps = c.Containers.Apps()
+	}
+	for i := range apps {
+		apps[i].Totals = truncateTotals(apps[i].Totals)
+	}
+	w.WriteHeader(http.StatusOK)
+	bytes, _ := json.Marshal(apps)
+	fmt.Fprintf(w, "%v", string(bytes))
+}
@@ -3,11 +3,17 @@ package webServer
 import (
 	"encoding/json"
 	"fmt"
+	"github.com/FidelityInternational/diego-capacity-monitor/bbs"
+	"github.com/FidelityInternational/diego-capacity-monitor/containers"
+	"github.com/FidelityInternational/diego-capacity-monitor/demand"
 	"github.com/FidelityInternational/diego-capacity-monitor/metrics"
+	"github.com/FidelityInternational/diego-capacity-monitor/placement"
+	"github.com/FidelityInternational/diego-capacity-monitor/schedule"
+	"github.com/FidelityInternational/diego-capacity-monitor/vitals"
+	"github.com/FidelityInternational/diego-capacity-monitor/watermark"
 	"net/http"
 	"sort"
-	"strconv"
-	"strings"
+	"sync"
 	"time"
 )
 
@@ -23,24 +29,126 @@ type Controller struct {
 	CellMemory *float64
 	Watermark  *string
 	StartTime  time.Time
+	PoolBy     string
+	// PoolWatermarks - watermarks for pools whose name matches a pattern, checked in order before the default Watermark
+	PoolWatermarks []PoolWatermark
+	// WatermarkRounding - how percentage and memory watermarks are rounded to a whole number of cells
+	WatermarkRounding watermark.Rounding
+	// Schedule - profiles that switch the watermark and headroom thresholds at scheduled times
+	Schedule *schedule.Schedule
+	// HeadroomWarningPercent - the WatermarkMemoryPercent below which a pool is reported as a warning
+	HeadroomWarningPercent float64
+	// HeadroomCriticalPercent - the WatermarkMemoryPercent at or below which a pool is reported as critical
+	HeadroomCriticalPercent float64
+	// InstanceSizes - the instance sizes in MB that fragmentation of the free memory is reported for
+	InstanceSizes []float64
+	// ImbalanceLimit - the spread in MB of free memory across cells above which they are reported as imbalanced
+	ImbalanceLimit float64
+	// MissingCellThreshold - the number of missing cells above which a warning is reported
+	MissingCellThreshold int
+	// ExpectedCellCount - the number of cells that must report before the monitor is ready, 0 to decide from the data
+	ExpectedCellCount int
+	// QuietIntervals - the number of emission intervals without a new cell after which the monitor is ready
+	QuietIntervals int
+	// ReadinessTimeout - the longest the monitor will report that it is initialising for
+	ReadinessTimeout time.Duration
+	// Containers - the actual and reserved memory of the app instances, nil when ContainerMetric envelopes are not used
+	Containers *containers.Usage
+	// OvercommitHeadroomPercent - the headroom above the actual memory use kept when working out the safe overcommit
+	OvercommitHeadroomPercent float64
+	// Vitals - the BOSH system metrics of the cells' VMs, nil when they are not used
+	Vitals *vitals.Store
+	// PressureThresholds - the system metric percentages at or above which a cell's VM is under pressure
+	PressureThresholds vitals.Thresholds
+	// Placement - the auctions the auctioneer recently failed to place, nil when they are not tracked
+	Placement *placement.Tracker
+	// PlacementFailureThreshold - the number of recent placement failures that make the status critical, 0 to only report them
+	PlacementFailureThreshold int
+	// Demand - the demand for instances from the BBS and the containers on each cell, nil when it is not tracked
+	Demand *demand.Tracker
+	// Registry - the cells registered with the BBS, which are reconciled with the firehose, nil when it is not used
+	Registry *bbs.Registry
+	// Instances - the LRP instances placed on each cell fetched from the BBS, nil when they are not fetched
+	Instances *bbs.Instances
+	// HysteresisMarginPercent - how far the WatermarkMemoryPercent must recover past a threshold to leave its state
+	HysteresisMarginPercent float64
+	// MinimumStateDuration - the shortest time a pool stays in a state before it can change
+	MinimumStateDuration time.Duration
+	// Now - returns the time the health is evaluated at, time.Now when it is nil so that recordings can be replayed,
+	// the Metrics and trackers are timed by their own Now
+	Now             func() time.Time
+	ready           bool
+	readyMutex      sync.Mutex
+	watermarks      map[string]watermark.Watermark
+	watermarksMutex sync.Mutex
+	states          map[string]*healthState
+	statesMutex     sync.Mutex
+	sourceErr       error
+	sourceMutex     sync.Mutex
 }
 
+// Statuses of cells that are not counted as capacity
+const (
+	cellStale   = "stale"
+	cellMissing = "missing"
+)
+
 type cellReport struct {
-	Index     string  `json:"index"`
-	Memory    float64 `json:"memory"`
-	LowMemory bool    `json:"low_memory"`
+	Index  string  `json:"index"`
+	Memory float64 `json:"memory"`
+	// RawMemory - the latest free memory the cell reported, when Memory has been smoothed
+	RawMemory *float64 `json:"raw_memory,omitempty"`
+	// ActualMemory - the memory used by the app instances on the cell
+	ActualMemory *float64 `json:"actual_memory,omitempty"`
+	// ReservedMemory - the memory limits of the app instances on the cell
+	ReservedMemory *float64 `json:"reserved_memory,omitempty"`
+	LowMemory      bool     `json:"low_memory"`
+	Zone           string   `json:"zone,omitempty"`
+	Pool           string   `json:"pool,omitempty"`
+	Status         string   `json:"status,omitempty"`
+	LastSeen       string   `json:"last_seen,omitempty"`
+	// EmissionInterval - the learned number of seconds between the cell's metrics
+	EmissionInterval float64 `json:"emission_interval_seconds,omitempty"`
+	// ClockSkew - the number of seconds the cell's clock was behind the monitor's when its last metric was received
+	ClockSkew float64 `json:"clock_skew_seconds,omitempty"`
+	// Capacity - the cell's capacity registered with the BBS
+	Capacity *bbs.CellCapacity `json:"capacity,omitempty"`
+	// Resources - the cell's disk and containers, when its source reports them
+	Resources *metrics.Resources `json:"resources,omitempty"`
+	// ContainerCount - the number of containers the rep is running on the cell, when it is known
+	ContainerCount *float64 `json:"container_count,omitempty"`
+	// Instances - the LRP instances placed on the cell, when they are fetched from the BBS
+	Instances *instancesSummary `json:"instances,omitempty"`
+	// VM - the cell VM's system metrics, when they are known
+	VM *vmReport `json:"vm,omitempty"`
 }
 
 type report struct {
-	Healthy                bool         `json:"healthy"`
-	Message                string       `json:"message"`
-	CellReports            []cellReport `json:"details,omitempty"`
-	CellCount              int          `json:"cellCount"`
-	CellMemory             float64      `json:"cellMemory"`
-	Watermark              int          `json:"watermark"`
-	RequestedWatermark     string       `json:"requested_watermark"`
-	TotalFreeMemory        float64      `json:"totalFreeMemory"`
-	WatermarkMemoryPercent float64      `json:"WatermarkMemoryPercent"`
+	Healthy                bool                 `json:"healthy"`
+	Message                string               `json:"message"`
+	Status                 string               `json:"status"`
+	Reasons                []string             `json:"reasons"`
+	CellReports            []cellReport         `json:"details,omitempty"`
+	CellCount              int                  `json:"cellCount"`
+	CellMemory             float64              `json:"cellMemory"`
+	Watermark              int                  `json:"watermark"`
+	RequestedWatermark     string               `json:"requested_watermark"`
+	TotalFreeMemory        float64              `json:"totalFreeMemory"`
+	WatermarkMemoryPercent float64              `json:"WatermarkMemoryPercent"`
+	Zones                  []zoneReport         `json:"zones,omitempty"`
+	ZoneLoss               *zoneLossSimulation  `json:"zone_loss,omitempty"`
+	Pools                  []poolReport         `json:"pools,omitempty"`
+	Profile                *profileReport       `json:"profile,omitempty"`
+	Warnings               []string             `json:"warnings,omitempty"`
+	Fragmentation          *fragmentationReport `json:"fragmentation,omitempty"`
+	Distribution           *distributionReport  `json:"distribution,omitempty"`
+	MissingCellCount       int                  `json:"missingCellCount,omitempty"`
+	Readiness              *readinessReport     `json:"readiness,omitempty"`
+	State                  *stateReport         `json:"state,omitempty"`
+	Containers             *containerReport     `json:"containers,omitempty"`
+	Placement              *placementReport     `json:"placement,omitempty"`
+	Demand                 *demandReport        `json:"demand,omitempty"`
+	Registry               *registryReport      `json:"registry,omitempty"`
 }
 
 // CreateController - returns a populated controller object
@@ -50,6 +158,14 @@ func CreateController(metrics metrics.Metrics, cellMemory *float64, watermark *s
 		CellMemory: cellMemory,
 		Watermark:  watermark,
 		StartTime:  startTime,
+
+		HeadroomWarningPercent:    20,
+		HeadroomCriticalPercent:   0,
+		OvercommitHeadroomPercent: 20,
+		PressureThresholds:        vitals.DefaultThresholds(),
+		PlacementFailureThreshold: 1,
+		QuietIntervals:            3,
+		ReadinessTimeout:          5 * time.Minute,
 	}
 }
 
@@ -66,32 +182,149 @@ func WatermarkMemoryPercent2dp(watermark int, cellCount int, cellMemory float64,
 	return 0
 }
 
-// CalculateWatermarkCellCount - Calculates the watermark cell count from an count or percent.
+// CalculateWatermarkCellCount - Calculates the watermark cell count from a watermark expression.
 func (c *Controller) CalculateWatermarkCellCount(cellCount int) (int, error) {
-	var watermarkCellCount int
-	if strings.Contains(*c.Watermark, "%") {
-
-		watermarkPercent := strings.Split(*c.Watermark, "%")[0]
+	return c.calculateWatermarkCellCount(*c.Watermark, cellCount)
+}
 
-		watermark, err := strconv.Atoi(watermarkPercent)
-		if err != nil {
-			return 0, err
+// ValidateWatermarks - parses the default, pool and profile watermarks so that invalid expressions are found at startup
+func (c *Controller) ValidateWatermarks() error {
+	if _, err := c.parseWatermark(*c.Watermark); err != nil {
+		return err
+	}
+	for _, poolWatermark := range c.PoolWatermarks {
+		if _, err := c.parseWatermark(poolWatermark.Watermark); err != nil {
+			return err
 		}
-		watermarkCellCount = int((float64(cellCount) * (float64(watermark) / 100)) + 1)
-	} else {
-		watermark, err := strconv.Atoi(*c.Watermark)
-		if err != nil {
-			return 0, err
+	}
+	if c.Schedule != nil {
+		for _, profile := range c.Schedule.Profiles {
+			if profile.Watermark != "" {
+				if _, err := c.parseWatermark(profile.Watermark); err != nil {
+					return err
+				}
+			}
+			poolWatermarks, err := ParsePoolWatermarks(profile.PoolWatermarks)
+			if err != nil {
+				return fmt.Errorf("profile %s: %v", profile.Name, err)
+			}
+			for _, poolWatermark := range poolWatermarks {
+				if _, err := c.parseWatermark(poolWatermark.Watermark); err != nil {
+					return err
+				}
+			}
+		}
+	}
+	return nil
+}
+
+func (c *Controller) calculateWatermarkCellCount(requestedWatermark string, cellCount int) (int, error) {
+	parsedWatermark, err := c.parseWatermark(requestedWatermark)
+	if err != nil {
+		return 0, err
+	}
+	return parsedWatermark.CellCount(cellCount, c.cellMemory())
+}
+
+// cellMemory - returns the memory of a cell, taken from the capacity registered with the BBS until the source
+// reports it
+func (c *Controller) cellMemory() float64 {
+	if c.CellMemory != nil && *c.CellMemory > 0 {
+		return *c.CellMemory
+	}
+	if c.Registry != nil {
+		if memory, ok := c.Registry.CellMemory(); ok {
+			return memory
 		}
-		watermarkCellCount = watermark
 	}
+	return 0
+}
 
-	return watermarkCellCount, nil
+// parseWatermark - returns the parsed watermark expression, expressions are only parsed once
+func (c *Controller) parseWatermark(requestedWatermark string) (watermark.Watermark, error) {
+	c.watermarksMutex.Lock()
+	defer c.watermarksMutex.Unlock()
+	if parsedWatermark, ok := c.watermarks[requestedWatermark]; ok {
+		return parsedWatermark, nil
+	}
+	parsedWatermark, err := watermark.Parse(requestedWatermark, c.WatermarkRounding)
+	if err != nil {
+		return parsedWatermark, err
+	}
+	if c.watermarks == nil {
+		c.watermarks = make(map[string]watermark.Watermark)
+	}
+	c.watermarks[requestedWatermark] = parsedWatermark
+	return parsedWatermark, nil
+}
+
+// missingCellsWarning - returns a warning when more cells than the threshold have stopped reporting
+func (c *Controller) missingCellsWarning(cells []cellReport) string {
+	var missing []string
+	for _, cell := range cells {
+		if cell.Status == cellMissing {
+			missing = append(missing, cell.Index)
+		}
+	}
+	if len(missing) == 0 || len(missing) <= c.MissingCellThreshold {
+		return ""
+	}
+	return fmt.Sprintf("%d of %d known cells have stopped reporting: %v", len(missing), len(cells), missing)
 }
 
 // Index - The only current endpoint, returns a json object of health and diego memory stats
 func (c *Controller) Index(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "application/json")
+	report, statusCode := c.evaluateReport()
+	report.write(w, statusCode)
+}
+
+// Health - the overall health reported by the Index endpoint
+type Health struct {
+	Healthy    bool
+	Status     string
+	Reasons    []string
+	Message    string
+	StatusCode int
+}
+
+// Health - evaluates the overall health the Index endpoint would report
+func (c *Controller) Health() Health {
+	report, statusCode := c.evaluateReport()
+	return Health{
+		Healthy:    statusCode == http.StatusOK,
+		Status:     report.Status,
+		Reasons:    report.Reasons,
+		Message:    report.Message,
+		StatusCode: statusCode,
+	}
+}
+
+// SetSourceError - records why the cells' capacity can no longer be ingested from the source, which is reported as
+// unknown until it is cleared with nil once the source is ingested again
+func (c *Controller) SetSourceError(err error) {
+	c.sourceMutex.Lock()
+	defer c.sourceMutex.Unlock()
+	c.sourceErr = err
+}
+
+// sourceError - returns why the source can no longer be ingested, nil when it is being ingested
+func (c *Controller) sourceError() error {
+	c.sourceMutex.Lock()
+	defer c.sourceMutex.Unlock()
+	return c.sourceErr
+}
+
+// now - returns the time the health is evaluated at
+func (c *Controller) now() time.Time {
+	if c.Now == nil {
+		return time.Now()
+	}
+	return c.Now()
+}
+
+// evaluateReport - evaluates the health and diego memory stats, returning the report and its status code
+func (c *Controller) evaluateReport() (*report, int) {
 	var keys []string
 	messageMetrics := c.Metrics.GetAll()
 	for k := range messageMetrics {
@@ -100,73 +333,172 @@ func (c *Controller) Index(w http.ResponseWriter, r *http.Request) {
 	sort.Strings(keys)
 
 	var (
-		memLowCount     int
-		cellCount       int
-		totalFreeMemory float64
-		report          report
-		cellReports     []cellReport
-		statusCode      int
+		report      report
+		cellReports []cellReport
+		allReports  []cellReport
 	)
+	containerUsage := c.containerUsage()
+	now := c.now()
+	vitalsUsage := c.vitalsUsage()
+	containerCounts := c.containerCounts(now)
+	registered, fetchedAt, registryFetched := c.registeredCells()
+	cellInstances := c.cellInstances()
 
 	for _, index := range keys {
-		if !c.Metrics.IsMetricStale(index) {
-			var memLow = false
-			cellCount++
-			totalFreeMemory += messageMetrics[index].Memory
-
-			if messageMetrics[index].Memory < 2048 {
-				memLowCount++
-				memLow = true
-			}
+		metric := messageMetrics[index]
+		presence, isRegistered := registered[index]
+		if isRegistered {
+			metric = reconcile(metric, presence)
+		}
+		// Health is evaluated with the smoothed memory so that brief swings while apps restage do not flap the status
+		memory := messageMetrics[index].EffectiveMemory()
+		var memLow = false
+		if memory < 2048 {
+			memLow = true
+		}
 
-			cellReport := cellReport{Index: index, Memory: messageMetrics[index].Memory, LowMemory: memLow}
+		cellReport := cellReport{Index: index, Memory: memory, LowMemory: memLow, Zone: metric.Zone, Resources: metric.Resources}
+		if isRegistered {
+			cellReport.Capacity = presence.Capacity
+		}
+		if messageMetrics[index].SmoothedMemory != nil {
+			rawMemory := messageMetrics[index].Memory
+			cellReport.RawMemory = &rawMemory
+		}
+		if totals, ok := containerUsage[index]; ok {
+			cellReport.setContainerUsage(totals)
+		}
+		if cellVitals, ok := vitalsUsage[index]; ok {
+			cellReport.setVitals(cellVitals, c.cellMemory(), c.PressureThresholds)
+		}
+		if instances, ok := cellInstances[index]; ok {
+			summary := summariseInstances(instances)
+			cellReport.Instances = &summary
+		}
+		if count, ok := containerCounts[index]; ok {
+			cellReport.ContainerCount = &count
+		}
+		if c.PoolBy != "" {
+			cellReport.Pool = PoolName(metric, c.PoolBy)
+		}
+		if interval := messageMetrics[index].Interval; interval > 0 {
+			cellReport.EmissionInterval = truncate2dp(time.Duration(interval).Seconds())
+			cellReport.ClockSkew = truncate2dp(messageMetrics[index].ClockSkew().Seconds())
+		}
+		if c.Metrics.IsMetricStale(index) {
+			// Stale and missing cells are remembered until they expire, they are not counted as capacity
+			cellReport.Status = cellStale
+			if c.Metrics.IsMetricMissing(index) {
+				cellReport.Status = cellMissing
+				report.MissingCellCount++
+			}
+			cellReport.LastSeen = messageMetrics[index].LastSeen().UTC().Format(time.RFC3339)
+		} else if registryFetched && !isRegistered {
+			// The BBS is authoritative, a cell that is not registered will not be given work
+			cellReport.Status = cellUnregistered
+		} else {
 			cellReports = append(cellReports, cellReport)
 		}
+		allReports = append(allReports, cellReport)
 	}
 
-	report.CellMemory = *c.CellMemory
-	report.CellCount = cellCount
-	report.TotalFreeMemory = totalFreeMemory
-	report.RequestedWatermark = *c.Watermark
+	report.CellMemory = c.cellMemory()
+	report.Containers = c.containerReport()
+	active := c.activeThresholds(now)
+	report.RequestedWatermark = active.Watermark
+	report.Profile = c.profileReport(now, active)
 
-	watermarkCellCount, err := c.CalculateWatermarkCellCount(cellCount)
+	overall, err := c.evaluate("", cellReports, active)
+	report.CellCount = overall.CellCount
+	report.TotalFreeMemory = overall.TotalFreeMemory
 	if err != nil {
-		report.Message = fmt.Sprintf("Error occurred while calculating cell count: %v", err.Error())
-		report.write(w, http.StatusInternalServerError)
-		return
+		return watermarkErrorReport(&report, err)
+	}
+	report.Watermark = overall.Watermark
+	report.WatermarkMemoryPercent = overall.WatermarkMemoryPercent
+	report.Fragmentation = overall.Fragmentation
+	report.Distribution = overall.Distribution
+	report.Warnings = overall.Warnings
+	report.Demand = c.demandReport(now, cellReports, overall.Watermark)
+
+	if c.PoolBy != "" && overall.CellCount > 0 {
+		pools, err := c.evaluatePools(cellReports, active)
+		if err != nil {
+			return watermarkErrorReport(&report, err)
+		}
+		for i := range pools {
+			c.applyHysteresis(pools[i].Name, &pools[i], active, now)
+		}
+		report.Pools = pools
+		report.Warnings = nil
+		for _, pool := range pools {
+			for _, warning := range pool.Warnings {
+				report.Warnings = append(report.Warnings, fmt.Sprintf("Pool %s: %s", pool.Name, warning))
+			}
+		}
+
+		worst := pools[0]
+		for _, pool := range pools[1:] {
+			if pool.worseThan(worst) {
+				worst = pool
+			}
+		}
+		overall.setStatus(worst.Status, worst.Reasons[0], worst.Message, worst.statusCode)
+		if worst.Status != statusOK {
+			overall.Message = fmt.Sprintf("Pool %s: %s", worst.Name, worst.Message)
+		}
+	} else {
+		c.applyHysteresis("", &overall, active, now)
+		report.State = overall.State
+	}
+
+	if summary, ok := c.placementSummary(now); ok {
+		report.Placement = newPlacementReport(summary)
+		c.applyPlacementFailures(&overall, summary)
+	}
+
+	if ready, readiness := c.isReady(now, cellReports, allReports); !ready {
+		overall.setStatus(statusUnknown, "initialising", "I'm still initialising, please be patient!", http.StatusExpectationFailed)
+		report.Readiness = readiness
 	}
 
-	report.Watermark = watermarkCellCount
+	if err := c.sourceError(); err != nil {
+		overall.setStatus(statusUnknown, "source_unavailable", fmt.Sprintf("The source is unavailable: %v", err), http.StatusServiceUnavailable)
+	}
+
+	if warning := c.missingCellsWarning(allReports); warning != "" {
+		report.Warnings = append(report.Warnings, warning)
+	}
+	report.Warnings = append(report.Warnings, vitalsWarnings(cellReports)...)
+	if registryFetched {
+		report.Registry = c.registryReport(registered, fetchedAt, allReports)
+		report.Warnings = append(report.Warnings, report.Registry.warnings()...)
+	}
+	report.Zones = zoneReports(cellReports, c.cellMemory())
+	report.ZoneLoss = simulateZoneLoss(report.Zones)
+	if warning := unzonedWarning(report.Zones); warning != "" {
+		report.Warnings = append(report.Warnings, warning)
+	}
 
-	if c.Metrics.RedisNotUsed() && time.Now().Before(c.StartTime.Add(1*time.Minute)) {
+	report.Message = overall.Message
+	report.Status = overall.Status
+	report.Reasons = overall.Reasons
+	report.CellReports = allReports
+	return &report, overall.statusCode
+}
+
+// watermarkErrorReport - reports a watermark that could not be converted to cells, which is only an invalid
+// watermark when the cell memory it depends on is known
+func watermarkErrorReport(report *report, err error) (*report, int) {
+	report.Status = statusUnknown
+	if err == watermark.ErrCellMemoryUnknown {
 		report.Message = "I'm still initialising, please be patient!"
-		statusCode = http.StatusExpectationFailed
-	} else if cellCount == 0 {
-		report.Message = "I'm sorry Dave I can't show you any data"
-		statusCode = http.StatusGone
-		// Panic if we dont have more cells than the watermark
-	} else if cellCount <= watermarkCellCount {
-		report.Message = "The number of cells needs to exceed the watermark amount!"
-		statusCode = http.StatusExpectationFailed
-		// Panic if half or more of the cells are low on memory
-	} else {
-		WatermarkMemoryPercent := WatermarkMemoryPercent2dp(watermarkCellCount, cellCount, *c.CellMemory, totalFreeMemory)
-		report.WatermarkMemoryPercent = WatermarkMemoryPercent
-
-		// Panic if we do not have enough headroom after watermark cells are discounted
-		if WatermarkMemoryPercent <= 0 {
-			report.Message = "FATAL - There is not enough space to do an upgrade, add cells or reduce watermark!"
-			statusCode = http.StatusExpectationFailed
-		} else if WatermarkMemoryPercent < 20 {
-			report.Message = "The percentage of free memory will be too low during a migration!"
-			statusCode = http.StatusExpectationFailed
-		} else {
-			report.Message = "Everything is awesome!"
-			statusCode = http.StatusOK
-		}
+		report.Reasons = []string{"initialising"}
+		return report, http.StatusExpectationFailed
 	}
-	report.CellReports = cellReports
-	report.write(w, statusCode)
+	report.Message = fmt.Sprintf("Error occurred while calculating cell count: %v", err.Error())
+	report.Reasons = []string{"invalid_watermark"}
+	return report, http.StatusInternalServerError
 }
 
 func (r *report) write(w http.ResponseWriter, statusCode int) {
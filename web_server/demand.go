@@ -0,0 +1,77 @@
+package webServer
+
+import (
+	"github.com/FidelityInternational/diego-capacity-monitor/demand"
+	"sort"
+	"time"
+)
+
+type demandReport struct {
+	LRPsDesired       *float64 `json:"lrpsDesired,omitempty"`
+	LRPsRunning       *float64 `json:"lrpsRunning,omitempty"`
+	CrashedActualLRPs *float64 `json:"crashedActualLRPs,omitempty"`
+	// CFAppsDomainFresh - the BBS has an up to date view of the desired cf apps, so the desired instances can be trusted
+	CFAppsDomainFresh       *bool   `json:"cfAppsDomainFresh,omitempty"`
+	ContainerCount          float64 `json:"containerCount"`
+	AverageInstancesPerCell float64 `json:"averageInstancesPerCell"`
+	// DrainInstances - the instances that would move if the watermark number of the busiest cells were drained
+	DrainInstances      float64  `json:"drainInstances"`
+	DesiredTrendPerHour *float64 `json:"desiredTrendPerHour,omitempty"`
+}
+
+// containerCounts - returns the number of containers on each cell, nil when demand is not tracked
+func (c *Controller) containerCounts(now time.Time) map[string]float64 {
+	if c.Demand == nil {
+		return nil
+	}
+	return c.Demand.ContainerCounts(now)
+}
+
+// demandReport - reports the demand for instances alongside the reporting cells, nil when demand is not tracked
+// or nothing has been received
+func (c *Controller) demandReport(now time.Time, cells []cellReport, watermarkCellCount int) *demandReport {
+	if c.Demand == nil {
+		return nil
+	}
+	report := &demandReport{}
+	received := false
+	for name, field := range map[string]**float64{
+		demand.LRPsDesired:       &report.LRPsDesired,
+		demand.LRPsRunning:       &report.LRPsRunning,
+		demand.CrashedActualLRPs: &report.CrashedActualLRPs,
+	} {
+		if value, ok := c.Demand.Gauge(name); ok {
+			*field = &value
+			received = true
+		}
+	}
+	if value, ok := c.Demand.Gauge(demand.CFAppsDomain); ok {
+		fresh := value == 1
+		report.CFAppsDomainFresh = &fresh
+		received = true
+	}
+	if trend, ok := c.Demand.DesiredTrend(now); ok {
+		trend = truncate2dp(trend)
+		report.DesiredTrendPerHour = &trend
+	}
+
+	var counts []float64
+	for _, cell := range cells {
+		if cell.ContainerCount != nil {
+			counts = append(counts, *cell.ContainerCount)
+			report.ContainerCount += *cell.ContainerCount
+		}
+	}
+	if len(counts) > 0 {
+		received = true
+		report.AverageInstancesPerCell = truncate2dp(report.ContainerCount / float64(len(counts)))
+		sort.Sort(sort.Reverse(sort.Float64Slice(counts)))
+		for i := 0; i < watermarkCellCount && i < len(counts); i++ {
+			report.DrainInstances += counts[i]
+		}
+	}
+	if !received {
+		return nil
+	}
+	return report
+}
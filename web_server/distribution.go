@@ -0,0 +1,83 @@
+package webServer
+
+import (
+	"fmt"
+	"math"
+	"sort"
+)
+
+type distributionReport struct {
+	Min        float64  `json:"min"`
+	Max        float64  `json:"max"`
+	Median     float64  `json:"median"`
+	P10        float64  `json:"p10"`
+	P90        float64  `json:"p90"`
+	StdDev     float64  `json:"stdDev"`
+	Spread     float64  `json:"spread"`
+	Imbalanced bool     `json:"imbalanced"`
+	Outliers   []string `json:"outliers,omitempty"`
+}
+
+// distribution - works out statistics of the free memory of the cells. When the spread between the cells with the
+// most and least free memory exceeds the imbalance limit the cells are imbalanced, and the outliers are the cells
+// whose free memory is more than half of the limit from the median. A limit of 0 disables the imbalance check.
+func distribution(cells []cellReport, imbalanceLimit float64) *distributionReport {
+	if len(cells) == 0 {
+		return nil
+	}
+
+	var memories []float64
+	var sum float64
+	for _, cell := range cells {
+		memories = append(memories, cell.Memory)
+		sum += cell.Memory
+	}
+	sort.Float64s(memories)
+
+	mean := sum / float64(len(memories))
+	var squares float64
+	for _, memory := range memories {
+		squares += (memory - mean) * (memory - mean)
+	}
+
+	report := &distributionReport{
+		Min:    memories[0],
+		Max:    memories[len(memories)-1],
+		Median: percentile(memories, 50),
+		P10:    percentile(memories, 10),
+		P90:    percentile(memories, 90),
+		StdDev: truncate2dp(math.Sqrt(squares / float64(len(memories)))),
+	}
+	report.Spread = report.Max - report.Min
+
+	if imbalanceLimit > 0 && report.Spread > imbalanceLimit {
+		report.Imbalanced = true
+		for _, cell := range cells {
+			if math.Abs(cell.Memory-report.Median) > imbalanceLimit/2 {
+				report.Outliers = append(report.Outliers, cell.Index)
+			}
+		}
+	}
+	return report
+}
+
+// warning - returns the imbalance warning for the report, or an empty string when the cells are balanced
+func (d *distributionReport) warning() string {
+	if d == nil || !d.Imbalanced {
+		return ""
+	}
+	return fmt.Sprintf("Free memory is imbalanced across cells, the spread is %vMB, outliers: %v", d.Spread, d.Outliers)
+}
+
+// percentile - returns the percentile of sorted values, interpolating between the closest ranks
+func percentile(sorted []float64, p float64) float64 {
+	rank := p / 100 * float64(len(sorted)-1)
+	lower := int(math.Floor(rank))
+	upper := int(math.Ceil(rank))
+	return truncate2dp(sorted[lower] + (sorted[upper]-sorted[lower])*(rank-float64(lower)))
+}
+
+// truncate2dp - truncates to 2dp to golang way
+func truncate2dp(value float64) float64 {
+	return float64(int(value*100)) / 100
+}
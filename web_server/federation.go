@@ -0,0 +1,90 @@
+package webServer
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/FidelityInternational/diego-capacity-monitor/federation"
+	"github.com/gorilla/mux"
+	"net/http"
+	"time"
+)
+
+// Federated - serves a global view of the reports of downstream monitors
+type Federated struct {
+	Federation *federation.Federation
+	// StaleDuration - how long a downstream's report is used after it was last fetched
+	StaleDuration time.Duration
+}
+
+// Start - routes the global view and the report of each downstream monitor under /foundations/{name}
+func (f *Federated) Start() *mux.Router {
+	router := mux.NewRouter()
+
+	router.HandleFunc("/", f.Index).Methods("GET")
+	router.HandleFunc("/foundations/{name}", f.Foundation).Methods("GET")
+
+	return router
+}
+
+// Index - returns a json object summarising the report of every downstream monitor, its status is that of the
+// downstream in the most severe state. A downstream whose report has not been fetched or is stale is unknown.
+func (f *Federated) Index(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	overview := newOverviewReport()
+	now := time.Now()
+	for _, result := range f.Federation.Results() {
+		overview.add(f.summarise(result, now))
+	}
+	overview.write(w)
+}
+
+// summarise - summarises a downstream monitor's latest report
+func (f *Federated) summarise(result federation.Result, now time.Time) foundationSummary {
+	summary := foundationSummary{Name: result.Name, URL: result.URL}
+	if result.Err != nil {
+		summary.Error = result.Err.Error()
+	}
+	if result.FetchedAt.IsZero() {
+		summary.Status, summary.Reasons, summary.statusCode = statusUnknown, []string{"unreachable"}, http.StatusServiceUnavailable
+		summary.Message = "The report has not been fetched yet"
+		if summary.Error == "" {
+			summary.Reasons = []string{"initialising"}
+		}
+		return summary
+	}
+
+	report := result.Report
+	summary.Healthy = result.StatusCode == http.StatusOK
+	summary.Status, summary.Reasons, summary.Message = report.Status, report.Reasons, report.Message
+	summary.CellCount, summary.CellMemory, summary.Watermark = report.CellCount, report.CellMemory, report.Watermark
+	summary.TotalFreeMemory, summary.Warnings = report.TotalFreeMemory, report.Warnings
+	summary.FetchedAt = result.FetchedAt.UTC().Format(time.RFC3339)
+	summary.statusCode = result.StatusCode
+	if f.StaleDuration > 0 && now.Sub(result.FetchedAt) > f.StaleDuration {
+		summary.Healthy = false
+		summary.Stale = true
+		summary.Status, summary.Reasons, summary.statusCode = statusUnknown, []string{"stale_report"}, http.StatusServiceUnavailable
+		summary.Message = fmt.Sprintf("The report has not been fetched since %s", summary.FetchedAt)
+	}
+	return summary
+}
+
+// Foundation - returns the latest report of the named downstream monitor as it returned it
+func (f *Federated) Foundation(w http.ResponseWriter, r *http.Request) {
+	name := mux.Vars(r)["name"]
+	result, ok := f.Federation.Result(name)
+	if !ok {
+		writeNoFoundation(w, name)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	summary := f.summarise(result, time.Now())
+	if result.FetchedAt.IsZero() || summary.Stale {
+		w.WriteHeader(http.StatusServiceUnavailable)
+		bytes, _ := json.Marshal(map[string]string{"message": summary.Message, "error": summary.Error})
+		fmt.Fprintf(w, "%v", string(bytes))
+		return
+	}
+	w.WriteHeader(result.StatusCode)
+	fmt.Fprintf(w, "%s", result.Raw)
+}
@@ -0,0 +1,127 @@
+package webServer
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/gorilla/mux"
+	"net/http"
+)
+
+// Foundation - a foundation monitored by its own controller
+type Foundation struct {
+	Name       string
+	Controller *Controller
+}
+
+// Overview - serves the report of each foundation and a combined overview of them
+type Overview struct {
+	Foundations []Foundation
+}
+
+type foundationSummary struct {
+	Name            string   `json:"name"`
+	Healthy         bool     `json:"healthy"`
+	Status          string   `json:"status"`
+	Reasons         []string `json:"reasons"`
+	Message         string   `json:"message"`
+	CellCount       int      `json:"cellCount"`
+	CellMemory      float64  `json:"cellMemory"`
+	Watermark       int      `json:"watermark"`
+	TotalFreeMemory float64  `json:"totalFreeMemory"`
+	Warnings        []string `json:"warnings,omitempty"`
+	// URL, FetchedAt, Stale and Error describe the report of a federated monitor
+	URL        string `json:"url,omitempty"`
+	FetchedAt  string `json:"fetchedAt,omitempty"`
+	Stale      bool   `json:"stale,omitempty"`
+	Error      string `json:"error,omitempty"`
+	statusCode int
+}
+
+type overviewReport struct {
+	Healthy     bool                `json:"healthy"`
+	Status      string              `json:"status"`
+	Message     string              `json:"message"`
+	Foundations []foundationSummary `json:"foundations"`
+	statusCode  int
+}
+
+func newOverviewReport() *overviewReport {
+	return &overviewReport{Status: statusOK, Message: "Everything is awesome!", Foundations: []foundationSummary{}, statusCode: http.StatusOK}
+}
+
+// add - adds a foundation to the overview, which takes the foundation's status when it is the most severe so far
+func (o *overviewReport) add(summary foundationSummary) {
+	o.Foundations = append(o.Foundations, summary)
+	if statusSeverity[summary.Status] > statusSeverity[o.Status] {
+		o.Status = summary.Status
+		o.Message = fmt.Sprintf("Foundation %s: %s", summary.Name, summary.Message)
+		o.statusCode = summary.statusCode
+	}
+}
+
+func (o *overviewReport) write(w http.ResponseWriter) {
+	o.Healthy = o.statusCode == http.StatusOK
+	w.WriteHeader(o.statusCode)
+	bytes, _ := json.Marshal(o)
+	fmt.Fprintf(w, "%v", string(bytes))
+}
+
+// Start - routes the overview and the endpoints of each foundation under /foundations/{name}
+func (o *Overview) Start() *mux.Router {
+	router := mux.NewRouter()
+
+	router.HandleFunc("/", o.Index).Methods("GET")
+	router.HandleFunc("/foundations/{name}", o.foundation((*Controller).Index)).Methods("GET")
+	router.HandleFunc("/foundations/{name}/apps", o.foundation((*Controller).Apps)).Methods("GET")
+	router.HandleFunc("/foundations/{name}/cells/{id}/instances", o.foundation((*Controller).CellInstances)).Methods("GET")
+
+	return router
+}
+
+// Index - returns a json object summarising the health of every foundation, its status is that of the
+// foundation in the most severe state
+func (o *Overview) Index(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	overview := newOverviewReport()
+	for _, foundation := range o.Foundations {
+		report, foundationStatusCode := foundation.Controller.evaluateReport()
+		summary := foundationSummary{
+			Name:            foundation.Name,
+			Healthy:         foundationStatusCode == http.StatusOK,
+			Status:          report.Status,
+			Reasons:         report.Reasons,
+			Message:         report.Message,
+			CellCount:       report.CellCount,
+			CellMemory:      report.CellMemory,
+			Watermark:       report.Watermark,
+			TotalFreeMemory: report.TotalFreeMemory,
+			Warnings:        report.Warnings,
+			statusCode:      foundationStatusCode,
+		}
+		overview.add(summary)
+	}
+	overview.write(w)
+}
+
+// foundation - returns a handler that passes the request to the named foundation's controller, responding with
+// 404 when there is no such foundation
+func (o *Overview) foundation(handler func(*Controller, http.ResponseWriter, *http.Request)) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		name := mux.Vars(r)["name"]
+		for _, foundation := range o.Foundations {
+			if foundation.Name == name {
+				handler(foundation.Controller, w, r)
+				return
+			}
+		}
+		writeNoFoundation(w, name)
+	}
+}
+
+// writeNoFoundation - responds with 404 as there is no foundation with the name
+func writeNoFoundation(w http.ResponseWriter, name string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusNotFound)
+	bytes, _ := json.Marshal(map[string]string{"message": fmt.Sprintf("There is no foundation named %s", name)})
+	fmt.Fprintf(w, "%v", string(bytes))
+}
@@ -0,0 +1,81 @@
+package webServer
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+type instanceSizeReport struct {
+	Size               float64 `json:"size"`
+	PlaceableInstances int     `json:"placeableInstances"`
+	UsableMemory       float64 `json:"usableMemory"`
+	StrandedMemory     float64 `json:"strandedMemory"`
+	// Index - the share of the free memory that is stranded for this size
+	Index float64 `json:"index"`
+}
+
+type fragmentationReport struct {
+	Index             float64              `json:"index"`
+	LargestFreeMemory float64              `json:"largestFreeMemory"`
+	InstanceSizes     []instanceSizeReport `json:"instanceSizes"`
+}
+
+// ParseInstanceSizes - parses a comma separated list of instance sizes in MB, a GB suffix is also accepted
+func ParseInstanceSizes(instanceSizes string) ([]float64, error) {
+	var sizes []float64
+	for _, requestedSize := range strings.Split(instanceSizes, ",") {
+		size := strings.ToUpper(strings.TrimSpace(requestedSize))
+		if size == "" {
+			continue
+		}
+		multiplier := float64(1)
+		if strings.HasSuffix(size, "GB") {
+			multiplier = 1024
+			size = strings.TrimSuffix(size, "GB")
+		}
+		value, err := strconv.ParseFloat(strings.TrimSpace(strings.TrimSuffix(size, "MB")), 64)
+		if err != nil || value <= 0 {
+			return nil, fmt.Errorf("instance size %q must be a positive amount of memory", strings.TrimSpace(requestedSize))
+		}
+		sizes = append(sizes, value*multiplier)
+	}
+	sort.Float64s(sizes)
+	return sizes, nil
+}
+
+// fragmentation - works out how much of the free memory on the cells can actually be used by instances of each
+// size. Each size's index is 1 - (usable memory / total free memory), 0 when all the free memory can be filled with
+// instances of that size and 1 when none can be placed, the overall index is that of the largest size.
+func fragmentation(cells []cellReport, instanceSizes []float64) *fragmentationReport {
+	if len(instanceSizes) == 0 {
+		return nil
+	}
+
+	report := &fragmentationReport{}
+	var totalFreeMemory float64
+	for _, cell := range cells {
+		totalFreeMemory += cell.Memory
+		report.LargestFreeMemory = math.Max(report.LargestFreeMemory, cell.Memory)
+	}
+
+	for _, size := range instanceSizes {
+		sizeReport := instanceSizeReport{Size: size}
+		for _, cell := range cells {
+			if cell.Memory > 0 {
+				sizeReport.PlaceableInstances += int(cell.Memory / size)
+			}
+		}
+		sizeReport.UsableMemory = float64(sizeReport.PlaceableInstances) * size
+		sizeReport.StrandedMemory = math.Max(totalFreeMemory-sizeReport.UsableMemory, 0)
+		if totalFreeMemory > 0 {
+			sizeReport.Index = truncate2dp(sizeReport.StrandedMemory / totalFreeMemory)
+		}
+		// the sizes are sorted so the last is the largest
+		report.Index = sizeReport.Index
+		report.InstanceSizes = append(report.InstanceSizes, sizeReport)
+	}
+	return report
+}
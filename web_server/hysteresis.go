@@ -0,0 +1,95 @@
+package webServer
+
+import (
+	"net/http"
+	"time"
+)
+
+// healthState - the status a pool is held in and a different status it has been evaluated as since then
+type healthState struct {
+	status       string
+	reason       string
+	message      string
+	since        time.Time
+	pending      string
+	pendingSince time.Time
+}
+
+type stateReport struct {
+	State   string              `json:"state"`
+	Since   string              `json:"since"`
+	Pending *pendingStateReport `json:"pending,omitempty"`
+}
+
+type pendingStateReport struct {
+	State string `json:"state"`
+	Since string `json:"since"`
+	At    string `json:"at"`
+}
+
+// hysteresisEnabled - returns true if an exit margin or a minimum state duration is configured
+func (c *Controller) hysteresisEnabled() bool {
+	return c.HysteresisMarginPercent > 0 || c.MinimumStateDuration > 0
+}
+
+// applyHysteresis - holds a pool in its current state until the headroom has recovered past the threshold plus
+// the margin, and until it has been in that state for the minimum duration. Statuses that are not decided by
+// the headroom, e.g. no data, are never held.
+func (c *Controller) applyHysteresis(key string, pool *poolReport, active thresholds, now time.Time) {
+	if !c.hysteresisEnabled() {
+		return
+	}
+	c.statesMutex.Lock()
+	defer c.statesMutex.Unlock()
+	if c.states == nil {
+		c.states = make(map[string]*healthState)
+	}
+
+	state, ok := c.states[key]
+	if !ok || pool.Status == statusUnknown || state.status == statusUnknown {
+		c.states[key] = &healthState{status: pool.Status, reason: pool.Reasons[0], message: pool.Message, since: now}
+		pool.State = c.states[key].report(c.MinimumStateDuration)
+		return
+	}
+
+	// Leaving a state needs the headroom to recover past its threshold by the margin
+	if !pool.worseThan(poolReport{Status: state.status}) && pool.Status != state.status {
+		switch {
+		case state.status == statusCritical && state.reason == "no_upgrade_headroom" &&
+			pool.WatermarkMemoryPercent <= active.HeadroomCriticalPercent+c.HysteresisMarginPercent:
+			pool.setStatus(state.status, state.reason, state.message, http.StatusExpectationFailed)
+		case pool.Status == statusOK && pool.WatermarkMemoryPercent < active.HeadroomWarningPercent+c.HysteresisMarginPercent:
+			pool.setStatus(statusWarning, "low_upgrade_headroom", "The percentage of free memory will be too low during a migration!", http.StatusExpectationFailed)
+		}
+	}
+
+	switch {
+	case pool.Status == state.status:
+		state.reason, state.message = pool.Reasons[0], pool.Message
+		state.pending = ""
+	case !now.Before(state.since.Add(c.MinimumStateDuration)):
+		*state = healthState{status: pool.Status, reason: pool.Reasons[0], message: pool.Message, since: now}
+	default:
+		if state.pending != pool.Status {
+			state.pending, state.pendingSince = pool.Status, now
+		}
+		statusCode := http.StatusExpectationFailed
+		if state.status == statusOK {
+			statusCode = http.StatusOK
+		}
+		pool.setStatus(state.status, state.reason, state.message, statusCode)
+	}
+	pool.State = state.report(c.MinimumStateDuration)
+}
+
+func (s *healthState) report(minimumDuration time.Duration) *stateReport {
+	report := &stateReport{State: s.status, Since: s.since.UTC().Format(time.RFC3339)}
+	if s.pending != "" {
+		report.Pending = &pendingStateReport{
+			State: s.pending,
+			Since: s.pendingSince.UTC().Format(time.RFC3339),
+			At:    s.since.Add(minimumDuration).UTC().Format(time.RFC3339),
+		}
+	}
+	return report
+}
@@ -0,0 +1,77 @@
+package webServer
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/FidelityInternational/diego-capacity-monitor/bbs"
+	"github.com/gorilla/mux"
+	"net/http"
+	"sort"
+	"time"
+)
+
+// instancesSummary - the LRP instances placed on a cell, which would be evacuated if it were drained
+type instancesSummary struct {
+	Count        int      `json:"count"`
+	MemoryMB     int32    `json:"memory_mb"`
+	DiskMB       int32    `json:"disk_mb"`
+	ProcessGUIDs []string `json:"process_guids,omitempty"`
+}
+
+type cellInstancesReport struct {
+	Cell      string `json:"cell"`
+	FetchedAt string `json:"fetchedAt,omitempty"`
+	Message   string `json:"message,omitempty"`
+	instancesSummary
+	Instances []bbs.Instance `json:"instances"`
+}
+
+func summariseInstances(instances []bbs.Instance) instancesSummary {
+	summary := instancesSummary{Count: len(instances)}
+	processGUIDs := make(map[string]bool)
+	for _, instance := range instances {
+		summary.MemoryMB += instance.MemoryMB
+		summary.DiskMB += instance.DiskMB
+		processGUIDs[instance.ProcessGUID] = true
+	}
+	for processGUID := range processGUIDs {
+		summary.ProcessGUIDs = append(summary.ProcessGUIDs, processGUID)
+	}
+	sort.Strings(summary.ProcessGUIDs)
+	return summary
+}
+
+// cellInstances - returns the instances placed on each cell, nil when they are not fetched from the BBS
+func (c *Controller) cellInstances() map[string][]bbs.Instance {
+	if c.Instances == nil {
+		return nil
+	}
+	cells, _, _ := c.Instances.Cells()
+	return cells
+}
+
+// CellInstances - returns a json object of the LRP instances placed on a cell
+func (c *Controller) CellInstances(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	report := cellInstancesReport{Cell: mux.Vars(r)["id"], Instances: []bbs.Instance{}}
+	statusCode := http.StatusOK
+	if c.Instances == nil {
+		report.Message = "Instances are not being fetched from the BBS"
+		statusCode = http.StatusNotFound
+	} else if cells, fetchedAt, ok := c.Instances.Cells(); !ok {
+		report.Message = "The instances have not been fetched from the BBS yet"
+		if err := c.Instances.Err(); err != nil {
+			report.Message = fmt.Sprintf("%s: %v", report.Message, err)
+		}
+		statusCode = http.StatusServiceUnavailable
+	} else {
+		report.FetchedAt = fetchedAt.UTC().Format(time.RFC3339)
+		if instances, ok := cells[report.Cell]; ok {
+			report.Instances = instances
+		}
+		report.instancesSummary = summariseInstances(report.Instances)
+	}
+	w.WriteHeader(statusCode)
+	bytes, _ := json.Marshal(report)
+	fmt.Fprintf(w, "%v", string(bytes))
+}
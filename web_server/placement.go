@@ -0,0 +1,52 @@
+package webServer
+
+import (
+	"fmt"
+	"github.com/FidelityInternational/diego-capacity-monitor/placement"
+	"net/http"
+	"time"
+)
+
+type placementReport struct {
+	WindowSeconds               float64  `json:"windowSeconds"`
+	LRPAuctionsFailed           uint64   `json:"lrpAuctionsFailed"`
+	TaskAuctionsFailed          uint64   `json:"taskAuctionsFailed"`
+	LRPAuctionsFailedPerMinute  float64  `json:"lrpAuctionsFailedPerMinute"`
+	TaskAuctionsFailedPerMinute float64  `json:"taskAuctionsFailedPerMinute"`
+	LRPsMissing                 *float64 `json:"lrpsMissing,omitempty"`
+}
+
+// placementSummary - returns the recent placement failures, ok is false when they are not tracked or no
+// auctioneer or BBS metrics have been received
+func (c *Controller) placementSummary(now time.Time) (placement.Summary, bool) {
+	if c.Placement == nil {
+		return placement.Summary{}, false
+	}
+	return c.Placement.Summary(now)
+}
+
+func newPlacementReport(summary placement.Summary) *placementReport {
+	return &placementReport{
+		WindowSeconds:               summary.Window.Seconds(),
+		LRPAuctionsFailed:           summary.LRPAuctionsFailed,
+		TaskAuctionsFailed:          summary.TaskAuctionsFailed,
+		LRPAuctionsFailedPerMinute:  truncate2dp(summary.LRPAuctionsFailedPerMinute),
+		TaskAuctionsFailedPerMinute: truncate2dp(summary.TaskAuctionsFailedPerMinute),
+		LRPsMissing:                 summary.LRPsMissing,
+	}
+}
+
+// applyPlacementFailures - makes the status critical when the auctioneer has recently failed to place at least
+// the threshold number of LRPs and tasks, a threshold of 0 stops failures changing the status
+func (c *Controller) applyPlacementFailures(overall *poolReport, summary placement.Summary) {
+	if c.PlacementFailureThreshold <= 0 || summary.Failures() < uint64(c.PlacementFailureThreshold) {
+		return
+	}
+	message := fmt.Sprintf("The auctioneer has failed to place %d LRPs and %d tasks in the last %v!",
+		summary.LRPAuctionsFailed, summary.TaskAuctionsFailed, summary.Window)
+	if overall.Status == statusCritical || overall.Status == statusUnknown {
+		overall.Reasons = append(overall.Reasons, "placement_failures")
+		return
+	}
+	overall.setStatus(statusCritical, "placement_failures", message, http.StatusExpectationFailed)
+}
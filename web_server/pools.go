@@ -0,0 +1,184 @@
+package webServer
+
+import (
+	"fmt"
+	"github.com/FidelityInternational/diego-capacity-monitor/metrics"
+	"net/http"
+	"path"
+	"sort"
+	"strings"
+)
+
+const defaultPool = "default"
+
+// Health statuses in order of increasing severity
+const (
+	statusOK       = "ok"
+	statusWarning  = "warning"
+	statusCritical = "critical"
+	statusUnknown  = "unknown"
+)
+
+var statusSeverity = map[string]int{
+	statusOK:       0,
+	statusWarning:  1,
+	statusCritical: 2,
+	statusUnknown:  3,
+}
+
+type poolReport struct {
+	Name                   string               `json:"name"`
+	Healthy                bool                 `json:"healthy"`
+	Status                 string               `json:"status"`
+	Reasons                []string             `json:"reasons"`
+	Message                string               `json:"message"`
+	CellCount              int                  `json:"cellCount"`
+	Watermark              int                  `json:"watermark"`
+	RequestedWatermark     string               `json:"requested_watermark"`
+	TotalFreeMemory        float64              `json:"totalFreeMemory"`
+	WatermarkMemoryPercent float64              `json:"WatermarkMemoryPercent"`
+	Warnings               []string             `json:"warnings,omitempty"`
+	Fragmentation          *fragmentationReport `json:"fragmentation,omitempty"`
+	Distribution           *distributionReport  `json:"distribution,omitempty"`
+	State                  *stateReport         `json:"state,omitempty"`
+	statusCode             int
+}
+
+func (p *poolReport) setStatus(status string, reason string, message string, statusCode int) {
+	p.Status = status
+	p.Reasons = []string{reason}
+	p.Message = message
+	p.statusCode = statusCode
+	p.Healthy = statusCode == http.StatusOK
+}
+
+// worseThan - returns true if the pool is in a more severe state than the other pool
+func (p *poolReport) worseThan(other poolReport) bool {
+	return statusSeverity[p.Status] > statusSeverity[other.Status]
+}
+
+// PoolName - returns the name of the pool a cell belongs to when cells are grouped by the given attribute,
+// one of "deployment", "job" or "placement_tags"
+func PoolName(metric metrics.MessageMetric, poolBy string) string {
+	var name string
+	switch poolBy {
+	case "deployment":
+		name = metric.Deployment
+	case "job":
+		name = metric.Job
+	case "placement_tags":
+		tags := append([]string{}, metric.PlacementTags...)
+		sort.Strings(tags)
+		name = strings.Join(tags, ",")
+	}
+	if name == "" {
+		return defaultPool
+	}
+	return name
+}
+
+// PoolWatermark - a watermark for the pools whose name matches a glob pattern
+type PoolWatermark struct {
+	Pattern   string
+	Watermark string
+}
+
+// ParsePoolWatermarks - parses a comma separated list of pattern=watermark pairs, e.g. "iso-*=2,cf=10%"
+func ParsePoolWatermarks(poolWatermarks string) ([]PoolWatermark, error) {
+	var parsed []PoolWatermark
+	for _, pair := range strings.Split(poolWatermarks, ",") {
+		if strings.TrimSpace(pair) == "" {
+			continue
+		}
+		parts := strings.SplitN(pair, "=", 2)
+		if len(parts) != 2 || strings.TrimSpace(parts[0]) == "" || strings.TrimSpace(parts[1]) == "" {
+			return nil, fmt.Errorf("pool watermark %q must be in the form pattern=watermark", pair)
+		}
+		poolWatermark := PoolWatermark{Pattern: strings.TrimSpace(parts[0]), Watermark: strings.TrimSpace(parts[1])}
+		if _, err := path.Match(poolWatermark.Pattern, ""); err != nil {
+			return nil, fmt.Errorf("pool watermark pattern %q is invalid: %v", poolWatermark.Pattern, err)
+		}
+		parsed = append(parsed, poolWatermark)
+	}
+	return parsed, nil
+}
+
+// watermarkFor - returns the watermark of the first pool pattern matching the pool name, the active profile's
+// patterns are matched before PoolWatermarks so that a profile can change the watermark of any pool, the active
+// watermark is returned when no pattern matches
+func (c *Controller) watermarkFor(name string, active thresholds) string {
+	if name != "" {
+		for _, poolWatermarks := range [][]PoolWatermark{active.PoolWatermarks, c.PoolWatermarks} {
+			for _, poolWatermark := range poolWatermarks {
+				if matched, _ := path.Match(poolWatermark.Pattern, name); matched {
+					return poolWatermark.Watermark
+				}
+			}
+		}
+	}
+	return active.Watermark
+}
+
+// evaluate - works out the health of a set of cells that share a watermark
+func (c *Controller) evaluate(name string, cells []cellReport, active thresholds) (poolReport, error) {
+	pool := poolReport{Name: name, RequestedWatermark: c.watermarkFor(name, active)}
+	for _, cell := range cells {
+		pool.CellCount++
+		pool.TotalFreeMemory += cell.Memory
+	}
+
+	pool.Fragmentation = fragmentation(cells, c.InstanceSizes)
+	pool.Distribution = distribution(cells, c.ImbalanceLimit)
+	if warning := pool.Distribution.warning(); warning != "" {
+		pool.Warnings = append(pool.Warnings, warning)
+	}
+
+	watermarkCellCount, err := c.calculateWatermarkCellCount(pool.RequestedWatermark, pool.CellCount)
+	if err != nil {
+		return pool, err
+	}
+	pool.Watermark = watermarkCellCount
+
+	if pool.CellCount == 0 {
+		pool.setStatus(statusUnknown, "no_data", "I'm sorry Dave I can't show you any data", http.StatusGone)
+		// Panic if we dont have more cells than the watermark
+	} else if pool.CellCount <= watermarkCellCount {
+		pool.setStatus(statusCritical, "insufficient_cells", "The number of cells needs to exceed the watermark amount!", http.StatusExpectationFailed)
+	} else {
+		pool.WatermarkMemoryPercent = WatermarkMemoryPercent2dp(watermarkCellCount, pool.CellCount, c.cellMemory(), pool.TotalFreeMemory)
+
+		// Panic if we do not have enough headroom after watermark cells are discounted
+		if pool.WatermarkMemoryPercent <= active.HeadroomCriticalPercent {
+			pool.setStatus(statusCritical, "no_upgrade_headroom", "FATAL - There is not enough space to do an upgrade, add cells or reduce watermark!", http.StatusExpectationFailed)
+		} else if pool.WatermarkMemoryPercent < active.HeadroomWarningPercent {
+			pool.setStatus(statusWarning, "low_upgrade_headroom", "The percentage of free memory will be too low during a migration!", http.StatusExpectationFailed)
+		} else {
+			pool.setStatus(statusOK, "ok", "Everything is awesome!", http.StatusOK)
+		}
+	}
+	return pool, nil
+}
+
+// evaluatePools - groups cells into pools and evaluates each of them, pools are sorted by name
+func (c *Controller) evaluatePools(cells []cellReport, active thresholds) ([]poolReport, error) {
+	grouped := make(map[string][]cellReport)
+	for _, cell := range cells {
+		grouped[cell.Pool] = append(grouped[cell.Pool], cell)
+	}
+
+	var names []string
+	for name := range grouped {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var pools []poolReport
+	for _, name := range names {
+		pool, err := c.evaluate(name, grouped[name], active)
+		if err != nil {
+			return nil, err
+		}
+		pools = append(pools, pool)
+	}
+	return pools, nil
+}
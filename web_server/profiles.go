@@ -0,0 +1,71 @@
+package webServer
+
+import (
+	"github.com/FidelityInternational/diego-capacity-monitor/schedule"
+	"time"
+)
+
+// thresholds - the watermark and headroom thresholds cells are evaluated against
+type thresholds struct {
+	Profile                 string
+	Watermark               string
+	PoolWatermarks          []PoolWatermark
+	HeadroomWarningPercent  float64
+	HeadroomCriticalPercent float64
+}
+
+type profileReport struct {
+	Name                    string  `json:"name"`
+	Watermark               string  `json:"watermark"`
+	HeadroomWarningPercent  float64 `json:"headroom_warning_percent"`
+	HeadroomCriticalPercent float64 `json:"headroom_critical_percent"`
+	NextSwitch              string  `json:"next_switch,omitempty"`
+	NextProfile             string  `json:"next_profile,omitempty"`
+}
+
+// activeThresholds - returns the thresholds of the profile active at the given time, or the defaults when no
+// schedule is configured
+func (c *Controller) activeThresholds(now time.Time) thresholds {
+	active := thresholds{
+		Profile:                 schedule.DefaultProfileName,
+		Watermark:               *c.Watermark,
+		HeadroomWarningPercent:  c.HeadroomWarningPercent,
+		HeadroomCriticalPercent: c.HeadroomCriticalPercent,
+	}
+	if c.Schedule == nil {
+		return active
+	}
+
+	profile := c.Schedule.Active(now)
+	active.Profile = profile.Name
+	if profile.Watermark != "" {
+		active.Watermark = profile.Watermark
+	}
+	// the profile's pool watermarks were validated at startup
+	active.PoolWatermarks, _ = ParsePoolWatermarks(profile.PoolWatermarks)
+	if profile.HeadroomWarningPercent != nil {
+		active.HeadroomWarningPercent = *profile.HeadroomWarningPercent
+	}
+	if profile.HeadroomCriticalPercent != nil {
+		active.HeadroomCriticalPercent = *profile.HeadroomCriticalPercent
+	}
+	return active
+}
+
+// profileReport - reports the active profile and when it will next change, nil when no schedule is configured
+func (c *Controller) profileReport(now time.Time, active thresholds) *profileReport {
+	if c.Schedule == nil || len(c.Schedule.Profiles) == 0 {
+		return nil
+	}
+	report := &profileReport{
+		Name:                    active.Profile,
+		Watermark:               active.Watermark,
+		HeadroomWarningPercent:  active.HeadroomWarningPercent,
+		HeadroomCriticalPercent: active.HeadroomCriticalPercent,
+	}
+	if switchTime, next, ok := c.Schedule.NextSwitch(now); ok {
+		report.NextSwitch = switchTime.Format(time.RFC3339)
+		report.NextProfile = next.Name
+	}
+	return report
+}
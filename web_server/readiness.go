@@ -0,0 +1,97 @@
+package webServer
+
+import (
+	"sort"
+	"time"
+)
+
+type readinessReport struct {
+	ExpectedCellCount  int    `json:"expectedCellCount,omitempty"`
+	ExpectedFrom       string `json:"expectedFrom,omitempty"`
+	ReportingCellCount int    `json:"reportingCellCount"`
+	KnownCellCount     int    `json:"knownCellCount"`
+	LastNewCell        string `json:"lastNewCell,omitempty"`
+	ReadyBy            string `json:"readyBy"`
+}
+
+// isReady - decides from the cells that have reported whether the monitor has seen enough data to report health,
+// once ready it stays ready. The monitor is ready when
+//   - the expected number of cells are reporting, see expectedCellCount
+//   - otherwise no new cell has been seen for QuietIntervals of the cells' emission interval
+//   - or ReadinessTimeout has passed since the monitor started
+func (c *Controller) isReady(now time.Time, reporting []cellReport, all []cellReport) (bool, *readinessReport) {
+	c.readyMutex.Lock()
+	defer c.readyMutex.Unlock()
+	if c.ready {
+		return true, nil
+	}
+
+	readyBy := c.StartTime.Add(c.ReadinessTimeout)
+	expected, expectedFrom := c.expectedCellCount(all)
+	readiness := &readinessReport{
+		ExpectedCellCount:  expected,
+		ExpectedFrom:       expectedFrom,
+		ReportingCellCount: len(reporting),
+		KnownCellCount:     len(all),
+		ReadyBy:            readyBy.UTC().Format(time.RFC3339),
+	}
+
+	switch {
+	case !now.Before(readyBy):
+		c.ready = true
+	case expected > 0:
+		c.ready = len(reporting) >= expected
+	default:
+		lastNewCell, interval := c.lastNewCell(reporting)
+		if lastNewCell.Before(c.StartTime) {
+			lastNewCell = c.StartTime
+		}
+		readiness.LastNewCell = lastNewCell.UTC().Format(time.RFC3339)
+		quietDuration := time.Duration(int64(interval) * int64(c.QuietIntervals))
+		c.ready = interval > 0 && c.QuietIntervals > 0 && !now.Before(lastNewCell.Add(quietDuration))
+	}
+
+	if c.ready {
+		return true, nil
+	}
+	return false, readiness
+}
+
+// expectedCellCount - returns the number of cells expected to report and where it came from, the configured
+// ExpectedCellCount, otherwise learned from the cells registered with the BBS, otherwise, with redis, from the cells
+// in the stored data, which survive a restart, 0 when it cannot be learned
+func (c *Controller) expectedCellCount(all []cellReport) (int, string) {
+	if c.ExpectedCellCount > 0 {
+		return c.ExpectedCellCount, "configured"
+	}
+	if registered, _, ok := c.registeredCells(); ok && len(registered) > 0 {
+		return len(registered), "registry"
+	}
+	if !c.Metrics.RedisNotUsed() && len(all) > 0 {
+		return len(all), "stored"
+	}
+	return 0, ""
+}
+
+// lastNewCell - returns when the most recently discovered cell was first seen and the median emission interval
+func (c *Controller) lastNewCell(cells []cellReport) (time.Time, time.Duration) {
+	messageMetrics := c.Metrics.GetAll()
+	var (
+		lastNewCell time.Time
+		intervals   []float64
+	)
+	for _, cell := range cells {
+		messageMetric := messageMetrics[cell.Index]
+		if firstSeen := time.Unix(0, messageMetric.FirstSeen); messageMetric.FirstSeen > 0 && firstSeen.After(lastNewCell) {
+			lastNewCell = firstSeen
+		}
+		if messageMetric.Interval > 0 {
+			intervals = append(intervals, float64(messageMetric.Interval))
+		}
+	}
+	if len(intervals) == 0 {
+		return lastNewCell, 0
+	}
+	sort.Float64s(intervals)
+	return lastNewCell, time.Duration(intervals[len(intervals)/2])
+}
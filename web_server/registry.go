@@ -0,0 +1,81 @@
+package webServer
+
+import (
+	"fmt"
+	"github.com/FidelityInternational/diego-capacity-monitor/bbs"
+	"github.com/FidelityInternational/diego-capacity-monitor/metrics"
+	"sort"
+	"time"
+)
+
+// cellUnregistered - the status of a cell that reports capacity but is not registered with the BBS, so it will
+// not be given work
+const cellUnregistered = "unregistered"
+
+type registryReport struct {
+	CellCount int    `json:"cellCount"`
+	FetchedAt string `json:"fetchedAt"`
+	Error     string `json:"error,omitempty"`
+	// UnreportedCells - registered cells that are not reporting capacity on the firehose
+	UnreportedCells []string `json:"unreportedCells,omitempty"`
+	// UnregisteredCells - cells reporting capacity on the firehose that are not registered
+	UnregisteredCells []string `json:"unregisteredCells,omitempty"`
+}
+
+// registeredCells - returns the cells registered with the BBS, ok is false when the registry is not used or no
+// cells have been fetched yet
+func (c *Controller) registeredCells() (map[string]*bbs.CellPresence, time.Time, bool) {
+	if c.Registry == nil {
+		return nil, time.Time{}, false
+	}
+	return c.Registry.Cells()
+}
+
+// reconcile - fills in the zone and placement tags of a firehose metric from the cell's registration when the
+// cell did not tag its envelopes with them
+func reconcile(metric metrics.MessageMetric, presence *bbs.CellPresence) metrics.MessageMetric {
+	if metric.Zone == "" {
+		metric.Zone = presence.Zone
+	}
+	if len(metric.PlacementTags) == 0 {
+		metric.PlacementTags = presence.PlacementTags
+	}
+	return metric
+}
+
+// registryReport - reports the cells registered with the BBS that do not match the cells reporting on the firehose
+func (c *Controller) registryReport(registered map[string]*bbs.CellPresence, fetchedAt time.Time, all []cellReport) *registryReport {
+	report := &registryReport{CellCount: len(registered), FetchedAt: fetchedAt.UTC().Format(time.RFC3339)}
+	if err := c.Registry.Err(); err != nil {
+		report.Error = err.Error()
+	}
+
+	reporting := make(map[string]bool)
+	for _, cell := range all {
+		switch cell.Status {
+		case cellUnregistered:
+			report.UnregisteredCells = append(report.UnregisteredCells, cell.Index)
+		case "":
+			reporting[cell.Index] = true
+		}
+	}
+	for cellID := range registered {
+		if !reporting[cellID] {
+			report.UnreportedCells = append(report.UnreportedCells, cellID)
+		}
+	}
+	sort.Strings(report.UnreportedCells)
+	return report
+}
+
+// warnings - returns warnings for the cells that do not match between the BBS and the firehose
+func (r *registryReport) warnings() []string {
+	var warnings []string
+	if len(r.UnreportedCells) > 0 {
+		warnings = append(warnings, fmt.Sprintf("%d cells registered with the BBS are not reporting capacity: %v", len(r.UnreportedCells), r.UnreportedCells))
+	}
+	if len(r.UnregisteredCells) > 0 {
+		warnings = append(warnings, fmt.Sprintf("%d cells reporting capacity are not registered with the BBS: %v", len(r.UnregisteredCells), r.UnregisteredCells))
+	}
+	return warnings
+}
@@ -26,6 +26,8 @@ func (s *Server) Start() *mux.Router {
 	router := mux.NewRouter()
 
 	router.HandleFunc("/", s.Controller.Index).Methods("GET")
+	router.HandleFunc("/apps", s.Controller.Apps).Methods("GET")
+	router.HandleFunc("/cells/{id}/instances", s.Controller.CellInstances).Methods("GET")
 
 	return router
 }
@@ -0,0 +1,78 @@
+package webServer
+
+import (
+	"fmt"
+	"github.com/FidelityInternational/diego-capacity-monitor/vitals"
+)
+
+type vmReport struct {
+	MemoryPercent        *float64 `json:"memoryPercent,omitempty"`
+	SwapPercent          *float64 `json:"swapPercent,omitempty"`
+	CPUUser              *float64 `json:"cpuUser,omitempty"`
+	EphemeralDiskPercent *float64 `json:"ephemeralDiskPercent,omitempty"`
+	// ReservedPercent - the percentage of the rep's capacity that is reserved, to compare with the memory actually used
+	ReservedPercent float64 `json:"reservedPercent"`
+	// PhysicalMemory - the VM's physical memory in MB
+	PhysicalMemory *float64 `json:"physicalMemory,omitempty"`
+	// Overcommitted - the rep advertises more memory than the VM has
+	Overcommitted bool     `json:"overcommitted"`
+	Pressure      []string `json:"pressure,omitempty"`
+}
+
+// vitalsUsage - returns the BOSH system metrics of each cell, nil when they are not tracked
+func (c *Controller) vitalsUsage() map[string]vitals.Vitals {
+	if c.Vitals == nil {
+		return nil
+	}
+	return c.Vitals.All()
+}
+
+// setVitals - correlates the cell's system metrics with its free memory, flagging pressure and overcommitment
+func (cell *cellReport) setVitals(cellVitals vitals.Vitals, cellMemory float64, thresholds vitals.Thresholds) {
+	vm := &vmReport{Pressure: cellVitals.Pressure(thresholds)}
+	for name, field := range map[string]**float64{
+		vitals.MemoryPercent:        &vm.MemoryPercent,
+		vitals.SwapPercent:          &vm.SwapPercent,
+		vitals.CPUUser:              &vm.CPUUser,
+		vitals.EphemeralDiskPercent: &vm.EphemeralDiskPercent,
+	} {
+		if value, ok := cellVitals.Value(name); ok {
+			value = truncate2dp(value)
+			*field = &value
+		}
+	}
+	if cellMemory > 0 {
+		vm.ReservedPercent = truncate2dp((cellMemory - cell.Memory) / cellMemory * 100)
+	}
+	if physicalMemory, ok := cellVitals.PhysicalMemory(); ok {
+		physicalMemory = truncate2dp(physicalMemory)
+		vm.PhysicalMemory = &physicalMemory
+		vm.Overcommitted = cellMemory > physicalMemory
+	}
+	cell.VM = vm
+}
+
+// vitalsWarnings - returns warnings for the cells whose VM is under pressure or overcommitted
+func vitalsWarnings(cells []cellReport) []string {
+	var pressured, overcommitted []string
+	for _, cell := range cells {
+		if cell.VM == nil {
+			continue
+		}
+		if len(cell.VM.Pressure) > 0 {
+			pressured = append(pressured, cell.Index)
+		}
+		if cell.VM.Overcommitted {
+			overcommitted = append(overcommitted, cell.Index)
+		}
+	}
+
+	var warnings []string
+	if len(pressured) > 0 {
+		warnings = append(warnings, fmt.Sprintf("%d cells are under VM pressure: %v", len(pressured), pressured))
+	}
+	if len(overcommitted) > 0 {
+		warnings = append(warnings, fmt.Sprintf("%d cells advertise more memory than their VM has: %v", len(overcommitted), overcommitted))
+	}
+	return warnings
+}
@@ -1,13 +1,24 @@
 package webServer_test
 
 import (
+	"errors"
+	"fmt"
+	"github.com/FidelityInternational/diego-capacity-monitor/bbs"
+	"github.com/FidelityInternational/diego-capacity-monitor/containers"
+	"github.com/FidelityInternational/diego-capacity-monitor/demand"
+	"github.com/FidelityInternational/diego-capacity-monitor/federation"
 	metricsLib "github.com/FidelityInternational/diego-capacity-monitor/metrics"
+	"github.com/FidelityInternational/diego-capacity-monitor/placement"
+	"github.com/FidelityInternational/diego-capacity-monitor/schedule"
+	"github.com/FidelityInternational/diego-capacity-monitor/vitals"
+	watermarkLib "github.com/FidelityInternational/diego-capacity-monitor/watermark"
 	webs "github.com/FidelityInternational/diego-capacity-monitor/web_server"
 	"github.com/gorilla/mux"
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
 	"net/http"
 	"net/http/httptest"
+	"strconv"
 	"time"
 )
 
@@ -55,19 +66,47 @@ var _ = Describe("Controller", func() {
 
 	Describe("#Index", func() {
 		var (
-			cellMemory   float64
-			watermark    string
-			startTime    time.Time
-			controller   *webs.Controller
-			req          *http.Request
-			mockRecorder *httptest.ResponseRecorder
-			metrics      metricsLib.Metrics
-			timeNow      = time.Now().UnixNano()
+			cellMemory    float64
+			watermark     string
+			startTime     time.Time
+			controller    *webs.Controller
+			req           *http.Request
+			mockRecorder  *httptest.ResponseRecorder
+			metrics       metricsLib.Metrics
+			poolBy        string
+			poolWms       []webs.PoolWatermark
+			profiles      *schedule.Schedule
+			sizes         []float64
+			imbalance     float64
+			missingCells  int
+			expectedCells int
+			readiness     time.Duration
+			usage         *containers.Usage
+			vmStore       *vitals.Store
+			tracker       *placement.Tracker
+			demandTracker *demand.Tracker
+			registry      *bbs.Registry
+			instances     *bbs.Instances
+			timeNow       = time.Now().UnixNano()
 		)
 
 		JustBeforeEach(func() {
 			mockRecorder = httptest.NewRecorder()
 			controller = webs.CreateController(metrics, &cellMemory, &watermark, startTime)
+			controller.PoolBy = poolBy
+			controller.PoolWatermarks = poolWms
+			controller.Schedule = profiles
+			controller.InstanceSizes = sizes
+			controller.ImbalanceLimit = imbalance
+			controller.MissingCellThreshold = missingCells
+			controller.ExpectedCellCount = expectedCells
+			controller.ReadinessTimeout = readiness
+			controller.Containers = usage
+			controller.Vitals = vmStore
+			controller.Placement = tracker
+			controller.Demand = demandTracker
+			controller.Registry = registry
+			controller.Instances = instances
 			req, _ = http.NewRequest("GET", "http://example.com/", nil)
 			Router(controller).ServeHTTP(mockRecorder, req)
 		})
@@ -78,22 +117,46 @@ var _ = Describe("Controller", func() {
 				cellMemory = 10000
 				watermark = "invalid"
 				startTime = time.Now().Add(-1 * time.Minute)
+				readiness = time.Minute
 			})
 
 			It("reports healthy as false with a report message as an error", func() {
 				Ω(mockRecorder.Code).To(Equal(500))
 				Ω(mockRecorder.Body.String()).Should(Equal(`{"healthy":false,"message":"Error occurred while calculating cell count: ` +
-					`strconv.Atoi: parsing \"invalid\": invalid syntax","cellCount":0,"cellMemory":10000,"watermark":0,` +
+					`invalid watermark \"invalid\": \"invalid\" is not a number of cells, a percentage or an amount of memory","status":"unknown","reasons":["invalid_watermark"],"cellCount":0,"cellMemory":10000,"watermark":0,` +
 					`"requested_watermark":"invalid","totalFreeMemory":0,"WatermarkMemoryPercent":0}`))
 			})
 		})
 
+		Context("when the watermark is an amount of memory and the cell memory is not known yet", func() {
+			BeforeEach(func() {
+				metrics = metricsLib.CreateMetrics()
+				metrics.Set("1", metricsLib.MessageMetric{Memory: 5000, Timestamp: timeNow, ReceivedAt: timeNow})
+				cellMemory = 0
+				watermark = "64GB"
+				startTime = time.Now().Add(-1 * time.Minute)
+				readiness = time.Minute
+			})
+
+			AfterEach(func() {
+				metrics.Delete("1")
+				watermark = "1"
+			})
+
+			It("reports that it is initialising rather than an invalid watermark", func() {
+				Ω(mockRecorder.Code).To(Equal(417))
+				Ω(mockRecorder.Body.String()).Should(Equal(`{"healthy":false,"message":"I'm still initialising, please be patient!","status":"unknown","reasons":["initialising"],` +
+					`"cellCount":1,"cellMemory":0,"watermark":0,"requested_watermark":"64GB","totalFreeMemory":5000,"WatermarkMemoryPercent":0}`))
+			})
+		})
+
 		Context("when watermark is valid", func() {
 			BeforeEach(func() {
 				metrics = metricsLib.CreateMetrics()
 				cellMemory = 10000
 				watermark = "1"
 				startTime = time.Now().Add(-1 * time.Minute)
+				readiness = time.Minute
 			})
 
 			AfterEach(func() {
@@ -107,7 +170,7 @@ var _ = Describe("Controller", func() {
 
 				It("reports healthy as false", func() {
 					Ω(mockRecorder.Code).To(Equal(410))
-					Ω(mockRecorder.Body.String()).Should(Equal(`{"healthy":false,"message":"I'm sorry Dave I can't show you any data",` +
+					Ω(mockRecorder.Body.String()).Should(Equal(`{"healthy":false,"message":"I'm sorry Dave I can't show you any data","status":"unknown","reasons":["no_data"],` +
 						`"cellCount":0,"cellMemory":10000,"watermark":1,"requested_watermark":"1","totalFreeMemory":0,"WatermarkMemoryPercent":0}`))
 				})
 			})
@@ -118,10 +181,12 @@ var _ = Describe("Controller", func() {
 						metrics.Set("1", metricsLib.MessageMetric{Memory: 5000, Timestamp: 200})
 					})
 
-					It("reports healthy as false", func() {
+					It("reports healthy as false and the cell as missing", func() {
 						Ω(mockRecorder.Code).To(Equal(410))
-						Ω(mockRecorder.Body.String()).Should(Equal(`{"healthy":false,"message":"I'm sorry Dave I can't show you any data",` +
-							`"cellCount":0,"cellMemory":10000,"watermark":1,"requested_watermark":"1","totalFreeMemory":0,"WatermarkMemoryPercent":0}`))
+						Ω(mockRecorder.Body.String()).Should(Equal(`{"healthy":false,"message":"I'm sorry Dave I can't show you any data","status":"unknown","reasons":["no_data"],` +
+							`"details":[{"index":"1","memory":5000,"low_memory":false,"status":"missing","last_seen":"1970-01-01T00:00:00Z"}],` +
+							`"cellCount":0,"cellMemory":10000,"watermark":1,"requested_watermark":"1","totalFreeMemory":0,"WatermarkMemoryPercent":0,` +
+							`"warnings":["1 of 1 known cells have stopped reporting: [1]"],"missingCellCount":1}`))
 					})
 				})
 
@@ -129,14 +194,100 @@ var _ = Describe("Controller", func() {
 					Context("and the system is initialiseing", func() {
 						BeforeEach(func() {
 							startTime = time.Now()
+							readiness = 5 * time.Minute
 							metrics.Set("1", metricsLib.MessageMetric{Memory: 1000, Timestamp: timeNow})
 						})
 
 						It("reports healthy as false", func() {
 							Ω(mockRecorder.Code).To(Equal(417))
-							Ω(mockRecorder.Body.String()).Should(Equal(`{"healthy":false,"message":"I'm still initialising, please be patient!","details":[` +
+							Ω(mockRecorder.Body.String()).Should(Equal(`{"healthy":false,"message":"I'm still initialising, please be patient!","status":"unknown","reasons":["initialising"],"details":[` +
 								`{"index":"1","memory":1000,"low_memory":true}` +
-								`],"cellCount":1,"cellMemory":10000,"watermark":1,"requested_watermark":"1","totalFreeMemory":1000,"WatermarkMemoryPercent":0}`))
+								`],"cellCount":1,"cellMemory":10000,"watermark":1,"requested_watermark":"1","totalFreeMemory":1000,"WatermarkMemoryPercent":0,"distribution":{"min":1000,"max":1000,"median":1000,"p10":1000,"p90":1000,"stdDev":0,"spread":0,"imbalanced":false},` +
+								`"readiness":{"reportingCellCount":1,"knownCellCount":1,"lastNewCell":"` + startTime.UTC().Format(time.RFC3339) + `",` +
+								`"readyBy":"` + startTime.Add(5*time.Minute).UTC().Format(time.RFC3339) + `"}}`))
+						})
+
+						Context("and the cells are registered with the BBS", func() {
+							BeforeEach(func() {
+								registry = bbs.CreateRegistry(&fakeCellLister{cells: []*bbs.CellPresence{{CellID: "1"}, {CellID: "2"}}})
+								Ω(registry.Refresh()).Should(Succeed())
+							})
+
+							AfterEach(func() {
+								registry = nil
+							})
+
+							It("expects every registered cell to report", func() {
+								Ω(mockRecorder.Code).To(Equal(417))
+								Ω(mockRecorder.Body.String()).Should(ContainSubstring(`"readiness":{"expectedCellCount":2,"expectedFrom":"registry","reportingCellCount":1,"knownCellCount":1,"readyBy":`))
+							})
+
+							Context("and every registered cell is reporting", func() {
+								BeforeEach(func() {
+									metrics.Set("2", metricsLib.MessageMetric{Memory: 1000, Timestamp: timeNow})
+								})
+
+								It("is ready", func() {
+									Ω(mockRecorder.Body.String()).ShouldNot(ContainSubstring(`"initialising"`))
+								})
+							})
+						})
+
+						Context("and the expected number of cells are reporting", func() {
+							BeforeEach(func() {
+								expectedCells = 2
+								metrics.Set("2", metricsLib.MessageMetric{Memory: 1000, Timestamp: timeNow})
+							})
+
+							AfterEach(func() {
+								expectedCells = 0
+							})
+
+							It("is ready", func() {
+								Ω(mockRecorder.Body.String()).ShouldNot(ContainSubstring(`"initialising"`))
+							})
+						})
+
+						Context("and fewer than the expected number of cells are reporting", func() {
+							BeforeEach(func() {
+								expectedCells = 2
+							})
+
+							AfterEach(func() {
+								expectedCells = 0
+							})
+
+							It("is initialising", func() {
+								Ω(mockRecorder.Body.String()).Should(ContainSubstring(`"readiness":{"expectedCellCount":2,"expectedFrom":"configured","reportingCellCount":1,"knownCellCount":1,"readyBy":`))
+							})
+						})
+
+						Context("and no new cells have been seen for the quiet intervals", func() {
+							BeforeEach(func() {
+								startTime = time.Now().Add(-2 * time.Minute)
+								receivedAt := time.Now().Add(-10 * time.Second).UnixNano()
+								metrics.Set("1", metricsLib.MessageMetric{Memory: 1000, Timestamp: timeNow, ReceivedAt: receivedAt,
+									FirstSeen: time.Now().Add(-2 * time.Minute).UnixNano(), Interval: int64(30 * time.Second)})
+							})
+
+							It("is ready", func() {
+								Ω(mockRecorder.Body.String()).ShouldNot(ContainSubstring(`"initialising"`))
+							})
+						})
+
+						Context("and a new cell has been seen within the quiet intervals", func() {
+							BeforeEach(func() {
+								startTime = time.Now().Add(-2 * time.Minute)
+								receivedAt := time.Now().Add(-10 * time.Second).UnixNano()
+								metrics.Set("1", metricsLib.MessageMetric{Memory: 1000, Timestamp: timeNow, ReceivedAt: receivedAt,
+									FirstSeen: time.Now().Add(-2 * time.Minute).UnixNano(), Interval: int64(30 * time.Second)})
+								metrics.Set("2", metricsLib.MessageMetric{Memory: 1000, Timestamp: timeNow, ReceivedAt: receivedAt,
+									FirstSeen: time.Now().Add(-1 * time.Minute).UnixNano(), Interval: int64(30 * time.Second)})
+							})
+
+							It("is initialising", func() {
+								Ω(mockRecorder.Body.String()).Should(ContainSubstring(`"initialising"`))
+							})
 						})
 					})
 
@@ -148,10 +299,207 @@ var _ = Describe("Controller", func() {
 
 						It("reports healthy as true", func() {
 							Ω(mockRecorder.Code).To(Equal(200))
-							Ω(mockRecorder.Body.String()).Should(Equal(`{"healthy":true,"message":"Everything is awesome!","details":[` +
+							Ω(mockRecorder.Body.String()).Should(Equal(`{"healthy":true,"message":"Everything is awesome!","status":"ok","reasons":["ok"],"details":[` +
 								`{"index":"1","memory":6321,"low_memory":false},` +
 								`{"index":"2","memory":6321,"low_memory":false}` +
-								`],"cellCount":2,"cellMemory":10000,"watermark":1,"requested_watermark":"1","totalFreeMemory":12642,"WatermarkMemoryPercent":26.42}`))
+								`],"cellCount":2,"cellMemory":10000,"watermark":1,"requested_watermark":"1","totalFreeMemory":12642,"WatermarkMemoryPercent":26.42,"distribution":{"min":6321,"max":6321,"median":6321,"p10":6321,"p90":6321,"stdDev":0,"spread":0,"imbalanced":false}}`))
+						})
+					})
+
+					Context("and the app instances' memory use is known", func() {
+						BeforeEach(func() {
+							metrics.Set("1", metricsLib.MessageMetric{Memory: 6321, Timestamp: timeNow})
+							metrics.Set("2", metricsLib.MessageMetric{Memory: 6321, Timestamp: timeNow})
+							usage = containers.CreateUsage()
+							usage.Record(containers.Instance{AppID: "app-a", Cell: "1", MemoryBytes: 256 * 1024 * 1024, MemoryBytesQuota: 1024 * 1024 * 1024})
+						})
+
+						AfterEach(func() {
+							usage = nil
+						})
+
+						It("reports the actual and reserved memory of the cells and the safe overcommit", func() {
+							Ω(mockRecorder.Code).To(Equal(200))
+							Ω(mockRecorder.Body.String()).Should(ContainSubstring(`{"index":"1","memory":6321,"actual_memory":256,"reserved_memory":1024,"low_memory":false}`))
+							Ω(mockRecorder.Body.String()).Should(ContainSubstring(`{"index":"2","memory":6321,"low_memory":false}`))
+							Ω(mockRecorder.Body.String()).Should(ContainSubstring(`"containers":{"instanceCount":1,"actualMemory":256,"reservedMemory":1024,"unusedReservedMemory":768,` +
+								`"safeOvercommitMemory":716.8,"overcommitHeadroomPercent":20}`))
+						})
+					})
+
+					Context("and the cells' system metrics are known", func() {
+						BeforeEach(func() {
+							metrics.Set("1", metricsLib.MessageMetric{Memory: 6321, Timestamp: timeNow})
+							metrics.Set("2", metricsLib.MessageMetric{Memory: 6321, Timestamp: timeNow})
+							vmStore = vitals.CreateStore()
+							vmStore.Record("1", vitals.MemoryPercent, 50)
+							vmStore.Record("1", vitals.MemoryKB, 4194304)
+							vmStore.Record("1", vitals.SwapPercent, 20)
+							vmStore.Record("2", vitals.MemoryPercent, 40)
+						})
+
+						AfterEach(func() {
+							vmStore = nil
+						})
+
+						It("flags cells under pressure and overcommitted without changing the health", func() {
+							Ω(mockRecorder.Code).To(Equal(200))
+							Ω(mockRecorder.Body.String()).Should(ContainSubstring(`{"index":"1","memory":6321,"low_memory":false,` +
+								`"vm":{"memoryPercent":50,"swapPercent":20,"reservedPercent":36.79,"physicalMemory":8192,"overcommitted":true,"pressure":["swap"]}}`))
+							Ω(mockRecorder.Body.String()).Should(ContainSubstring(`{"index":"2","memory":6321,"low_memory":false,` +
+								`"vm":{"memoryPercent":40,"reservedPercent":36.79,"overcommitted":false}}`))
+							Ω(mockRecorder.Body.String()).Should(ContainSubstring(`"warnings":["1 cells are under VM pressure: [1]","1 cells advertise more memory than their VM has: [1]"]`))
+						})
+					})
+
+					Context("and the auctioneer is tracked", func() {
+						BeforeEach(func() {
+							metrics.Set("1", metricsLib.MessageMetric{Memory: 6321, Timestamp: timeNow})
+							metrics.Set("2", metricsLib.MessageMetric{Memory: 6321, Timestamp: timeNow})
+							tracker = placement.CreateTracker()
+							tracker.RecordGauge(placement.LRPsMissing, 0)
+						})
+
+						AfterEach(func() {
+							tracker = nil
+						})
+
+						It("reports the placement failure rates", func() {
+							Ω(mockRecorder.Code).To(Equal(200))
+							Ω(mockRecorder.Body.String()).Should(ContainSubstring(`"placement":{"windowSeconds":300,"lrpAuctionsFailed":0,"taskAuctionsFailed":0,` +
+								`"lrpAuctionsFailedPerMinute":0,"taskAuctionsFailedPerMinute":0,"lrpsMissing":0}`))
+						})
+
+						Context("and it has recently failed to place work", func() {
+							BeforeEach(func() {
+								tracker.RecordCounter(placement.LRPAuctionsFailed, 5)
+								tracker.RecordCounter(placement.TaskAuctionsFailed, 2)
+							})
+
+							It("reports healthy as false", func() {
+								Ω(mockRecorder.Code).To(Equal(417))
+								Ω(mockRecorder.Body.String()).Should(ContainSubstring(`"message":"The auctioneer has failed to place 5 LRPs and 2 tasks in the last 5m0s!",` +
+									`"status":"critical","reasons":["placement_failures"]`))
+								Ω(mockRecorder.Body.String()).Should(ContainSubstring(`"lrpAuctionsFailedPerMinute":1,"taskAuctionsFailedPerMinute":0.4,`))
+							})
+						})
+					})
+
+					Context("and the source reports the cells' disk and containers", func() {
+						BeforeEach(func() {
+							metrics.Set("1", metricsLib.MessageMetric{Memory: 6321, Timestamp: timeNow,
+								Resources: &metricsLib.Resources{RemainingDisk: 10240, TotalDisk: 65536, RemainingContainers: 200, TotalContainers: 250}})
+							metrics.Set("2", metricsLib.MessageMetric{Memory: 6321, Timestamp: timeNow})
+						})
+
+						It("reports them alongside the cells", func() {
+							Ω(mockRecorder.Code).To(Equal(200))
+							Ω(mockRecorder.Body.String()).Should(ContainSubstring(`{"index":"1","memory":6321,"low_memory":false,` +
+								`"resources":{"remaining_disk_mb":10240,"total_disk_mb":65536,"remaining_containers":200,"total_containers":250}}`))
+							Ω(mockRecorder.Body.String()).Should(ContainSubstring(`{"index":"2","memory":6321,"low_memory":false}`))
+						})
+					})
+
+					Context("and the demand for instances is tracked", func() {
+						BeforeEach(func() {
+							metrics.Set("1", metricsLib.MessageMetric{Memory: 6321, Timestamp: timeNow})
+							metrics.Set("2", metricsLib.MessageMetric{Memory: 6321, Timestamp: timeNow})
+							demandTracker = demand.CreateTracker()
+							demandTracker.RecordGauge("bbs", demand.LRPsDesired, 30)
+							demandTracker.RecordGauge("bbs", demand.LRPsRunning, 29)
+							demandTracker.RecordGauge("bbs", demand.CFAppsDomain, 1)
+							demandTracker.RecordGauge("1", demand.ContainerCount, 20)
+							demandTracker.RecordGauge("2", demand.ContainerCount, 9)
+						})
+
+						AfterEach(func() {
+							demandTracker = nil
+						})
+
+						It("reports the demand alongside the cells", func() {
+							Ω(mockRecorder.Code).To(Equal(200))
+							Ω(mockRecorder.Body.String()).Should(ContainSubstring(`{"index":"1","memory":6321,"low_memory":false,"container_count":20}`))
+							Ω(mockRecorder.Body.String()).Should(ContainSubstring(`"demand":{"lrpsDesired":30,"lrpsRunning":29,"cfAppsDomainFresh":true,` +
+								`"containerCount":29,"averageInstancesPerCell":14.5,"drainInstances":20}`))
+						})
+					})
+
+					Context("and the cells are registered with the BBS", func() {
+						BeforeEach(func() {
+							metrics.Set("1", metricsLib.MessageMetric{Memory: 6321, Timestamp: timeNow})
+							metrics.Set("2", metricsLib.MessageMetric{Memory: 6321, Timestamp: timeNow})
+							metrics.Set("3", metricsLib.MessageMetric{Memory: 6321, Timestamp: timeNow})
+							registry = bbs.CreateRegistry(&fakeCellLister{cells: []*bbs.CellPresence{
+								{CellID: "1", Zone: "z1", Capacity: &bbs.CellCapacity{MemoryMB: 10000, DiskMB: 20000, Containers: 250}},
+								{CellID: "2", Zone: "z2", Capacity: &bbs.CellCapacity{MemoryMB: 10000, DiskMB: 20000, Containers: 250}},
+								{CellID: "4", Zone: "z2", Capacity: &bbs.CellCapacity{MemoryMB: 10000, DiskMB: 20000, Containers: 250}},
+							}})
+							Ω(registry.Refresh()).Should(Succeed())
+						})
+
+						AfterEach(func() {
+							registry = nil
+						})
+
+						It("reconciles the registered cells with the firehose and does not count unregistered cells", func() {
+							Ω(mockRecorder.Code).To(Equal(200))
+							Ω(mockRecorder.Body.String()).Should(ContainSubstring(`{"index":"1","memory":6321,"low_memory":false,"zone":"z1",` +
+								`"capacity":{"memory_mb":10000,"disk_mb":20000,"containers":250}}`))
+							Ω(mockRecorder.Body.String()).Should(ContainSubstring(`{"index":"3","memory":6321,"low_memory":false,"status":"unregistered"}`))
+							Ω(mockRecorder.Body.String()).Should(ContainSubstring(`"cellCount":2,`))
+							Ω(mockRecorder.Body.String()).Should(MatchRegexp(`"registry":\{"cellCount":3,"fetchedAt":"[^"]+","unreportedCells":\["4"\],"unregisteredCells":\["3"\]\}`))
+							Ω(mockRecorder.Body.String()).Should(ContainSubstring(`"1 cells registered with the BBS are not reporting capacity: [4]",` +
+								`"1 cells reporting capacity are not registered with the BBS: [3]"`))
+						})
+
+						Context("and the cell memory has not been reported yet", func() {
+							BeforeEach(func() {
+								cellMemory = 0
+							})
+
+							AfterEach(func() {
+								cellMemory = 10000
+							})
+
+							It("takes the cell memory from the capacity registered with the BBS", func() {
+								Ω(mockRecorder.Code).To(Equal(200))
+								Ω(mockRecorder.Body.String()).Should(ContainSubstring(`"cellMemory":10000,`))
+							})
+						})
+					})
+
+					Context("and the instances on the cells are fetched from the BBS", func() {
+						BeforeEach(func() {
+							metrics.Set("1", metricsLib.MessageMetric{Memory: 6321, Timestamp: timeNow})
+							metrics.Set("2", metricsLib.MessageMetric{Memory: 6321, Timestamp: timeNow})
+							instances = bbs.CreateInstances(newFakeLRPLister())
+							Ω(instances.Refresh()).Should(Succeed())
+						})
+
+						AfterEach(func() {
+							instances = nil
+						})
+
+						It("reports the instances that would be evacuated from each cell", func() {
+							Ω(mockRecorder.Code).To(Equal(200))
+							Ω(mockRecorder.Body.String()).Should(ContainSubstring(`{"index":"1","memory":6321,"low_memory":false,` +
+								`"instances":{"count":2,"memory_mb":1280,"disk_mb":3072,"process_guids":["app-a","app-b"]}}`))
+							Ω(mockRecorder.Body.String()).Should(ContainSubstring(`{"index":"2","memory":6321,"low_memory":false}`))
+						})
+					})
+
+					Context("and the cells' memory has been smoothed", func() {
+						BeforeEach(func() {
+							smoothed := 1500.0
+							metrics.Set("1", metricsLib.MessageMetric{Memory: 6321, Timestamp: timeNow, SmoothedMemory: &smoothed})
+							metrics.Set("2", metricsLib.MessageMetric{Memory: 6321, Timestamp: timeNow, SmoothedMemory: &smoothed})
+						})
+
+						It("evaluates health with the smoothed memory and shows the raw memory", func() {
+							Ω(mockRecorder.Code).To(Equal(417))
+							Ω(mockRecorder.Body.String()).Should(ContainSubstring(`"reasons":["no_upgrade_headroom"]`))
+							Ω(mockRecorder.Body.String()).Should(ContainSubstring(`{"index":"1","memory":1500,"raw_memory":6321,"low_memory":true}`))
+							Ω(mockRecorder.Body.String()).Should(ContainSubstring(`"totalFreeMemory":3000,`))
 						})
 					})
 
@@ -163,9 +511,9 @@ var _ = Describe("Controller", func() {
 
 							It("reports healthy as true", func() {
 								Ω(mockRecorder.Code).To(Equal(417))
-								Ω(mockRecorder.Body.String()).Should(Equal(`{"healthy":false,"message":"The number of cells needs to exceed the watermark amount!","details":[` +
+								Ω(mockRecorder.Body.String()).Should(Equal(`{"healthy":false,"message":"The number of cells needs to exceed the watermark amount!","status":"critical","reasons":["insufficient_cells"],"details":[` +
 									`{"index":"1","memory":6000,"low_memory":false}` +
-									`],"cellCount":1,"cellMemory":10000,"watermark":1,"requested_watermark":"1","totalFreeMemory":6000,"WatermarkMemoryPercent":0}`))
+									`],"cellCount":1,"cellMemory":10000,"watermark":1,"requested_watermark":"1","totalFreeMemory":6000,"WatermarkMemoryPercent":0,"distribution":{"min":6000,"max":6000,"median":6000,"p10":6000,"p90":6000,"stdDev":0,"spread":0,"imbalanced":false}}`))
 							})
 						})
 
@@ -175,11 +523,44 @@ var _ = Describe("Controller", func() {
 								metrics.Set("2", metricsLib.MessageMetric{Memory: 6000, Timestamp: 200})
 							})
 
-							It("reports healthy as true", func() {
+							It("reports healthy as false and the stale cell as missing", func() {
 								Ω(mockRecorder.Code).To(Equal(417))
-								Ω(mockRecorder.Body.String()).Should(Equal(`{"healthy":false,"message":"The number of cells needs to exceed the watermark amount!","details":[` +
-									`{"index":"1","memory":6000,"low_memory":false}` +
-									`],"cellCount":1,"cellMemory":10000,"watermark":1,"requested_watermark":"1","totalFreeMemory":6000,"WatermarkMemoryPercent":0}`))
+								Ω(mockRecorder.Body.String()).Should(Equal(`{"healthy":false,"message":"The number of cells needs to exceed the watermark amount!","status":"critical","reasons":["insufficient_cells"],"details":[` +
+									`{"index":"1","memory":6000,"low_memory":false},` +
+									`{"index":"2","memory":6000,"low_memory":false,"status":"missing","last_seen":"1970-01-01T00:00:00Z"}` +
+									`],"cellCount":1,"cellMemory":10000,"watermark":1,"requested_watermark":"1","totalFreeMemory":6000,"WatermarkMemoryPercent":0,` +
+									`"warnings":["1 of 2 known cells have stopped reporting: [2]"],` +
+									`"distribution":{"min":6000,"max":6000,"median":6000,"p10":6000,"p90":6000,"stdDev":0,"spread":0,"imbalanced":false},"missingCellCount":1}`))
+							})
+
+							Context("and a cell has missed its emission intervals", func() {
+								BeforeEach(func() {
+									receivedAt := time.Now().Add(-2 * time.Minute)
+									metrics.Set("3", metricsLib.MessageMetric{Memory: 6000, Timestamp: receivedAt.Add(-1500 * time.Millisecond).UnixNano(),
+										ReceivedAt: receivedAt.UnixNano(), Interval: int64(30 * time.Second)})
+								})
+
+								It("reports the cell as stale with its interval and clock skew without counting it", func() {
+									Ω(mockRecorder.Body.String()).Should(ContainSubstring(`{"index":"3","memory":6000,"low_memory":false,"status":"stale","last_seen":`))
+									Ω(mockRecorder.Body.String()).Should(ContainSubstring(`"emission_interval_seconds":30,"clock_skew_seconds":1.5}`))
+									Ω(mockRecorder.Body.String()).Should(ContainSubstring(`"cellCount":1,`))
+									Ω(mockRecorder.Body.String()).Should(ContainSubstring(`"missingCellCount":1`))
+								})
+							})
+
+							Context("and fewer cells than the threshold are missing", func() {
+								BeforeEach(func() {
+									missingCells = 1
+								})
+
+								AfterEach(func() {
+									missingCells = 0
+								})
+
+								It("does not warn about the missing cells", func() {
+									Ω(mockRecorder.Body.String()).ShouldNot(ContainSubstring(`"warnings"`))
+									Ω(mockRecorder.Body.String()).Should(ContainSubstring(`"missingCellCount":1`))
+								})
 							})
 						})
 					})
@@ -195,12 +576,12 @@ var _ = Describe("Controller", func() {
 
 							It("reports healthy as false", func() {
 								Ω(mockRecorder.Code).To(Equal(417))
-								Ω(mockRecorder.Body.String()).Should(Equal(`{"healthy":false,"message":"FATAL - There is not enough space to do an upgrade, add cells or reduce watermark!","details":[` +
+								Ω(mockRecorder.Body.String()).Should(Equal(`{"healthy":false,"message":"FATAL - There is not enough space to do an upgrade, add cells or reduce watermark!","status":"critical","reasons":["no_upgrade_headroom"],"details":[` +
 									`{"index":"1","memory":2100,"low_memory":false},` +
 									`{"index":"2","memory":2100,"low_memory":false},` +
 									`{"index":"3","memory":2100,"low_memory":false},` +
 									`{"index":"4","memory":2100,"low_memory":false}` +
-									`],"cellCount":4,"cellMemory":10000,"watermark":1,"requested_watermark":"1","totalFreeMemory":8400,"WatermarkMemoryPercent":-5.33}`))
+									`],"cellCount":4,"cellMemory":10000,"watermark":1,"requested_watermark":"1","totalFreeMemory":8400,"WatermarkMemoryPercent":-5.33,"distribution":{"min":2100,"max":2100,"median":2100,"p10":2100,"p90":2100,"stdDev":0,"spread":0,"imbalanced":false}}`))
 							})
 						})
 
@@ -214,12 +595,12 @@ var _ = Describe("Controller", func() {
 
 							It("reports healthy as false", func() {
 								Ω(mockRecorder.Code).To(Equal(417))
-								Ω(mockRecorder.Body.String()).Should(Equal(`{"healthy":false,"message":"The percentage of free memory will be too low during a migration!","details":[` +
+								Ω(mockRecorder.Body.String()).Should(Equal(`{"healthy":false,"message":"The percentage of free memory will be too low during a migration!","status":"warning","reasons":["low_upgrade_headroom"],"details":[` +
 									`{"index":"1","memory":3100,"low_memory":false},` +
 									`{"index":"2","memory":3100,"low_memory":false},` +
 									`{"index":"3","memory":3100,"low_memory":false},` +
 									`{"index":"4","memory":3100,"low_memory":false}` +
-									`],"cellCount":4,"cellMemory":10000,"watermark":1,"requested_watermark":"1","totalFreeMemory":12400,"WatermarkMemoryPercent":8}`))
+									`],"cellCount":4,"cellMemory":10000,"watermark":1,"requested_watermark":"1","totalFreeMemory":12400,"WatermarkMemoryPercent":8,"distribution":{"min":3100,"max":3100,"median":3100,"p10":3100,"p90":3100,"stdDev":0,"spread":0,"imbalanced":false}}`))
 							})
 						})
 
@@ -232,11 +613,229 @@ var _ = Describe("Controller", func() {
 
 							It("reports healthy as true", func() {
 								Ω(mockRecorder.Code).To(Equal(200))
-								Ω(mockRecorder.Body.String()).Should(Equal(`{"healthy":true,"message":"Everything is awesome!","details":[` +
+								Ω(mockRecorder.Body.String()).Should(Equal(`{"healthy":true,"message":"Everything is awesome!","status":"ok","reasons":["ok"],"details":[` +
 									`{"index":"1","memory":5000,"low_memory":false},` +
 									`{"index":"2","memory":5000,"low_memory":false},` +
 									`{"index":"3","memory":5000,"low_memory":false}` +
-									`],"cellCount":3,"cellMemory":10000,"watermark":1,"requested_watermark":"1","totalFreeMemory":15000,"WatermarkMemoryPercent":25}`))
+									`],"cellCount":3,"cellMemory":10000,"watermark":1,"requested_watermark":"1","totalFreeMemory":15000,"WatermarkMemoryPercent":25,"distribution":{"min":5000,"max":5000,"median":5000,"p10":5000,"p90":5000,"stdDev":0,"spread":0,"imbalanced":false}}`))
+							})
+						})
+
+						Context("and the cells are grouped into pools by deployment", func() {
+							BeforeEach(func() {
+								poolBy = "deployment"
+								metrics.Set("1", metricsLib.MessageMetric{Memory: 5000, Timestamp: timeNow, Deployment: "cf"})
+								metrics.Set("2", metricsLib.MessageMetric{Memory: 5000, Timestamp: timeNow, Deployment: "cf"})
+								metrics.Set("3", metricsLib.MessageMetric{Memory: 5000, Timestamp: timeNow, Deployment: "cf"})
+								metrics.Set("4", metricsLib.MessageMetric{Memory: 5500, Timestamp: timeNow, Deployment: "iso"})
+								metrics.Set("5", metricsLib.MessageMetric{Memory: 5500, Timestamp: timeNow, Deployment: "iso"})
+							})
+
+							AfterEach(func() {
+								poolBy = ""
+							})
+
+							It("reports each pool and takes the overall status from the worst pool", func() {
+								Ω(mockRecorder.Code).To(Equal(417))
+								Ω(mockRecorder.Body.String()).Should(Equal(`{"healthy":false,"message":"Pool iso: The percentage of free memory will be too low during a migration!",` +
+									`"status":"warning","reasons":["low_upgrade_headroom"],"details":[` +
+									`{"index":"1","memory":5000,"low_memory":false,"pool":"cf"},` +
+									`{"index":"2","memory":5000,"low_memory":false,"pool":"cf"},` +
+									`{"index":"3","memory":5000,"low_memory":false,"pool":"cf"},` +
+									`{"index":"4","memory":5500,"low_memory":false,"pool":"iso"},` +
+									`{"index":"5","memory":5500,"low_memory":false,"pool":"iso"}` +
+									`],"cellCount":5,"cellMemory":10000,"watermark":1,"requested_watermark":"1","totalFreeMemory":26000,"WatermarkMemoryPercent":40,"pools":[` +
+									`{"name":"cf","healthy":true,"status":"ok","reasons":["ok"],"message":"Everything is awesome!","cellCount":3,"watermark":1,` +
+									`"requested_watermark":"1","totalFreeMemory":15000,"WatermarkMemoryPercent":25,` +
+									`"distribution":{"min":5000,"max":5000,"median":5000,"p10":5000,"p90":5000,"stdDev":0,"spread":0,"imbalanced":false}},` +
+									`{"name":"iso","healthy":false,"status":"warning","reasons":["low_upgrade_headroom"],` +
+									`"message":"The percentage of free memory will be too low during a migration!","cellCount":2,"watermark":1,` +
+									`"requested_watermark":"1","totalFreeMemory":11000,"WatermarkMemoryPercent":10,` +
+									`"distribution":{"min":5500,"max":5500,"median":5500,"p10":5500,"p90":5500,"stdDev":0,"spread":0,"imbalanced":false}}],` +
+									`"distribution":{"min":5000,"max":5500,"median":5000,"p10":5000,"p90":5500,"stdDev":244.94,"spread":500,"imbalanced":false}}`))
+							})
+
+							Context("and the pool has its own watermark", func() {
+								BeforeEach(func() {
+									poolWms = []webs.PoolWatermark{{Pattern: "is*", Watermark: "0"}, {Pattern: "iso", Watermark: "2"}}
+								})
+
+								AfterEach(func() {
+									poolWms = nil
+								})
+
+								It("evaluates the pool with the first matching watermark", func() {
+									Ω(mockRecorder.Code).To(Equal(200))
+									Ω(mockRecorder.Body.String()).Should(ContainSubstring(`{"name":"iso","healthy":true,"status":"ok","reasons":["ok"],` +
+										`"message":"Everything is awesome!","cellCount":2,"watermark":0,"requested_watermark":"0","totalFreeMemory":11000,"WatermarkMemoryPercent":55,`))
+									Ω(mockRecorder.Body.String()).Should(ContainSubstring(`{"name":"cf","healthy":true,"status":"ok","reasons":["ok"],` +
+										`"message":"Everything is awesome!","cellCount":3,"watermark":1,"requested_watermark":"1","totalFreeMemory":15000,"WatermarkMemoryPercent":25,"distribution":{"min":5000,"max":5000,"median":5000,"p10":5000,"p90":5000,"stdDev":0,"spread":0,"imbalanced":false}}`))
+								})
+
+								Context("and a scheduled profile is active", func() {
+									BeforeEach(func() {
+										var err error
+										profiles, err = schedule.Parse(`[{"name":"always","watermark":"0"}]`)
+										Ω(err).Should(BeNil())
+									})
+
+									AfterEach(func() {
+										profiles = nil
+									})
+
+									It("keeps the pool's watermark and uses the profile's watermark for the other pools", func() {
+										Ω(mockRecorder.Body.String()).Should(ContainSubstring(`{"name":"iso","healthy":true,"status":"ok","reasons":["ok"],` +
+											`"message":"Everything is awesome!","cellCount":2,"watermark":0,"requested_watermark":"0",`))
+										Ω(mockRecorder.Body.String()).Should(ContainSubstring(`{"name":"cf","healthy":true,"status":"ok","reasons":["ok"],` +
+											`"message":"Everything is awesome!","cellCount":3,"watermark":0,"requested_watermark":"0",`))
+									})
+
+									Context("and the profile has its own pool watermarks", func() {
+										BeforeEach(func() {
+											var err error
+											profiles, err = schedule.Parse(`[{"name":"always","watermark":"0","pool_watermarks":"iso=1"}]`)
+											Ω(err).Should(BeNil())
+										})
+
+										It("evaluates the pool with the profile's watermark before the pool's own", func() {
+											Ω(mockRecorder.Body.String()).Should(ContainSubstring(`"cellCount":2,"watermark":1,"requested_watermark":"1","totalFreeMemory":11000,`))
+											Ω(mockRecorder.Body.String()).Should(ContainSubstring(`{"name":"cf","healthy":true,"status":"ok","reasons":["ok"],` +
+												`"message":"Everything is awesome!","cellCount":3,"watermark":0,"requested_watermark":"0",`))
+										})
+									})
+								})
+							})
+						})
+
+						Context("and a scheduled profile is active", func() {
+							BeforeEach(func() {
+								var err error
+								profiles, err = schedule.Parse(`[{"name":"always","watermark":"0","headroom_warning_percent":60}]`)
+								Ω(err).Should(BeNil())
+								metrics.Set("1", metricsLib.MessageMetric{Memory: 5000, Timestamp: timeNow})
+								metrics.Set("2", metricsLib.MessageMetric{Memory: 5000, Timestamp: timeNow})
+								metrics.Set("3", metricsLib.MessageMetric{Memory: 5000, Timestamp: timeNow})
+							})
+
+							AfterEach(func() {
+								profiles = nil
+							})
+
+							It("evaluates the cells with the profile's watermark and thresholds", func() {
+								Ω(mockRecorder.Code).To(Equal(417))
+								Ω(mockRecorder.Body.String()).Should(Equal(`{"healthy":false,"message":"The percentage of free memory will be too low during a migration!",` +
+									`"status":"warning","reasons":["low_upgrade_headroom"],"details":[` +
+									`{"index":"1","memory":5000,"low_memory":false},` +
+									`{"index":"2","memory":5000,"low_memory":false},` +
+									`{"index":"3","memory":5000,"low_memory":false}` +
+									`],"cellCount":3,"cellMemory":10000,"watermark":0,"requested_watermark":"0","totalFreeMemory":15000,"WatermarkMemoryPercent":50,` +
+									`"profile":{"name":"always","watermark":"0","headroom_warning_percent":60,"headroom_critical_percent":0},` +
+									`"distribution":{"min":5000,"max":5000,"median":5000,"p10":5000,"p90":5000,"stdDev":0,"spread":0,"imbalanced":false}}`))
+							})
+						})
+
+						Context("and instance sizes are configured", func() {
+							BeforeEach(func() {
+								sizes = []float64{1024, 4096}
+								metrics.Set("1", metricsLib.MessageMetric{Memory: 5000, Timestamp: timeNow})
+								metrics.Set("2", metricsLib.MessageMetric{Memory: 3000, Timestamp: timeNow})
+								metrics.Set("3", metricsLib.MessageMetric{Memory: 1500, Timestamp: timeNow})
+							})
+
+							AfterEach(func() {
+								sizes = nil
+							})
+
+							It("reports how much free memory each instance size can use", func() {
+								Ω(mockRecorder.Body.String()).Should(ContainSubstring(`"fragmentation":{"index":0.56,"largestFreeMemory":5000,"instanceSizes":[` +
+									`{"size":1024,"placeableInstances":7,"usableMemory":7168,"strandedMemory":2332,"index":0.24},` +
+									`{"size":4096,"placeableInstances":1,"usableMemory":4096,"strandedMemory":5404,"index":0.56}]}`))
+							})
+
+							Context("and the free memory is spread evenly over many cells", func() {
+								BeforeEach(func() {
+									for i := 1; i <= 100; i++ {
+										metrics.Set(strconv.Itoa(i), metricsLib.MessageMetric{Memory: 9000, Timestamp: timeNow})
+									}
+								})
+
+								AfterEach(func() {
+									for i := 4; i <= 100; i++ {
+										metrics.Delete(strconv.Itoa(i))
+									}
+								})
+
+								It("reports a low index as little of the free memory is stranded", func() {
+									Ω(mockRecorder.Body.String()).Should(ContainSubstring(`"fragmentation":{"index":0.08,"largestFreeMemory":9000,"instanceSizes":[` +
+										`{"size":1024,"placeableInstances":800,"usableMemory":819200,"strandedMemory":80800,"index":0.08},` +
+										`{"size":4096,"placeableInstances":200,"usableMemory":819200,"strandedMemory":80800,"index":0.08}]}`))
+								})
+							})
+						})
+
+						Context("and the free memory is imbalanced across cells", func() {
+							BeforeEach(func() {
+								imbalance = 2000
+								metrics.Set("1", metricsLib.MessageMetric{Memory: 500, Timestamp: timeNow})
+								metrics.Set("2", metricsLib.MessageMetric{Memory: 6000, Timestamp: timeNow})
+								metrics.Set("3", metricsLib.MessageMetric{Memory: 6500, Timestamp: timeNow})
+								metrics.Set("4", metricsLib.MessageMetric{Memory: 7000, Timestamp: timeNow})
+							})
+
+							AfterEach(func() {
+								imbalance = 0
+							})
+
+							It("warns that the cells are imbalanced and names the outliers", func() {
+								Ω(mockRecorder.Code).To(Equal(200))
+								Ω(mockRecorder.Body.String()).Should(ContainSubstring(`"warnings":["Free memory is imbalanced across cells, the spread is 6500MB, outliers: [1]"],` +
+									`"distribution":{"min":500,"max":7000,"median":6250,"p10":2150,"p90":6850,"stdDev":2622.02,"spread":6500,"imbalanced":true,"outliers":["1"]}}`))
+							})
+						})
+
+						Context("and the cells report availability zones", func() {
+							BeforeEach(func() {
+								metrics.Set("1", metricsLib.MessageMetric{Memory: 7000, Timestamp: timeNow, Zone: "z1"})
+								metrics.Set("2", metricsLib.MessageMetric{Memory: 7000, Timestamp: timeNow, Zone: "z1"})
+								metrics.Set("3", metricsLib.MessageMetric{Memory: 7000, Timestamp: timeNow, Zone: "z1"})
+								metrics.Set("4", metricsLib.MessageMetric{Memory: 4000, Timestamp: timeNow, Zone: "z2"})
+								metrics.Set("5", metricsLib.MessageMetric{Memory: 4000, Timestamp: timeNow, Zone: "z2"})
+							})
+
+							It("reports the capacity of each zone and whether losing a zone can be survived", func() {
+								Ω(mockRecorder.Code).To(Equal(200))
+								Ω(mockRecorder.Body.String()).Should(Equal(`{"healthy":true,"message":"Everything is awesome!","status":"ok","reasons":["ok"],"details":[` +
+									`{"index":"1","memory":7000,"low_memory":false,"zone":"z1"},` +
+									`{"index":"2","memory":7000,"low_memory":false,"zone":"z1"},` +
+									`{"index":"3","memory":7000,"low_memory":false,"zone":"z1"},` +
+									`{"index":"4","memory":4000,"low_memory":false,"zone":"z2"},` +
+									`{"index":"5","memory":4000,"low_memory":false,"zone":"z2"}` +
+									`],"cellCount":5,"cellMemory":10000,"watermark":1,"requested_watermark":"1","totalFreeMemory":29000,"WatermarkMemoryPercent":47.5,` +
+									`"zones":[{"zone":"z1","cellCount":3,"freeMemory":21000,"totalMemory":30000},{"zone":"z2","cellCount":2,"freeMemory":8000,"totalMemory":20000}],` +
+									`"zone_loss":{"survives":false,"zones":[` +
+									`{"zone":"z1","lostCells":3,"remainingCells":2,"displacedMemory":9000,"remainingFreeMemory":8000,"freeMemoryPercent":-5,"survives":false},` +
+									`{"zone":"z2","lostCells":2,"remainingCells":3,"displacedMemory":12000,"remainingFreeMemory":21000,"freeMemoryPercent":30,"survives":true}]},` +
+									`"distribution":{"min":4000,"max":7000,"median":7000,"p10":4000,"p90":7000,"stdDev":1469.69,"spread":3000,"imbalanced":false}}`))
+							})
+						})
+
+						Context("and some cells do not report an availability zone", func() {
+							BeforeEach(func() {
+								metrics.Set("1", metricsLib.MessageMetric{Memory: 7000, Timestamp: timeNow, Zone: "z1"})
+								metrics.Set("2", metricsLib.MessageMetric{Memory: 7000, Timestamp: timeNow, Zone: "z1"})
+								metrics.Set("3", metricsLib.MessageMetric{Memory: 7000, Timestamp: timeNow, Zone: "z2"})
+								metrics.Set("4", metricsLib.MessageMetric{Memory: 7000, Timestamp: timeNow, Zone: "z2"})
+								metrics.Set("5", metricsLib.MessageMetric{Memory: 4000, Timestamp: timeNow})
+							})
+
+							It("reports the unknown zone separately and leaves it out of the zone loss simulation", func() {
+								Ω(mockRecorder.Code).To(Equal(200))
+								Ω(mockRecorder.Body.String()).Should(ContainSubstring(`"zones":[{"zone":"unknown","cellCount":1,"freeMemory":4000,"totalMemory":10000},` +
+									`{"zone":"z1","cellCount":2,"freeMemory":14000,"totalMemory":20000},{"zone":"z2","cellCount":2,"freeMemory":14000,"totalMemory":20000}],` +
+									`"zone_loss":{"survives":true,"zones":[` +
+									`{"zone":"z1","lostCells":2,"remainingCells":3,"displacedMemory":6000,"remainingFreeMemory":18000,"freeMemoryPercent":40,"survives":true},` +
+									`{"zone":"z2","lostCells":2,"remainingCells":3,"displacedMemory":6000,"remainingFreeMemory":18000,"freeMemoryPercent":40,"survives":true}]},` +
+									`"warnings":["1 cells have no availability zone and are left out of the zone loss simulation"]`))
 							})
 						})
 					})
@@ -245,6 +844,71 @@ var _ = Describe("Controller", func() {
 		})
 	})
 
+	Describe("#Health", func() {
+		var (
+			cellMemory float64
+			watermark  string
+			receivedAt time.Time
+			metrics    metricsLib.Metrics
+			controller *webs.Controller
+		)
+
+		BeforeEach(func() {
+			cellMemory = 10000
+			watermark = "1"
+			receivedAt = time.Now().Add(-time.Hour)
+			metrics = metricsLib.CreateMetrics()
+			for _, index := range []string{"1", "2", "3"} {
+				metrics.Record(index, metricsLib.MessageMetric{Memory: 8000, Timestamp: receivedAt.UnixNano(), ReceivedAt: receivedAt.UnixNano()})
+			}
+			controller = webs.CreateController(metrics, &cellMemory, &watermark, receivedAt)
+			controller.ExpectedCellCount = 3
+		})
+
+		Context("when the health is evaluated at the wall clock", func() {
+			It("reports the metrics received an hour ago as missing", func() {
+				health := controller.Health()
+				Ω(health.Healthy).Should(BeFalse())
+				Ω(health.StatusCode).Should(Equal(410))
+				Ω(health.Reasons).Should(Equal([]string{"no_data"}))
+			})
+		})
+
+		Context("when the health is evaluated at the time Now returns", func() {
+			BeforeEach(func() {
+				now := func() time.Time { return receivedAt.Add(time.Minute) }
+				controller.Now = now
+				controller.Metrics.Now = now
+			})
+
+			It("reports the health the Index endpoint would at that time", func() {
+				health := controller.Health()
+				Ω(health.Healthy).Should(BeTrue())
+				Ω(health.StatusCode).Should(Equal(200))
+				Ω(health.Status).Should(Equal("ok"))
+				Ω(health.Reasons).Should(Equal([]string{"ok"}))
+			})
+
+			Context("and the source has failed", func() {
+				BeforeEach(func() {
+					controller.SetSourceError(errors.New("the firehose stream was closed"))
+				})
+
+				It("reports the health as unknown until the source is ingested again", func() {
+					health := controller.Health()
+					Ω(health.Healthy).Should(BeFalse())
+					Ω(health.StatusCode).Should(Equal(503))
+					Ω(health.Status).Should(Equal("unknown"))
+					Ω(health.Reasons).Should(Equal([]string{"source_unavailable"}))
+					Ω(health.Message).Should(Equal("The source is unavailable: the firehose stream was closed"))
+
+					controller.SetSourceError(nil)
+					Ω(controller.Health().Status).Should(Equal("ok"))
+				})
+			})
+		})
+	})
+
 	Describe("#CalculateWatermarkCellCount", func() {
 		var (
 			controller *webs.Controller
@@ -264,7 +928,7 @@ var _ = Describe("Controller", func() {
 				It("returns the specified watermark cell count value as an int", func() {
 					cellCount, err := controller.CalculateWatermarkCellCount(4)
 					Ω(cellCount).Should(Equal(0))
-					Ω(err).Should(MatchError(`strconv.Atoi: parsing "invalid": invalid syntax`))
+					Ω(err).Should(MatchError(`invalid watermark "invalid": "invalid" is not a number of cells, a percentage or an amount of memory`))
 				})
 			})
 
@@ -292,7 +956,7 @@ var _ = Describe("Controller", func() {
 				It("returns the specified watermark cell count value as an int", func() {
 					cellCount, err := controller.CalculateWatermarkCellCount(4)
 					Ω(cellCount).Should(Equal(0))
-					Ω(err).Should(MatchError(`strconv.Atoi: parsing "invalid": invalid syntax`))
+					Ω(err).Should(MatchError(`invalid watermark "invalid%": "invalid" is not a positive number`))
 				})
 			})
 
@@ -315,10 +979,116 @@ var _ = Describe("Controller", func() {
 					cellCount, err = controller.CalculateWatermarkCellCount(99)
 					Ω(cellCount).Should(Equal(10))
 					Ω(err).Should(BeNil())
+					cellCount, err = controller.CalculateWatermarkCellCount(50)
+					Ω(cellCount).Should(Equal(5))
+					Ω(err).Should(BeNil())
 				})
+
+				It("rounds with the watermark rounding mode", func() {
+					controller.WatermarkRounding = watermarkLib.Floor
+					cellCount, err := controller.CalculateWatermarkCellCount(56)
+					Ω(cellCount).Should(Equal(5))
+					Ω(err).Should(BeNil())
+				})
+			})
+		})
+
+		Context("when a watermark is supplied as an amount of memory", func() {
+			BeforeEach(func() {
+				watermark := "64GB"
+				cellMemory := float64(10000)
+				controller.Watermark = &watermark
+				controller.CellMemory = &cellMemory
+			})
+
+			It("returns the number of cells holding that much memory", func() {
+				cellCount, err := controller.CalculateWatermarkCellCount(50)
+				Ω(cellCount).Should(Equal(7))
+				Ω(err).Should(BeNil())
 			})
 		})
 	})
+
+	Describe("#ValidateWatermarks", func() {
+		var controller *webs.Controller
+
+		BeforeEach(func() {
+			watermark := "max(2, 10%)"
+			controller = &webs.Controller{Watermark: &watermark}
+		})
+
+		It("returns nil when all watermarks are valid", func() {
+			controller.PoolWatermarks = []webs.PoolWatermark{{Pattern: "iso-*", Watermark: "min(5, 20%)"}}
+			Ω(controller.ValidateWatermarks()).Should(BeNil())
+		})
+
+		It("returns an error when a pool watermark is invalid", func() {
+			controller.PoolWatermarks = []webs.PoolWatermark{{Pattern: "iso-*", Watermark: "max(5)"}}
+			Ω(controller.ValidateWatermarks()).Should(MatchError(`invalid watermark "max(5)": max needs at least 2 arguments`))
+		})
+
+		It("returns an error when a profile's pool watermark is invalid", func() {
+			var err error
+			controller.Schedule, err = schedule.Parse(`[{"name":"patching","pool_watermarks":"iso-*=max(5)"}]`)
+			Ω(err).Should(BeNil())
+			Ω(controller.ValidateWatermarks()).Should(MatchError(`invalid watermark "max(5)": max needs at least 2 arguments`))
+			controller.Schedule, err = schedule.Parse(`[{"name":"patching","pool_watermarks":"iso-*"}]`)
+			Ω(err).Should(BeNil())
+			Ω(controller.ValidateWatermarks()).Should(MatchError(`profile patching: pool watermark "iso-*" must be in the form pattern=watermark`))
+		})
+	})
+})
+
+var _ = Describe("#PoolName", func() {
+	var metric = metricsLib.MessageMetric{Deployment: "cf-iso", Job: "diego_cell", PlacementTags: []string{"windows", "isolated"}}
+
+	It("returns the attribute the cells are grouped by", func() {
+		Ω(webs.PoolName(metric, "deployment")).Should(Equal("cf-iso"))
+		Ω(webs.PoolName(metric, "job")).Should(Equal("diego_cell"))
+		Ω(webs.PoolName(metric, "placement_tags")).Should(Equal("isolated,windows"))
+	})
+
+	It("returns the default pool when the cell does not have the attribute", func() {
+		Ω(webs.PoolName(metricsLib.MessageMetric{}, "deployment")).Should(Equal("default"))
+		Ω(webs.PoolName(metricsLib.MessageMetric{}, "placement_tags")).Should(Equal("default"))
+	})
+})
+
+var _ = Describe("#ParsePoolWatermarks", func() {
+	It("parses pattern and watermark pairs in order", func() {
+		poolWatermarks, err := webs.ParsePoolWatermarks("iso-* = 2, cf=10%,")
+		Ω(err).Should(BeNil())
+		Ω(poolWatermarks).Should(Equal([]webs.PoolWatermark{{Pattern: "iso-*", Watermark: "2"}, {Pattern: "cf", Watermark: "10%"}}))
+	})
+
+	It("returns nothing when no pool watermarks are supplied", func() {
+		poolWatermarks, err := webs.ParsePoolWatermarks("")
+		Ω(err).Should(BeNil())
+		Ω(poolWatermarks).Should(BeEmpty())
+	})
+
+	It("returns an error when a pair is malformed", func() {
+		_, err := webs.ParsePoolWatermarks("iso-*")
+		Ω(err).Should(MatchError(`pool watermark "iso-*" must be in the form pattern=watermark`))
+	})
+
+	It("returns an error when a pattern is invalid", func() {
+		_, err := webs.ParsePoolWatermarks("iso-[=2")
+		Ω(err).Should(MatchError(`pool watermark pattern "iso-[" is invalid: syntax error in pattern`))
+	})
+})
+
+var _ = Describe("#ParseInstanceSizes", func() {
+	It("parses and sorts the sizes in MB", func() {
+		sizes, err := webs.ParseInstanceSizes("2GB, 256,1024MB")
+		Ω(err).Should(BeNil())
+		Ω(sizes).Should(Equal([]float64{256, 1024, 2048}))
+	})
+
+	It("returns an error for an invalid size", func() {
+		_, err := webs.ParseInstanceSizes("256,big")
+		Ω(err).Should(MatchError(`instance size "big" must be a positive amount of memory`))
+	})
 })
 
 var _ = Describe("#WatermarkMemoryPercent2dp", func() {
@@ -360,3 +1130,418 @@ var _ = Describe("#WatermarkMemoryPercent2dp", func() {
 		})
 	})
 })
+
+var _ = Describe("Hysteresis", func() {
+	var (
+		cellMemory float64 = 10000
+		watermark          = "1"
+		metrics    metricsLib.Metrics
+		controller *webs.Controller
+	)
+
+	setMemory := func(memory float64) {
+		for _, index := range []string{"1", "2", "3", "4"} {
+			metrics.Set(index, metricsLib.MessageMetric{Memory: memory, Timestamp: time.Now().UnixNano()})
+		}
+	}
+
+	request := func() *httptest.ResponseRecorder {
+		mockRecorder := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", "http://example.com/", nil)
+		Router(controller).ServeHTTP(mockRecorder, req)
+		return mockRecorder
+	}
+
+	BeforeEach(func() {
+		metrics = metricsLib.CreateMetrics()
+		controller = webs.CreateController(metrics, &cellMemory, &watermark, time.Now().Add(-5*time.Minute))
+	})
+
+	Context("when an exit margin is set", func() {
+		BeforeEach(func() {
+			controller.HysteresisMarginPercent = 5
+		})
+
+		It("stays in a warning until the headroom recovers past the threshold plus the margin", func() {
+			setMemory(3850)
+			mockRecorder := request()
+			Ω(mockRecorder.Code).To(Equal(417))
+			Ω(mockRecorder.Body.String()).Should(ContainSubstring(`"state":{"state":"warning","since":`))
+
+			setMemory(4150)
+			mockRecorder = request()
+			Ω(mockRecorder.Code).To(Equal(417))
+			Ω(mockRecorder.Body.String()).Should(ContainSubstring(`"WatermarkMemoryPercent":22,`))
+			Ω(mockRecorder.Body.String()).Should(ContainSubstring(`"reasons":["low_upgrade_headroom"]`))
+
+			setMemory(4450)
+			mockRecorder = request()
+			Ω(mockRecorder.Code).To(Equal(200))
+			Ω(mockRecorder.Body.String()).Should(ContainSubstring(`"state":{"state":"ok","since":`))
+		})
+	})
+
+	Context("when a minimum state duration is set", func() {
+		BeforeEach(func() {
+			controller.MinimumStateDuration = time.Hour
+		})
+
+		It("stays in a state for the minimum duration and reports the pending transition", func() {
+			setMemory(4450)
+			mockRecorder := request()
+			Ω(mockRecorder.Code).To(Equal(200))
+
+			setMemory(3850)
+			mockRecorder = request()
+			Ω(mockRecorder.Code).To(Equal(200))
+			Ω(mockRecorder.Body.String()).Should(ContainSubstring(`"status":"ok"`))
+			Ω(mockRecorder.Body.String()).Should(MatchRegexp(`"state":\{"state":"ok","since":"[^"]+","pending":\{"state":"warning","since":"[^"]+","at":"[^"]+"\}\}`))
+
+			setMemory(4450)
+			mockRecorder = request()
+			Ω(mockRecorder.Body.String()).ShouldNot(ContainSubstring(`"pending"`))
+		})
+	})
+
+	Context("when hysteresis is not configured", func() {
+		It("does not report a state", func() {
+			setMemory(4450)
+			Ω(request().Body.String()).ShouldNot(ContainSubstring(`"state"`))
+		})
+	})
+})
+
+var _ = Describe("#Apps", func() {
+	var (
+		cellMemory float64 = 10000
+		watermark          = "1"
+		controller *webs.Controller
+	)
+
+	request := func() *httptest.ResponseRecorder {
+		mockRecorder := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", "http://example.com/apps", nil)
+		Router(controller).ServeHTTP(mockRecorder, req)
+		return mockRecorder
+	}
+
+	BeforeEach(func() {
+		controller = webs.CreateController(metricsLib.CreateMetrics(), &cellMemory, &watermark, time.Now())
+	})
+
+	Context("when app instances are not tracked", func() {
+		It("returns an empty list", func() {
+			mockRecorder := request()
+			Ω(mockRecorder.Code).To(Equal(200))
+			Ω(mockRecorder.Body.String()).Should(Equal(`[]`))
+		})
+	})
+
+	Context("when app instances are tracked", func() {
+		BeforeEach(func() {
+			controller.Containers = containers.CreateUsage()
+			controller.Containers.Record(containers.Instance{AppID: "app-a", Cell: "1", MemoryBytes: 256 * 1024 * 1024, MemoryBytesQuota: 1024 * 1024 * 1024})
+			controller.Containers.Record(containers.Instance{AppID: "app-b", Cell: "1", MemoryBytes: 500 * 1024 * 1024, MemoryBytesQuota: 512 * 1024 * 1024})
+		})
+
+		It("returns the memory of each app, the most unused reserved memory first", func() {
+			Ω(request().Body.String()).Should(Equal(`[` +
+				`{"appId":"app-a","instanceCount":1,"actualMemory":256,"reservedMemory":1024,"unusedReservedMemory":768},` +
+				`{"appId":"app-b","instanceCount":1,"actualMemory":500,"reservedMemory":512,"unusedReservedMemory":12}]`))
+		})
+	})
+})
+
+type fakeCellLister struct {
+	cells []*bbs.CellPresence
+}
+
+func (f *fakeCellLister) Cells() ([]*bbs.CellPresence, error) {
+	return f.cells, nil
+}
+
+func newFakeLRPLister() *fakeLRPLister {
+	return &fakeLRPLister{
+		actualLRPs: []*bbs.ActualLRP{
+			{ActualLRPKey: &bbs.ActualLRPKey{ProcessGUID: "app-a"}, ActualLRPInstanceKey: &bbs.ActualLRPInstanceKey{InstanceGUID: "a-0", CellID: "1"}, State: "RUNNING"},
+			{ActualLRPKey: &bbs.ActualLRPKey{ProcessGUID: "app-b"}, ActualLRPInstanceKey: &bbs.ActualLRPInstanceKey{InstanceGUID: "b-0", CellID: "1"}, State: "RUNNING"},
+		},
+		schedulingInfos: []*bbs.DesiredLRPSchedulingInfo{
+			{DesiredLRPKey: &bbs.DesiredLRPKey{ProcessGUID: "app-a"}, DesiredLRPResource: &bbs.DesiredLRPResource{MemoryMB: 1024, DiskMB: 2048}},
+			{DesiredLRPKey: &bbs.DesiredLRPKey{ProcessGUID: "app-b"}, DesiredLRPResource: &bbs.DesiredLRPResource{MemoryMB: 256, DiskMB: 1024}},
+		},
+	}
+}
+
+type fakeLRPLister struct {
+	actualLRPs      []*bbs.ActualLRP
+	schedulingInfos []*bbs.DesiredLRPSchedulingInfo
+}
+
+func (f *fakeLRPLister) ActualLRPs() ([]*bbs.ActualLRP, error) {
+	return f.actualLRPs, nil
+}
+
+func (f *fakeLRPLister) DesiredLRPSchedulingInfos() ([]*bbs.DesiredLRPSchedulingInfo, error) {
+	return f.schedulingInfos, nil
+}
+
+var _ = Describe("#CellInstances", func() {
+	var (
+		cellMemory float64 = 10000
+		watermark          = "1"
+		controller *webs.Controller
+	)
+
+	request := func(cell string) *httptest.ResponseRecorder {
+		mockRecorder := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", "http://example.com/cells/"+cell+"/instances", nil)
+		Router(controller).ServeHTTP(mockRecorder, req)
+		return mockRecorder
+	}
+
+	BeforeEach(func() {
+		controller = webs.CreateController(metricsLib.CreateMetrics(), &cellMemory, &watermark, time.Now())
+	})
+
+	Context("when instances are not fetched from the BBS", func() {
+		It("returns not found", func() {
+			mockRecorder := request("1")
+			Ω(mockRecorder.Code).To(Equal(404))
+			Ω(mockRecorder.Body.String()).Should(Equal(`{"cell":"1","message":"Instances are not being fetched from the BBS","count":0,"memory_mb":0,"disk_mb":0,"instances":[]}`))
+		})
+	})
+
+	Context("when the instances have not been fetched yet", func() {
+		BeforeEach(func() {
+			controller.Instances = bbs.CreateInstances(newFakeLRPLister())
+		})
+
+		It("returns service unavailable", func() {
+			Ω(request("1").Code).To(Equal(503))
+		})
+	})
+
+	Context("when the instances have been fetched", func() {
+		BeforeEach(func() {
+			controller.Instances = bbs.CreateInstances(newFakeLRPLister())
+			Ω(controller.Instances.Refresh()).Should(Succeed())
+		})
+
+		It("returns the instances on the cell", func() {
+			mockRecorder := request("1")
+			Ω(mockRecorder.Code).To(Equal(200))
+			Ω(mockRecorder.Body.String()).Should(MatchRegexp(`^\{"cell":"1","fetchedAt":"[^"]+","count":2,"memory_mb":1280,"disk_mb":3072,"process_guids":\["app-a","app-b"\],"instances":\[` +
+				`\{"process_guid":"app-a","index":0,"instance_guid":"a-0","state":"RUNNING","memory_mb":1024,"disk_mb":2048\},` +
+				`\{"process_guid":"app-b","index":0,"instance_guid":"b-0","state":"RUNNING","memory_mb":256,"disk_mb":1024\}\]\}$`))
+		})
+
+		It("returns an empty list for a cell without instances", func() {
+			mockRecorder := request("2")
+			Ω(mockRecorder.Code).To(Equal(200))
+			Ω(mockRecorder.Body.String()).Should(ContainSubstring(`"count":0,"memory_mb":0,"disk_mb":0,"instances":[]}`))
+		})
+	})
+})
+
+var _ = Describe("Overview", func() {
+	var (
+		overview     *webs.Overview
+		mockRecorder *httptest.ResponseRecorder
+		path         string
+		timeNow      = time.Now().UnixNano()
+	)
+
+	newFoundation := func(name string, memory ...float64) webs.Foundation {
+		metrics := metricsLib.CreateMetrics()
+		for i, free := range memory {
+			metrics.Set(fmt.Sprintf("%s-%d", name, i), metricsLib.MessageMetric{Memory: free, Timestamp: timeNow})
+		}
+		cellMemory := 10000.0
+		watermark := "1"
+		return webs.Foundation{Name: name, Controller: webs.CreateController(metrics, &cellMemory, &watermark, time.Now().Add(-5*time.Minute))}
+	}
+
+	JustBeforeEach(func() {
+		mockRecorder = httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", "http://example.com"+path, nil)
+		overview.Start().ServeHTTP(mockRecorder, req)
+	})
+
+	BeforeEach(func() {
+		overview = &webs.Overview{Foundations: []webs.Foundation{
+			newFoundation("east", 6000, 6000),
+			newFoundation("west", 1000, 1000),
+		}}
+	})
+
+	Describe("#Index", func() {
+		BeforeEach(func() {
+			path = "/"
+		})
+
+		It("summarises every foundation with the status of the worst", func() {
+			Ω(mockRecorder.Code).To(Equal(417))
+			Ω(mockRecorder.Body.String()).Should(Equal(`{"healthy":false,"status":"critical",` +
+				`"message":"Foundation west: FATAL - There is not enough space to do an upgrade, add cells or reduce watermark!","foundations":[` +
+				`{"name":"east","healthy":true,"status":"ok","reasons":["ok"],"message":"Everything is awesome!","cellCount":2,"cellMemory":10000,"watermark":1,"totalFreeMemory":12000},` +
+				`{"name":"west","healthy":false,"status":"critical","reasons":["no_upgrade_headroom"],` +
+				`"message":"FATAL - There is not enough space to do an upgrade, add cells or reduce watermark!","cellCount":2,"cellMemory":10000,"watermark":1,"totalFreeMemory":2000}]}`))
+		})
+
+		Context("when every foundation is healthy", func() {
+			BeforeEach(func() {
+				overview.Foundations = overview.Foundations[:1]
+			})
+
+			It("reports healthy as true", func() {
+				Ω(mockRecorder.Code).To(Equal(200))
+				Ω(mockRecorder.Body.String()).Should(HavePrefix(`{"healthy":true,"status":"ok","message":"Everything is awesome!","foundations":[{"name":"east",`))
+			})
+		})
+	})
+
+	Describe("#Foundation", func() {
+		Context("when the foundation exists", func() {
+			BeforeEach(func() {
+				path = "/foundations/east"
+			})
+
+			It("returns the foundation's report", func() {
+				Ω(mockRecorder.Code).To(Equal(200))
+				Ω(mockRecorder.Body.String()).Should(HavePrefix(`{"healthy":true,"message":"Everything is awesome!","status":"ok","reasons":["ok"],"details":[` +
+					`{"index":"east-0","memory":6000,"low_memory":false},{"index":"east-1","memory":6000,"low_memory":false}]`))
+			})
+		})
+
+		Context("when the foundation's apps are requested", func() {
+			BeforeEach(func() {
+				path = "/foundations/west/apps"
+			})
+
+			It("returns the foundation's apps", func() {
+				Ω(mockRecorder.Code).To(Equal(200))
+				Ω(mockRecorder.Body.String()).Should(Equal(`[]`))
+			})
+		})
+
+		Context("when the foundation does not exist", func() {
+			BeforeEach(func() {
+				path = "/foundations/north"
+			})
+
+			It("returns 404", func() {
+				Ω(mockRecorder.Code).To(Equal(404))
+				Ω(mockRecorder.Body.String()).Should(Equal(`{"message":"There is no foundation named north"}`))
+			})
+		})
+	})
+})
+
+var _ = Describe("Federated", func() {
+	var (
+		downstream   *httptest.Server
+		federated    *webs.Federated
+		mockRecorder *httptest.ResponseRecorder
+		path         string
+		body         string
+		statusCode   int
+	)
+
+	BeforeEach(func() {
+		path = "/"
+		statusCode = http.StatusOK
+		body = `{"healthy":true,"message":"Everything is awesome!","status":"ok","reasons":["ok"],"cellCount":3,"cellMemory":10000,"watermark":1,"totalFreeMemory":15000}`
+		downstream = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(statusCode)
+			fmt.Fprint(w, body)
+		}))
+		federated = &webs.Federated{Federation: federation.CreateFederation([]federation.Downstream{
+			{Name: "east", URL: downstream.URL},
+			{Name: "west", URL: "http://127.0.0.1:1/"},
+		}, 0)}
+	})
+
+	JustBeforeEach(func() {
+		federated.Federation.Refresh()
+		mockRecorder = httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", "http://example.com"+path, nil)
+		federated.Start().ServeHTTP(mockRecorder, req)
+	})
+
+	AfterEach(func() {
+		downstream.Close()
+	})
+
+	Describe("#Index", func() {
+		It("merges the reports, treating an unreachable downstream as unknown", func() {
+			Ω(mockRecorder.Code).To(Equal(503))
+			Ω(mockRecorder.Body.String()).Should(HavePrefix(`{"healthy":false,"status":"unknown","message":"Foundation west: The report has not been fetched yet","foundations":[` +
+				`{"name":"east","healthy":true,"status":"ok","reasons":["ok"],"message":"Everything is awesome!","cellCount":3,"cellMemory":10000,"watermark":1,` +
+				`"totalFreeMemory":15000,"url":"` + downstream.URL + `","fetchedAt":"`))
+			Ω(mockRecorder.Body.String()).Should(ContainSubstring(`{"name":"west","healthy":false,"status":"unknown","reasons":["unreachable"],` +
+				`"message":"The report has not been fetched yet","cellCount":0,"cellMemory":0,"watermark":0,"totalFreeMemory":0,"url":"http://127.0.0.1:1/","error":"Get`))
+		})
+
+		Context("when the reachable downstream is unhealthy", func() {
+			BeforeEach(func() {
+				federated.Federation = federation.CreateFederation([]federation.Downstream{{Name: "east", URL: downstream.URL}}, 0)
+				statusCode = http.StatusExpectationFailed
+				body = `{"healthy":false,"message":"The number of cells needs to exceed the watermark amount!","status":"critical","reasons":["insufficient_cells"]}`
+			})
+
+			It("takes the downstream's status code", func() {
+				Ω(mockRecorder.Code).To(Equal(417))
+				Ω(mockRecorder.Body.String()).Should(HavePrefix(`{"healthy":false,"status":"critical",` +
+					`"message":"Foundation east: The number of cells needs to exceed the watermark amount!"`))
+			})
+		})
+
+		Context("when the downstream's report is stale", func() {
+			BeforeEach(func() {
+				federated.Federation = federation.CreateFederation([]federation.Downstream{{Name: "east", URL: downstream.URL}}, 0)
+				federated.StaleDuration = time.Nanosecond
+			})
+
+			It("is unknown", func() {
+				Ω(mockRecorder.Code).To(Equal(503))
+				Ω(mockRecorder.Body.String()).Should(ContainSubstring(`"status":"unknown","reasons":["stale_report"],"message":"The report has not been fetched since `))
+				Ω(mockRecorder.Body.String()).Should(ContainSubstring(`"stale":true`))
+			})
+		})
+	})
+
+	Describe("#Foundation", func() {
+		Context("when the downstream's report has been fetched", func() {
+			BeforeEach(func() {
+				path = "/foundations/east"
+			})
+
+			It("returns the report as the downstream returned it", func() {
+				Ω(mockRecorder.Code).To(Equal(200))
+				Ω(mockRecorder.Body.String()).Should(Equal(body))
+			})
+		})
+
+		Context("when the downstream is unreachable", func() {
+			BeforeEach(func() {
+				path = "/foundations/west"
+			})
+
+			It("returns 503", func() {
+				Ω(mockRecorder.Code).To(Equal(503))
+				Ω(mockRecorder.Body.String()).Should(ContainSubstring(`"message":"The report has not been fetched yet"`))
+			})
+		})
+
+		Context("when there is no such downstream", func() {
+			BeforeEach(func() {
+				path = "/foundations/north"
+			})
+
+			It("returns 404", func() {
+				Ω(mockRecorder.Code).To(Equal(404))
+			})
+		})
+	})
+})
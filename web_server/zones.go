@@ -0,0 +1,122 @@
+package webServer
+
+import (
+	"fmt"
+	"sort"
+)
+
+const unknownZone = "unknown"
+
+type zoneReport struct {
+	Zone        string  `json:"zone"`
+	CellCount   int     `json:"cellCount"`
+	FreeMemory  float64 `json:"freeMemory"`
+	TotalMemory float64 `json:"totalMemory"`
+}
+
+type zoneLossReport struct {
+	Zone                string  `json:"zone"`
+	LostCells           int     `json:"lostCells"`
+	RemainingCells      int     `json:"remainingCells"`
+	DisplacedMemory     float64 `json:"displacedMemory"`
+	RemainingFreeMemory float64 `json:"remainingFreeMemory"`
+	FreeMemoryPercent   float64 `json:"freeMemoryPercent"`
+	Survives            bool    `json:"survives"`
+}
+
+type zoneLossSimulation struct {
+	Survives bool             `json:"survives"`
+	Zones    []zoneLossReport `json:"zones"`
+}
+
+// zoneReports groups the reporting cells by availability zone, it returns nil when no cell has a zone
+func zoneReports(cells []cellReport, cellMemory float64) []zoneReport {
+	zoned := false
+	zones := make(map[string]*zoneReport)
+	for _, cell := range cells {
+		name := cell.Zone
+		if name == "" {
+			name = unknownZone
+		} else {
+			zoned = true
+		}
+		zone, ok := zones[name]
+		if !ok {
+			zone = &zoneReport{Zone: name}
+			zones[name] = zone
+		}
+		zone.CellCount++
+		zone.FreeMemory += cell.Memory
+		zone.TotalMemory += cellMemory
+	}
+
+	if !zoned {
+		return nil
+	}
+
+	var names []string
+	for name := range zones {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var reports []zoneReport
+	for _, name := range names {
+		reports = append(reports, *zones[name])
+	}
+	return reports
+}
+
+// unzonedWarning - warns that the cells without an availability zone are left out of the zone loss simulation
+func unzonedWarning(zones []zoneReport) string {
+	for _, zone := range zones {
+		if zone.Zone == unknownZone {
+			return fmt.Sprintf("%d cells have no availability zone and are left out of the zone loss simulation", zone.CellCount)
+		}
+	}
+	return ""
+}
+
+// simulateZoneLoss works out, for each zone in turn, whether the memory in use on that zone's cells would fit
+// into the free memory of the cells left in the other zones. Cells without a zone are never lost, as they
+// cannot be placed in a zone, but their free memory still counts towards what is left
+func simulateZoneLoss(zones []zoneReport) *zoneLossSimulation {
+	if len(zones) == 0 {
+		return nil
+	}
+
+	var (
+		totalCells       int
+		totalFreeMemory  float64
+		totalCellsMemory float64
+	)
+	for _, zone := range zones {
+		totalCells += zone.CellCount
+		totalFreeMemory += zone.FreeMemory
+		totalCellsMemory += zone.TotalMemory
+	}
+
+	simulation := &zoneLossSimulation{Survives: true}
+	for _, zone := range zones {
+		if zone.Zone == unknownZone {
+			continue
+		}
+		loss := zoneLossReport{
+			Zone:                zone.Zone,
+			LostCells:           zone.CellCount,
+			RemainingCells:      totalCells - zone.CellCount,
+			DisplacedMemory:     zone.TotalMemory - zone.FreeMemory,
+			RemainingFreeMemory: totalFreeMemory - zone.FreeMemory,
+		}
+		remainingMemory := totalCellsMemory - zone.TotalMemory
+		if remainingMemory > 0 {
+			loss.FreeMemoryPercent = truncate2dp(((loss.RemainingFreeMemory - loss.DisplacedMemory) / remainingMemory) * 100)
+		}
+		loss.Survives = loss.RemainingCells > 0 && loss.RemainingFreeMemory >= loss.DisplacedMemory
+		if !loss.Survives {
+			simulation.Survives = false
+		}
+		simulation.Zones = append(simulation.Zones, loss)
+	}
+	return simulation
+}